@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"cronlogger"
+	"cronlogger/store"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// the access rules grant roles on applications to users and groups. The roles build on
+// each other: trigger includes read and admin includes trigger. The admin role on all
+// applications ("*") is needed for the administration of the server, e.g. the backups.
+
+// Role is the permission of a principal on an application
+type Role int
+
+const (
+	// RoleNone grants no access
+	RoleNone Role = iota
+	// RoleRead shows the executions of an application
+	RoleRead
+	// RoleTrigger starts the job of an application via the web UI
+	RoleTrigger
+	// RoleAdmin administrates the applications
+	RoleAdmin
+)
+
+var roleNames = [...]string{RoleNone: "none", RoleRead: "read", RoleTrigger: "trigger", RoleAdmin: "admin"}
+
+// ParseRole returns the role of the name used in the configuration
+func ParseRole(name string) (Role, error) {
+	for role, roleName := range roleNames {
+		if roleName == name && Role(role) != RoleNone {
+			return Role(role), nil
+		}
+	}
+	return RoleNone, fmt.Errorf("unknown role '%s', expected read, trigger or admin", name)
+}
+
+func (r Role) String() string {
+	if r < RoleNone || int(r) >= len(roleNames) {
+		return fmt.Sprintf("Role(%d)", int(r))
+	}
+	return roleNames[r]
+}
+
+// Access evaluates the access rules of the configuration
+type Access struct {
+	rules []accessRule
+}
+
+type accessRule struct {
+	users  []string
+	groups []string
+	apps   []string
+	role   Role
+}
+
+// NewAccess validates the access rules. Without rules every principal has the admin role on all applications.
+func NewAccess(rules []cronlogger.AccessRule) (*Access, error) {
+	a := &Access{}
+	for i, rule := range rules {
+		role, err := ParseRole(rule.Role)
+		if err != nil {
+			return nil, fmt.Errorf("invalid access rule %d; %v", i+1, err)
+		}
+		if len(rule.Users) == 0 && len(rule.Groups) == 0 {
+			return nil, fmt.Errorf("invalid access rule %d; the rule needs users or groups", i+1)
+		}
+		if len(rule.Apps) == 0 || slices.Contains(rule.Apps, "") {
+			return nil, fmt.Errorf("invalid access rule %d; the rule needs applications", i+1)
+		}
+		a.rules = append(a.rules, accessRule{users: rule.Users, groups: rule.Groups, apps: rule.Apps, role: role})
+	}
+	return a, nil
+}
+
+// Restricted reports whether access rules are defined
+func (a *Access) Restricted() bool {
+	return len(a.rules) > 0
+}
+
+// Allowed reports whether the principal has the role on the application.
+// A principal with scopes may only read the applications of its read scopes.
+func (a *Access) Allowed(p Principal, role Role, app string) bool {
+	if p.Scoped() {
+		return role <= RoleRead && scopeAllows(p.Scopes, ScopeRead, app)
+	}
+	if !a.Restricted() {
+		return true
+	}
+	for _, rule := range a.rules {
+		if rule.role < role || !rule.applies(p) {
+			continue
+		}
+		for _, pattern := range rule.apps {
+			if store.MatchApp(pattern, app) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Admin reports whether the principal has the admin role on all applications
+func (a *Access) Admin(p Principal) bool {
+	return !p.Scoped() && a.Apps(p, RoleAdmin) == nil
+}
+
+// Apps returns the patterns of the applications on which the principal has the role,
+// see store.WithApps. The result is nil if the principal has the role on all applications.
+func (a *Access) Apps(p Principal, role Role) []string {
+	if p.Scoped() {
+		if role > RoleRead {
+			return []string{}
+		}
+		return scopeApps(p.Scopes, ScopeRead)
+	}
+	if !a.Restricted() {
+		return nil
+	}
+	patterns := []string{}
+	for _, rule := range a.rules {
+		if rule.role < role || !rule.applies(p) {
+			continue
+		}
+		if slices.Contains(rule.apps, store.AllApps) {
+			return nil
+		}
+		patterns = append(patterns, rule.apps...)
+	}
+	return patterns
+}
+
+// applies matches the principal by its name or its groups. The users of an OIDC provider are
+// only matched by the entries with OIDCPrefix, a local user or a token never by these entries.
+func (r accessRule) applies(p Principal) bool {
+	for _, user := range r.users {
+		if user == p.Name && strings.HasPrefix(user, OIDCPrefix) == (p.Method == MethodOIDC) {
+			return true
+		}
+	}
+	for _, group := range p.Groups {
+		if slices.Contains(r.groups, group) {
+			return true
+		}
+	}
+	return false
+}
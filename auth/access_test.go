@@ -0,0 +1,115 @@
+package auth_test
+
+import (
+	"cronlogger"
+	"cronlogger/auth"
+	"cronlogger/store"
+	"slices"
+	"testing"
+)
+
+var rules = []cronlogger.AccessRule{
+	{Groups: []string{"ops"}, Apps: []string{"*"}, Role: "admin"},
+	{Groups: []string{"backup"}, Apps: []string{"rclone-*"}, Role: "trigger"},
+	{Users: []string{"bob"}, Apps: []string{"acme-tls"}, Role: "read"},
+	{Users: []string{"oidc:248289761001"}, Apps: []string{"certbot"}, Role: "read"},
+}
+
+func Test_Access(t *testing.T) {
+	a, err := auth.NewAccess(rules)
+	if err != nil {
+		t.Fatalf("could not create the access rules; %v", err)
+	}
+	alice := auth.Principal{Name: "alice", Groups: []string{"ops"}}
+	bob := auth.Principal{Name: "bob", Groups: []string{"backup"}}
+	eve := auth.Principal{Name: "eve"}
+	// the users of the OIDC provider and the local users are distinguished
+	carol := auth.Principal{Name: "oidc:248289761001", Method: auth.MethodOIDC}
+	oidcBob := auth.Principal{Name: "bob", Method: auth.MethodOIDC}
+	localCarol := auth.Principal{Name: "oidc:248289761001", Method: auth.MethodBasic}
+
+	for _, tc := range []struct {
+		p    auth.Principal
+		role auth.Role
+		app  string
+		want bool
+	}{
+		{alice, auth.RoleAdmin, "acme-tls", true},
+		{bob, auth.RoleTrigger, "rclone-photos", true},
+		{bob, auth.RoleAdmin, "rclone-photos", false},
+		{bob, auth.RoleRead, "acme-tls", true},
+		{bob, auth.RoleTrigger, "acme-tls", false},
+		{bob, auth.RoleRead, "certbot", false},
+		{eve, auth.RoleRead, "acme-tls", false},
+		{carol, auth.RoleRead, "certbot", true},
+		{oidcBob, auth.RoleRead, "acme-tls", false},
+		{localCarol, auth.RoleRead, "certbot", false},
+	} {
+		if got := a.Allowed(tc.p, tc.role, tc.app); got != tc.want {
+			t.Errorf("%s %s %s: expected %v, got %v", tc.p.Name, tc.role, tc.app, tc.want, got)
+		}
+	}
+
+	if !a.Admin(alice) || a.Admin(bob) {
+		t.Errorf("expected only alice to be an admin")
+	}
+	if apps := a.Apps(alice, auth.RoleRead); apps != nil {
+		t.Errorf("expected alice to read all applications, got %v", apps)
+	}
+	if apps := a.Apps(bob, auth.RoleRead); !slices.Equal(apps, []string{"rclone-*", "acme-tls"}) {
+		t.Errorf("unexpected applications of bob %v", apps)
+	}
+	if apps := a.Apps(eve, auth.RoleRead); apps == nil || len(apps) != 0 {
+		t.Errorf("expected eve to read no applications, got %v", apps)
+	}
+
+	// without rules everything is allowed
+	a, _ = auth.NewAccess(nil)
+	if !a.Allowed(eve, auth.RoleAdmin, "acme-tls") || !a.Admin(eve) || a.Apps(eve, auth.RoleRead) != nil {
+		t.Errorf("expected everything to be allowed without rules")
+	}
+}
+
+func Test_NewAccess_Invalid(t *testing.T) {
+	for name, rule := range map[string]cronlogger.AccessRule{
+		"role":  {Users: []string{"bob"}, Apps: []string{"*"}, Role: "owner"},
+		"users": {Apps: []string{"*"}, Role: "read"},
+		"apps":  {Users: []string{"bob"}, Role: "read"},
+	} {
+		if _, err := auth.NewAccess([]cronlogger.AccessRule{rule}); err == nil {
+			t.Errorf("%s: expected an error for the invalid rule", name)
+		}
+	}
+	if _, err := auth.New(cronlogger.AuthConfig{Access: rules}, logger); err == nil {
+		t.Errorf("expected an error for access rules without authentication")
+	}
+}
+
+func Test_ScopeApps(t *testing.T) {
+	hash, _ := auth.HashPassword("secret")
+	s, err := auth.New(cronlogger.AuthConfig{
+		Users:  []cronlogger.User{{Name: "bob", Password: hash}},
+		Access: rules,
+	}, logger)
+	if err != nil {
+		t.Fatalf("could not create the authentication; %v", err)
+	}
+
+	ctx := auth.WithPrincipal(t.Context(), auth.Principal{Name: "bob"})
+	if apps, ok := store.AppsOf(s.ScopeApps(ctx)); !ok || !slices.Equal(apps, []string{"acme-tls"}) {
+		t.Errorf("unexpected applications of bob %v", apps)
+	}
+	if !s.Allowed(ctx, auth.RoleRead, "acme-tls") || s.Allowed(ctx, auth.RoleTrigger, "acme-tls") || s.Admin(ctx) {
+		t.Errorf("unexpected roles of bob")
+	}
+
+	// a request without principal, e.g. of the login page, does not see any application
+	if apps, ok := store.AppsOf(s.ScopeApps(t.Context())); !ok || len(apps) != 0 {
+		t.Errorf("expected no applications without principal, got %v", apps)
+	}
+
+	ctx = auth.WithPrincipal(t.Context(), auth.Principal{Name: "alice", Groups: []string{"ops"}})
+	if _, ok := store.AppsOf(s.ScopeApps(ctx)); ok || !s.Admin(ctx) {
+		t.Errorf("expected alice to access all applications")
+	}
+}
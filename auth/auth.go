@@ -0,0 +1,359 @@
+package auth
+
+import (
+	"context"
+	"cronlogger"
+	"cronlogger/store"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// the authentication is a chain of authenticators which are asked one after another
+// for the principal of a request: the session cookie of a browser, HTTP basic auth and
+// bearer tokens. Further authenticators can be added with Use, e.g. the ManagedTokens.
+
+// LoginPath is the path of the login page, it is available without authentication
+const LoginPath = "/cronlogger/Login"
+
+const (
+	defaultSessionTTL    = 12 * time.Hour
+	oidcDiscoveryTimeout = 10 * time.Second
+)
+
+var (
+	// ErrNoCredentials is returned by an authenticator if the request does not provide its credentials
+	ErrNoCredentials = errors.New("no credentials provided")
+	// ErrInvalidCredentials is returned for unknown users, wrong passwords and invalid tokens
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// the authentication methods of a principal
+const (
+	MethodBasic   = "basic"
+	MethodToken   = "token"
+	MethodSession = "session"
+	MethodOIDC    = "oidc"
+)
+
+// OIDCPrefix is the prefix of the names of the users of an OIDC provider, the name is followed
+// by the subject of the ID token, e.g. oidc:248289761001. The access rules use the same names.
+const OIDCPrefix = "oidc:"
+
+// Principal is the authenticated user or machine client of a request
+type Principal struct {
+	Name   string   `json:"name"`
+	Groups []string `json:"groups,omitempty"`
+	// Display is shown instead of the name in the web UI, e.g. the preferred username of an OIDC user
+	Display string `json:"display,omitempty"`
+	// Method is the authentication method, a session is started by a login with the password or via OIDC
+	Method string `json:"method"`
+	// Scopes limit a token of the token store, see ParseScopes
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+// HasSession reports whether the principal has a session which can be ended by a logout
+func (p Principal) HasSession() bool {
+	return p.Method == MethodSession || p.Method == MethodOIDC
+}
+
+// DisplayName returns the name shown in the web UI
+func (p Principal) DisplayName() string {
+	if p.Display != "" {
+		return p.Display
+	}
+	return p.Name
+}
+
+// Scoped reports whether the principal is limited by scopes instead of the access rules
+func (p Principal) Scoped() bool {
+	return len(p.Scopes) > 0
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a context carrying the principal
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal of an authenticated request
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// Authenticator determines the principal of a request. ErrNoCredentials is returned if the
+// request does not contain the credentials of the authenticator, the next authenticator is asked then.
+type Authenticator interface {
+	Authenticate(r *http.Request) (Principal, error)
+}
+
+// Service authenticates the requests of the server and manages the sessions of the browsers
+type Service struct {
+	logger         *slog.Logger
+	users          *Users
+	sessions       *Sessions
+	oidc           *OIDC
+	access         *Access
+	authenticators []Authenticator
+	// public paths are available without authentication
+	public []string
+}
+
+// New creates the authentication of the configured users, tokens and OIDC provider.
+// Without users, tokens and provider the authentication is disabled, see Enabled.
+func New(config cronlogger.AuthConfig, logger *slog.Logger) (*Service, error) {
+	users, err := NewUsers(config.Users)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := NewTokens(config.Tokens)
+	if err != nil {
+		return nil, err
+	}
+	ttl := config.SessionTTL
+	if ttl == 0 {
+		ttl = defaultSessionTTL
+	}
+	sessions, err := NewSessions([]byte(config.SessionKey), ttl)
+	if err != nil {
+		return nil, err
+	}
+	access, err := NewAccess(config.Access)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Service{
+		logger:   logger,
+		users:    users,
+		sessions: sessions,
+		access:   access,
+		public:   []string{LoginPath, "/assets/"},
+	}
+	if config.OIDC.Issuer != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), oidcDiscoveryTimeout)
+		defer cancel()
+		if s.oidc, err = NewOIDC(ctx, config.OIDC, sessions); err != nil {
+			return nil, err
+		}
+		s.public = append(s.public, OIDCLoginPath, OIDCCallbackPath)
+	}
+	if len(config.Users) > 0 || len(config.Tokens) > 0 || s.oidc != nil {
+		s.authenticators = []Authenticator{sessions, users, tokens}
+	}
+	if access.Restricted() && !s.Enabled() {
+		return nil, fmt.Errorf("the access rules need users, tokens or an OIDC provider")
+	}
+	return s, nil
+}
+
+// Use adds an authenticator to the chain, it also enables the authentication
+func (s *Service) Use(a Authenticator) {
+	s.authenticators = append(s.authenticators, a)
+}
+
+// Public makes paths available without authentication, a path ending with a slash includes all sub-paths
+func (s *Service) Public(paths ...string) {
+	s.public = append(s.public, paths...)
+}
+
+// Enabled reports whether the requests need to be authenticated
+func (s *Service) Enabled() bool {
+	return len(s.authenticators) > 0
+}
+
+// PasswordLogin reports whether users can log in with a password
+func (s *Service) PasswordLogin() bool {
+	return s.users.Len() > 0
+}
+
+// OIDC returns the single sign-on, it is nil if no provider is configured
+func (s *Service) OIDC() *OIDC {
+	return s.oidc
+}
+
+// Sessions returns the session cookies of the browsers
+func (s *Service) Sessions() *Sessions {
+	return s.sessions
+}
+
+// Authenticate asks the authenticators for the principal of the request
+func (s *Service) Authenticate(r *http.Request) (Principal, error) {
+	for _, a := range s.authenticators {
+		p, err := a.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return p, err
+	}
+	return Principal{}, ErrNoCredentials
+}
+
+// Allowed reports whether the principal of the context has the role on the application.
+// Without authentication or access rules everything is allowed.
+func (s *Service) Allowed(ctx context.Context, role Role, app string) bool {
+	p, restricted := s.restriction(ctx)
+	return !restricted || (p != nil && s.access.Allowed(*p, role, app))
+}
+
+// Admin reports whether the principal of the context has the admin role on all applications
+func (s *Service) Admin(ctx context.Context) bool {
+	p, restricted := s.restriction(ctx)
+	return !restricted || (p != nil && s.access.Admin(*p))
+}
+
+// ScopeApps restricts the store queries of the context to the applications the principal may read, see store.WithApps
+func (s *Service) ScopeApps(ctx context.Context) context.Context {
+	p, restricted := s.restriction(ctx)
+	if !restricted {
+		return ctx
+	}
+	if p == nil {
+		return store.WithApps(ctx, nil)
+	}
+	if patterns := s.access.Apps(*p, RoleRead); patterns != nil {
+		return store.WithApps(ctx, patterns)
+	}
+	return ctx
+}
+
+// Ingest reports whether the principal of the context may store executions of the application. Tokens
+// with scopes need an ingest scope, the other principals the trigger role.
+func (s *Service) Ingest(ctx context.Context, app string) bool {
+	if p, ok := FromContext(ctx); ok && p.Scoped() {
+		return scopeAllows(p.Scopes, ScopeIngest, app)
+	}
+	return s.Allowed(ctx, RoleTrigger, app)
+}
+
+// restriction returns the principal of the context if the access rules or scopes apply, the
+// principal is nil if the request is not authenticated, e.g. a request of a public path
+func (s *Service) restriction(ctx context.Context) (*Principal, bool) {
+	if !s.Enabled() {
+		return nil, false
+	}
+	p, ok := FromContext(ctx)
+	if ok && p.Scoped() {
+		return &p, true
+	}
+	if !s.access.Restricted() {
+		return nil, false
+	}
+	if ok {
+		return &p, true
+	}
+	return nil, true
+}
+
+// Login verifies the password of a user and starts a session
+func (s *Service) Login(w http.ResponseWriter, r *http.Request, name, password string) (Principal, error) {
+	p, err := s.users.Verify(name, password)
+	if err != nil {
+		return Principal{}, err
+	}
+	p.Method = MethodSession
+	if err := s.sessions.Create(w, r, p); err != nil {
+		return Principal{}, err
+	}
+	return p, nil
+}
+
+// Logout ends the session of the browser. The returned URL is the logout of the OIDC
+// provider if the user logged in via OIDC and the provider supports the logout.
+func (s *Service) Logout(w http.ResponseWriter, r *http.Request) string {
+	s.sessions.Clear(w, r)
+	if p, ok := FromContext(r.Context()); ok && p.Method == MethodOIDC && s.oidc != nil {
+		return s.oidc.LogoutURL()
+	}
+	return ""
+}
+
+// Middleware rejects the requests which are not authenticated. Browsers are redirected to
+// the login page, other clients receive a 401 response.
+func (s *Service) Middleware(next http.Handler) http.Handler {
+	if !s.Enabled() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.isPublic(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		p, err := s.Authenticate(r)
+		if err == nil {
+			if err := checkScopes(p, r); err != nil {
+				s.logger.Warn("rejected the request", "remote", clientAddr(r), "path", r.URL.Path, "error", err)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusForbidden)
+				json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+			return
+		}
+		if !errors.Is(err, ErrNoCredentials) {
+			s.logger.Warn("rejected the request", "remote", clientAddr(r), "path", r.URL.Path, "error", err)
+		}
+
+		if wantsHTML(r) {
+			http.Redirect(w, r, LoginURL(r.URL.RequestURI()), http.StatusSeeOther)
+			return
+		}
+		if r.Header.Get("HX-Request") != "" {
+			// htmx replaces the page with the login page
+			w.Header().Set("HX-Redirect", LoginPath)
+		}
+		w.Header().Set("WWW-Authenticate", `Basic realm="cronlogger", charset="UTF-8"`)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "authentication required"})
+	})
+}
+
+func (s *Service) isPublic(path string) bool {
+	for _, public := range s.public {
+		if path == public || (strings.HasSuffix(public, "/") && strings.HasPrefix(path, public)) {
+			return true
+		}
+	}
+	return false
+}
+
+// LoginURL returns the login page which redirects to the given path after the login
+func LoginURL(next string) string {
+	if next == "" || next == "/" {
+		return LoginPath
+	}
+	return LoginPath + "?next=" + url.QueryEscape(next)
+}
+
+// RedirectTarget returns the local path to continue with after the login, other targets are replaced by the start page
+func RedirectTarget(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
+
+// wantsHTML distinguishes the browsers from the API clients
+func wantsHTML(r *http.Request) bool {
+	if r.Header.Get("HX-Request") != "" || strings.HasPrefix(r.URL.Path, "/cronlogger/api/") {
+		return false
+	}
+	return r.Method == http.MethodGet && strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+func clientAddr(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		return forwarded
+	}
+	return r.RemoteAddr
+}
@@ -0,0 +1,253 @@
+package auth
+
+import (
+	"context"
+	"cronlogger"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// the single sign-on uses the authorization code flow with PKCE. The state, the nonce and
+// the PKCE verifier of a login are kept in a short-lived signed cookie until the provider
+// redirects the browser back to the callback. After the callback the user has a regular session.
+
+const (
+	// OIDCLoginPath starts the login at the provider
+	OIDCLoginPath = "/cronlogger/oidc/login"
+	// OIDCCallbackPath is the redirect target of the provider
+	OIDCCallbackPath = "/cronlogger/oidc/callback"
+
+	oidcFlowCookie = "cronlogger_oidc"
+	oidcFlowTTL    = 10 * time.Minute
+)
+
+// ErrNotAllowed is returned if a user of the provider is not allowed to log in
+var ErrNotAllowed = errors.New("the user is not allowed to log in")
+
+// OIDC implements the login via an OpenID Connect provider
+type OIDC struct {
+	config    cronlogger.OIDCConfig
+	oauth2    oauth2.Config
+	verifier  *oidc.IDTokenVerifier
+	sessions  *Sessions
+	logoutURL string
+}
+
+// oidcFlow is the state of a login between the redirect to the provider and the callback
+type oidcFlow struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	Next     string `json:"next"`
+}
+
+// NewOIDC discovers the endpoints of the provider
+func NewOIDC(ctx context.Context, config cronlogger.OIDCConfig, sessions *Sessions) (*OIDC, error) {
+	if config.ClientID == "" || config.RedirectURL == "" {
+		return nil, fmt.Errorf("the OIDC login needs a clientID and a redirectURL")
+	}
+	provider, err := oidc.NewProvider(ctx, config.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("could not discover the OIDC provider '%s'; %v", config.Issuer, err)
+	}
+	var discovery struct {
+		EndSessionEndpoint string `json:"end_session_endpoint"`
+	}
+	if err := provider.Claims(&discovery); err != nil {
+		return nil, fmt.Errorf("could not read the configuration of the OIDC provider; %v", err)
+	}
+
+	scopes := config.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"profile", "email"}
+	}
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = "groups"
+	}
+	o := &OIDC{
+		config: config,
+		oauth2: oauth2.Config{
+			ClientID:     config.ClientID,
+			ClientSecret: config.ClientSecret,
+			RedirectURL:  config.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       append([]string{oidc.ScopeOpenID}, scopes...),
+		},
+		verifier: provider.Verifier(&oidc.Config{ClientID: config.ClientID}),
+		sessions: sessions,
+	}
+	if discovery.EndSessionEndpoint != "" {
+		o.logoutURL, err = endSessionURL(discovery.EndSessionEndpoint, config)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return o, nil
+}
+
+// Name is the label of the login button
+func (o *OIDC) Name() string {
+	if o.config.Name != "" {
+		return o.config.Name
+	}
+	return "Single Sign-On"
+}
+
+// Start redirects the browser to the login of the provider, the browser returns to next after the login
+func (o *OIDC) Start(w http.ResponseWriter, r *http.Request, next string) error {
+	flow := oidcFlow{
+		State:    randomString(),
+		Nonce:    randomString(),
+		Verifier: oauth2.GenerateVerifier(),
+		Next:     RedirectTarget(next),
+	}
+	if err := o.sessions.setCookie(w, r, oidcFlowCookie, flow, o.sessions.now().Add(oidcFlowTTL)); err != nil {
+		return err
+	}
+	authURL := o.oauth2.AuthCodeURL(flow.State, oidc.Nonce(flow.Nonce), oauth2.S256ChallengeOption(flow.Verifier))
+	http.Redirect(w, r, authURL, http.StatusFound)
+	return nil
+}
+
+// Finish handles the callback of the provider: the code is exchanged for the ID token, the token
+// is verified and the session of the user is started. The target after the login is returned.
+func (o *OIDC) Finish(w http.ResponseWriter, r *http.Request) (Principal, string, error) {
+	var flow oidcFlow
+	if !o.sessions.readCookie(r, oidcFlowCookie, &flow) {
+		return Principal{}, "", fmt.Errorf("the login was not started or has expired")
+	}
+	o.sessions.clearCookie(w, r, oidcFlowCookie)
+
+	query := r.URL.Query()
+	if e := query.Get("error"); e != "" {
+		return Principal{}, "", fmt.Errorf("the provider rejected the login: %s %s", e, query.Get("error_description"))
+	}
+	if query.Get("state") == "" || query.Get("state") != flow.State {
+		return Principal{}, "", fmt.Errorf("the state of the login does not match")
+	}
+
+	token, err := o.oauth2.Exchange(r.Context(), query.Get("code"), oauth2.VerifierOption(flow.Verifier))
+	if err != nil {
+		return Principal{}, "", fmt.Errorf("could not exchange the authorization code; %v", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return Principal{}, "", fmt.Errorf("the provider did not return an ID token")
+	}
+	idToken, err := o.verifier.Verify(r.Context(), rawIDToken)
+	if err != nil {
+		return Principal{}, "", fmt.Errorf("invalid ID token; %v", err)
+	}
+	if idToken.Nonce != flow.Nonce {
+		return Principal{}, "", fmt.Errorf("the nonce of the ID token does not match")
+	}
+
+	p, err := o.principal(idToken)
+	if err != nil {
+		return Principal{}, "", err
+	}
+	if err := o.sessions.Create(w, r, p); err != nil {
+		return Principal{}, "", err
+	}
+	return p, flow.Next, nil
+}
+
+// LogoutURL returns the logout of the provider, it is empty if the provider does not support the logout
+func (o *OIDC) LogoutURL() string {
+	return o.logoutURL
+}
+
+func (o *OIDC) principal(idToken *oidc.IDToken) (Principal, error) {
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		return Principal{}, fmt.Errorf("could not read the claims of the ID token; %v", err)
+	}
+	email, _ := claims["email"].(string)
+	if verified, ok := claims["email_verified"].(bool); ok && !verified {
+		email = ""
+	}
+	groups := stringList(claims[o.config.GroupsClaim])
+
+	// the preferred username can be changed by the user, only the subject identifies the user
+	display, _ := claims["preferred_username"].(string)
+	if display == "" {
+		display = email
+	}
+	p := Principal{Name: OIDCPrefix + idToken.Subject, Display: display, Groups: groups, Method: MethodOIDC}
+	if !o.allowed(email, groups) {
+		return Principal{}, fmt.Errorf("%w: '%s' (%s)", ErrNotAllowed, p.Name, display)
+	}
+	return p, nil
+}
+
+func (o *OIDC) allowed(email string, groups []string) bool {
+	if len(o.config.AllowedGroups) == 0 && len(o.config.AllowedEmails) == 0 {
+		return true
+	}
+	for _, group := range groups {
+		if slices.Contains(o.config.AllowedGroups, group) {
+			return true
+		}
+	}
+	if email == "" {
+		return false
+	}
+	email = strings.ToLower(email)
+	for _, allowed := range o.config.AllowedEmails {
+		allowed = strings.ToLower(allowed)
+		if email == allowed || (strings.HasPrefix(allowed, "@") && strings.HasSuffix(email, allowed)) {
+			return true
+		}
+	}
+	return false
+}
+
+// endSessionURL returns the logout of the provider which redirects to the login page of the server
+func endSessionURL(endpoint string, config cronlogger.OIDCConfig) (string, error) {
+	logout, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid end_session_endpoint of the OIDC provider; %v", err)
+	}
+	redirect, err := url.Parse(config.RedirectURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid redirectURL '%s'; %v", config.RedirectURL, err)
+	}
+	query := logout.Query()
+	query.Set("client_id", config.ClientID)
+	query.Set("post_logout_redirect_uri", redirect.ResolveReference(&url.URL{Path: LoginPath}).String())
+	logout.RawQuery = query.Encode()
+	return logout.String(), nil
+}
+
+// stringList reads a claim which is either a list of strings or a single string
+func stringList(claim any) []string {
+	switch v := claim.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		var list []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+func randomString() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
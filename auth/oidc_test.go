@@ -0,0 +1,284 @@
+package auth_test
+
+import (
+	"cronlogger"
+	"cronlogger/auth"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeProvider is a minimal OpenID Connect provider which logs in the configured user without interaction
+type fakeProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	claims map[string]any
+	// wrongNonce returns an ID token with a nonce of another login
+	wrongNonce bool
+
+	mu     sync.Mutex
+	grants map[string]grant
+}
+
+type grant struct {
+	challenge   string
+	nonce       string
+	redirectURI string
+}
+
+func newFakeProvider(t *testing.T, claims map[string]any) *fakeProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("could not create the key of the provider; %v", err)
+	}
+	p := &fakeProvider{key: key, claims: claims, grants: map[string]grant{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("GET /keys", p.keys)
+	mux.HandleFunc("GET /authorize", p.authorize)
+	mux.HandleFunc("POST /token", p.token)
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func (p *fakeProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	issuer := p.server.URL
+	json.NewEncoder(w).Encode(map[string]any{
+		"issuer":                                issuer,
+		"authorization_endpoint":                issuer + "/authorize",
+		"token_endpoint":                        issuer + "/token",
+		"jwks_uri":                              issuer + "/keys",
+		"end_session_endpoint":                  issuer + "/logout",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (p *fakeProvider) keys(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": "test",
+		"alg": "RS256",
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+	}}})
+}
+
+func (p *fakeProvider) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		http.Error(w, "PKCE is required", http.StatusBadRequest)
+		return
+	}
+	code := base64.RawURLEncoding.EncodeToString([]byte(time.Now().String()))
+	p.mu.Lock()
+	p.grants[code] = grant{challenge: query.Get("code_challenge"), nonce: query.Get("nonce"), redirectURI: query.Get("redirect_uri")}
+	p.mu.Unlock()
+
+	redirect, _ := url.Parse(query.Get("redirect_uri"))
+	params := redirect.Query()
+	params.Set("code", code)
+	params.Set("state", query.Get("state"))
+	redirect.RawQuery = params.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (p *fakeProvider) token(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	g, ok := p.grants[r.PostFormValue("code")]
+	delete(p.grants, r.PostFormValue("code"))
+	p.mu.Unlock()
+	verifier := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(verifier[:]) != g.challenge || r.PostFormValue("redirect_uri") != g.redirectURI {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+	clientID, _, _ := r.BasicAuth()
+
+	claims := map[string]any{
+		"iss":   p.server.URL,
+		"aud":   clientID,
+		"sub":   "user-1",
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"nonce": g.nonce,
+	}
+	if p.wrongNonce {
+		claims["nonce"] = "other"
+	}
+	for k, v := range p.claims {
+		claims[k] = v
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     p.sign(claims),
+	})
+}
+
+func (p *fakeProvider) sign(claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(input))
+	signature, _ := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, digest[:])
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// getOIDCServer starts a server with the routes of the OIDC login in front of a protected page
+func getOIDCServer(t *testing.T, provider *fakeProvider, config cronlogger.OIDCConfig) (*httptest.Server, *auth.Service) {
+	var s *auth.Service
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+auth.OIDCLoginPath, func(w http.ResponseWriter, r *http.Request) {
+		if err := s.OIDC().Start(w, r, r.URL.Query().Get("next")); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+	mux.HandleFunc("GET "+auth.OIDCCallbackPath, func(w http.ResponseWriter, r *http.Request) {
+		_, next, err := s.OIDC().Finish(w, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		http.Redirect(w, r, next, http.StatusSeeOther)
+	})
+	mux.Handle("/", principalHandler)
+	server := httptest.NewServer(nil)
+	t.Cleanup(server.Close)
+
+	config.Issuer = provider.server.URL
+	config.ClientID = "cronlogger"
+	config.ClientSecret = "secret"
+	config.RedirectURL = server.URL + auth.OIDCCallbackPath
+	var err error
+	s, err = auth.New(cronlogger.AuthConfig{OIDC: config}, logger)
+	if err != nil {
+		t.Fatalf("could not create the authentication; %v", err)
+	}
+	server.Config.Handler = s.Middleware(mux)
+	return server, s
+}
+
+func login(t *testing.T, server *httptest.Server) (*http.Client, *http.Response) {
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar}
+	resp, err := client.Get(server.URL + auth.OIDCLoginPath + "?next=/cronlogger/App/acme-tls")
+	if err != nil {
+		t.Fatalf("could not log in; %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return client, resp
+}
+
+func body(t *testing.T, resp *http.Response) string {
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("could not read the response; %v", err)
+	}
+	return string(b)
+}
+
+func Test_OIDC_Login(t *testing.T) {
+	provider := newFakeProvider(t, map[string]any{"preferred_username": "alice", "email": "alice@example.com", "groups": []string{"ops"}})
+	server, s := getOIDCServer(t, provider, cronlogger.OIDCConfig{AllowedGroups: []string{"ops"}})
+	if !s.Enabled() || s.PasswordLogin() {
+		t.Errorf("expected only the OIDC login to be enabled")
+	}
+
+	client, resp := login(t, server)
+	if resp.StatusCode != http.StatusOK || resp.Request.URL.Path != "/cronlogger/App/acme-tls" {
+		t.Fatalf("expected to be redirected to the requested page, got %d %s", resp.StatusCode, resp.Request.URL)
+	}
+	if b := body(t, resp); b != "oidc:user-1/oidc" {
+		t.Errorf("unexpected principal %s", b)
+	}
+
+	// the session is used for the following requests
+	resp, err := client.Get(server.URL + "/cronlogger/StartPage")
+	if err != nil {
+		t.Fatalf("request failed; %v", err)
+	}
+	defer resp.Body.Close()
+	if b := body(t, resp); b != "oidc:user-1/oidc" {
+		t.Errorf("the session was not used: %s", b)
+	}
+
+	// the logout of the provider returns to the login page
+	r := httptest.NewRequest("POST", "/cronlogger/Logout", nil)
+	logout := s.Logout(httptest.NewRecorder(), r.WithContext(auth.WithPrincipal(r.Context(), auth.Principal{Name: "alice", Method: auth.MethodOIDC})))
+	if !strings.HasPrefix(logout, provider.server.URL+"/logout?") || !strings.Contains(logout, url.QueryEscape(server.URL+auth.LoginPath)) {
+		t.Errorf("unexpected logout of the provider %s", logout)
+	}
+}
+
+func Test_OIDC_NotAllowed(t *testing.T) {
+	claims := map[string]any{"preferred_username": "bob", "email": "bob@example.com", "groups": []string{"dev"}}
+	for name, config := range map[string]cronlogger.OIDCConfig{
+		"group": {AllowedGroups: []string{"ops"}},
+		"email": {AllowedEmails: []string{"alice@example.com", "@example.org"}},
+	} {
+		server, _ := getOIDCServer(t, newFakeProvider(t, claims), config)
+		if _, resp := login(t, server); resp.StatusCode != http.StatusForbidden {
+			t.Errorf("%s: expected the login to be rejected, got %d", name, resp.StatusCode)
+		}
+	}
+
+	// a whole domain is allowed, the email needs to be verified
+	server, _ := getOIDCServer(t, newFakeProvider(t, claims), cronlogger.OIDCConfig{AllowedEmails: []string{"@Example.com"}})
+	if _, resp := login(t, server); resp.StatusCode != http.StatusOK {
+		t.Errorf("expected the domain to be allowed, got %d", resp.StatusCode)
+	}
+	claims["email_verified"] = false
+	server, _ = getOIDCServer(t, newFakeProvider(t, claims), cronlogger.OIDCConfig{AllowedEmails: []string{"@example.com"}})
+	if _, resp := login(t, server); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected an unverified email to be rejected, got %d", resp.StatusCode)
+	}
+}
+
+func Test_OIDC_InvalidCallback(t *testing.T) {
+	provider := newFakeProvider(t, map[string]any{"preferred_username": "alice"})
+	server, _ := getOIDCServer(t, provider, cronlogger.OIDCConfig{})
+
+	// a callback without a started login
+	resp, err := http.Get(server.URL + auth.OIDCCallbackPath + "?code=abc&state=xyz")
+	if err != nil {
+		t.Fatalf("request failed; %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected a callback without login to be rejected, got %d", resp.StatusCode)
+	}
+
+	// a replayed ID token of another login
+	provider.wrongNonce = true
+	if _, resp := login(t, server); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected a wrong nonce to be rejected, got %d", resp.StatusCode)
+	}
+}
+
+func Test_OIDC_UnknownProvider(t *testing.T) {
+	provider := newFakeProvider(t, nil)
+	provider.server.Close()
+	_, err := auth.New(cronlogger.AuthConfig{OIDC: cronlogger.OIDCConfig{Issuer: provider.server.URL, ClientID: "cronlogger", RedirectURL: "http://localhost/cb"}}, logger)
+	if err == nil {
+		t.Errorf("expected an error for an unavailable provider")
+	}
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"cronlogger/store"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// the tokens of the token store are limited by scopes of the form <action>:<applications>,
+// e.g. "ingest:rclone-*" or "read:*". A token with scopes is restricted to the API: the
+// read scopes replace the access rules, the ingest scopes allow to store executions.
+
+// the actions of the scopes
+const (
+	ScopeRead   = "read"
+	ScopeIngest = "ingest"
+)
+
+// apiPrefix is the path of the API routes, the only routes available to tokens with scopes
+const apiPrefix = "/cronlogger/api/"
+
+// ParseScopes validates the scopes and removes duplicates
+func ParseScopes(scopes []string) ([]string, error) {
+	parsed := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		action, apps, ok := strings.Cut(scope, ":")
+		if !ok || apps == "" || strings.ContainsAny(apps, " \t") {
+			return nil, fmt.Errorf("invalid scope '%s', expected <action>:<applications>, e.g. ingest:rclone-*", scope)
+		}
+		if action != ScopeRead && action != ScopeIngest {
+			return nil, fmt.Errorf("unknown action of the scope '%s', expected read or ingest", scope)
+		}
+		if !slices.Contains(parsed, scope) {
+			parsed = append(parsed, scope)
+		}
+	}
+	if len(parsed) == 0 {
+		return nil, fmt.Errorf("at least one scope is needed")
+	}
+	return parsed, nil
+}
+
+// scopeApps returns the application patterns of the action, nil if the scopes include all applications
+func scopeApps(scopes []string, action string) []string {
+	patterns := []string{}
+	for _, scope := range scopes {
+		a, apps, _ := strings.Cut(scope, ":")
+		if a != action {
+			continue
+		}
+		if apps == store.AllApps {
+			return nil
+		}
+		patterns = append(patterns, apps)
+	}
+	return patterns
+}
+
+// scopeAllows reports whether the scopes allow the action on the application
+func scopeAllows(scopes []string, action, app string) bool {
+	patterns := scopeApps(scopes, action)
+	if patterns == nil {
+		return true
+	}
+	for _, pattern := range patterns {
+		if store.MatchApp(pattern, app) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkScopes rejects the requests of a token with scopes outside of its scopes. Reading requests
+// need a read scope, the other requests an ingest scope; the applications are checked by the handlers.
+func checkScopes(p Principal, r *http.Request) error {
+	if !p.Scoped() {
+		return nil
+	}
+	if !strings.HasPrefix(r.URL.Path, apiPrefix) {
+		return fmt.Errorf("the token '%s' may only access the API", p.Name)
+	}
+	action := ScopeIngest
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		action = ScopeRead
+	}
+	if patterns := scopeApps(p.Scopes, action); patterns != nil && len(patterns) == 0 {
+		return fmt.Errorf("the token '%s' has no %s scope", p.Name, action)
+	}
+	return nil
+}
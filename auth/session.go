@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SessionCookie is the name of the session cookie
+const SessionCookie = "cronlogger_session"
+
+// Sessions keeps the principal of a browser in a signed cookie, the server does not store the sessions.
+// A logout revokes the ID of the session until it expires, a copy of the cookie is therefore rejected as
+// well. The revocations are kept in memory: they are lost by a restart and not shared by several servers
+// with the same session key. The groups of the principal are the groups at the time of the login.
+type Sessions struct {
+	key []byte
+	ttl time.Duration
+	now func() time.Time
+
+	mu sync.Mutex
+	// revoked maps the IDs of the ended sessions to their expiry in unix seconds
+	revoked map[string]int64
+}
+
+type session struct {
+	Principal
+	ID      string `json:"sid"`
+	Expires int64  `json:"exp"`
+}
+
+// NewSessions creates the sessions signed by the key, a random key is created if the key is empty
+func NewSessions(key []byte, ttl time.Duration) (*Sessions, error) {
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("could not create a session key; %v", err)
+		}
+	} else if len(key) < 16 {
+		return nil, fmt.Errorf("the session key needs at least 16 characters")
+	}
+	return &Sessions{key: key, ttl: ttl, now: time.Now, revoked: map[string]int64{}}, nil
+}
+
+// Create starts the session of the principal
+func (s *Sessions) Create(w http.ResponseWriter, r *http.Request, p Principal) error {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Errorf("could not create a session ID; %v", err)
+	}
+	expires := s.now().Add(s.ttl)
+	return s.setCookie(w, r, SessionCookie, session{Principal: p, ID: base64.RawURLEncoding.EncodeToString(id), Expires: expires.Unix()}, expires)
+}
+
+// Clear ends the session of the browser and revokes it until it expires
+func (s *Sessions) Clear(w http.ResponseWriter, r *http.Request) {
+	var sess session
+	if s.readCookie(r, SessionCookie, &sess) && sess.ID != "" {
+		s.revoke(sess.ID, sess.Expires)
+	}
+	s.clearCookie(w, r, SessionCookie)
+}
+
+// Authenticate reads the principal of the session cookie
+func (s *Sessions) Authenticate(r *http.Request) (Principal, error) {
+	var sess session
+	// e.g. a session of a previous server start with a random key is not valid anymore
+	if !s.readCookie(r, SessionCookie, &sess) || s.now().Unix() >= sess.Expires || s.isRevoked(sess.ID) {
+		return Principal{}, ErrNoCredentials
+	}
+	return sess.Principal, nil
+}
+
+// revoke records the ended session, the expired sessions are removed at the same time
+func (s *Sessions) revoke(id string, expires int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now().Unix()
+	for revoked, exp := range s.revoked {
+		if now >= exp {
+			delete(s.revoked, revoked)
+		}
+	}
+	s.revoked[id] = expires
+}
+
+func (s *Sessions) isRevoked(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.revoked[id]
+	return ok
+}
+
+// setCookie stores the value as signed JSON in the cookie
+func (s *Sessions) setCookie(w http.ResponseWriter, r *http.Request, name string, v any, expires time.Time) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("could not create the cookie '%s'; %v", name, err)
+	}
+	value := base64.RawURLEncoding.EncodeToString(payload)
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value + "." + s.sign(name, value),
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		// the cookie is not sent with cross-site form posts
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// readCookie reads the signed value of the cookie, false is returned for missing or invalid cookies
+func (s *Sessions) readCookie(r *http.Request, name string, v any) bool {
+	cookie, err := r.Cookie(name)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+	value, signature, ok := strings.Cut(cookie.Value, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(s.sign(name, value))) {
+		return false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(payload, v) == nil
+}
+
+func (s *Sessions) clearCookie(w http.ResponseWriter, r *http.Request, name string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// sign includes the name of the cookie, the value of a cookie is not valid for another cookie
+func (s *Sessions) sign(name, value string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(name + "=" + value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"context"
+	"cronlogger/store"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// tokenPrefix marks the tokens of the token store, e.g. for secret scanners
+	tokenPrefix = "clg_"
+	// the last use of a token is only recorded once per interval, not for every request
+	lastUsedInterval = time.Minute
+)
+
+// ManagedTokens authenticates the bearer tokens of the token store. In contrast to the
+// static tokens of the configuration the tokens are created and revoked via the admin page
+// and are limited by scopes.
+type ManagedTokens struct {
+	store  store.TokenStore
+	logger *slog.Logger
+	now    func() time.Time
+}
+
+// NewManagedTokens creates the tokens of the store
+func NewManagedTokens(s store.TokenStore, logger *slog.Logger) *ManagedTokens {
+	return &ManagedTokens{store: s, logger: logger, now: time.Now}
+}
+
+// Create creates a token with the scopes, a ttl of 0 creates a token which does not expire.
+// Only the hash of the token is stored, the returned token cannot be retrieved again.
+func (m *ManagedTokens) Create(ctx context.Context, name string, scopes []string, ttl time.Duration, createdBy string) (string, store.APITokenEntity, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", store.APITokenEntity{}, fmt.Errorf("the token needs a name")
+	}
+	if ttl < 0 {
+		return "", store.APITokenEntity{}, fmt.Errorf("the lifetime of the token needs to be positive")
+	}
+	scopes, err := ParseScopes(scopes)
+	if err != nil {
+		return "", store.APITokenEntity{}, err
+	}
+
+	secret := tokenPrefix + randomString()
+	entity := store.APITokenEntity{
+		Name:      name,
+		Hash:      hashToken(secret),
+		Scopes:    strings.Join(scopes, " "),
+		CreatedBy: createdBy,
+	}
+	if ttl > 0 {
+		expires := m.now().Add(ttl)
+		entity.Expires = &expires
+	}
+	entity, err = m.store.CreateToken(ctx, entity)
+	if err != nil {
+		return "", store.APITokenEntity{}, err
+	}
+	return secret, entity, nil
+}
+
+// List returns all tokens, the latest token first
+func (m *ManagedTokens) List(ctx context.Context) ([]store.APITokenEntity, error) {
+	return m.store.GetTokens(ctx)
+}
+
+// Revoke deletes the token, the token cannot be used anymore
+func (m *ManagedTokens) Revoke(ctx context.Context, id string) error {
+	return m.store.DeleteToken(ctx, id)
+}
+
+// Authenticate checks the bearer token of the Authorization header. Unknown tokens are
+// left to the following authenticators.
+func (m *ManagedTokens) Authenticate(r *http.Request) (Principal, error) {
+	secret, ok := BearerToken(r)
+	if !ok || !strings.HasPrefix(secret, tokenPrefix) {
+		return Principal{}, ErrNoCredentials
+	}
+	token, err := m.store.GetTokenByHash(r.Context(), hashToken(secret))
+	if errors.Is(err, store.ErrTokenNotFound) {
+		return Principal{}, ErrNoCredentials
+	}
+	if err != nil {
+		return Principal{}, err
+	}
+
+	now := m.now()
+	if token.Expires != nil && !now.Before(*token.Expires) {
+		return Principal{}, fmt.Errorf("%w: the token '%s' has expired", ErrInvalidCredentials, token.Name)
+	}
+	if token.LastUsed == nil || now.Sub(*token.LastUsed) >= lastUsedInterval {
+		// a failed update does not reject the request
+		if err := m.store.TouchToken(context.WithoutCancel(r.Context()), token.ID, now); err != nil {
+			m.logger.Warn("could not record the use of the token", "token", token.Name, "error", err)
+		}
+	}
+	return Principal{Name: token.Name, Method: MethodToken, Scopes: strings.Fields(token.Scopes)}, nil
+}
+
+// hashToken is the hash of a token in the token store
+func hashToken(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
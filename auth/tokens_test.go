@@ -0,0 +1,148 @@
+package auth_test
+
+import (
+	"cronlogger"
+	"cronlogger/auth"
+	"cronlogger/store"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func getManagedTokens(t *testing.T) (*auth.Service, *auth.ManagedTokens, store.TokenStore) {
+	con, db, err := store.CreateConFromDsn(":memory:")
+	if err != nil {
+		t.Fatalf("cannot create database connection: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := store.Migrate(con); err != nil {
+		t.Fatalf("could not migrate the database; %v", err)
+	}
+	tokenStore := store.CreateTokenStore(con, store.Options{})
+	tokens := auth.NewManagedTokens(tokenStore, logger)
+	s := getService(t)
+	s.Use(tokens)
+	return s, tokens, tokenStore
+}
+
+func bearer(method, path, token string) *http.Request {
+	r := httptest.NewRequest(method, path, nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	return r
+}
+
+func Test_ManagedTokens(t *testing.T) {
+	s, tokens, tokenStore := getManagedTokens(t)
+	h := s.Middleware(principalHandler)
+
+	secret, created, err := tokens.Create(t.Context(), "web1", []string{"ingest:rclone-*", "read:*", "read:*"}, time.Hour, "alice")
+	if err != nil {
+		t.Fatalf("could not create the token; %v", err)
+	}
+	if created.Scopes != "ingest:rclone-* read:*" || created.Expires == nil || created.CreatedBy != "alice" || strings.Contains(created.Hash, secret) {
+		t.Errorf("unexpected token %+v", created)
+	}
+
+	rec := serve(h, bearer("GET", "/cronlogger/api/runs", secret))
+	if rec.Code != http.StatusOK || rec.Body.String() != "web1/token" {
+		t.Errorf("unexpected response %d %s", rec.Code, rec.Body.String())
+	}
+	list, err := tokens.List(t.Context())
+	if err != nil || len(list) != 1 || list[0].LastUsed == nil {
+		t.Errorf("expected the use of the token to be recorded, got %+v; %v", list, err)
+	}
+
+	// the static tokens of the configuration are still accepted
+	if rec := serve(h, bearer("GET", "/cronlogger/api/runs", token)); rec.Code != http.StatusOK {
+		t.Errorf("expected the static token to be accepted, got %d", rec.Code)
+	}
+
+	if err := tokens.Revoke(t.Context(), created.ID); err != nil {
+		t.Fatalf("could not revoke the token; %v", err)
+	}
+	if rec := serve(h, bearer("GET", "/cronlogger/api/runs", secret)); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected a revoked token to be rejected, got %d", rec.Code)
+	}
+
+	// an expired token
+	expired := "clg_expired-token-0123456789"
+	hash := sha256.Sum256([]byte(expired))
+	past := time.Now().Add(-time.Minute)
+	if _, err := tokenStore.CreateToken(t.Context(), store.APITokenEntity{Name: "old", Hash: hex.EncodeToString(hash[:]), Scopes: "read:*", Expires: &past}); err != nil {
+		t.Fatalf("could not create the token; %v", err)
+	}
+	if rec := serve(h, bearer("GET", "/cronlogger/api/runs", expired)); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected an expired token to be rejected, got %d", rec.Code)
+	}
+
+	for name, scopes := range map[string][]string{
+		"none":    nil,
+		"action":  {"write:*"},
+		"apps":    {"read:"},
+		"missing": {"read"},
+	} {
+		if _, _, err := tokens.Create(t.Context(), "web2", scopes, 0, "alice"); err == nil {
+			t.Errorf("%s: expected an error for invalid scopes", name)
+		}
+	}
+}
+
+func Test_Scopes(t *testing.T) {
+	s, tokens, _ := getManagedTokens(t)
+	h := s.Middleware(principalHandler)
+	ingest, _, err := tokens.Create(t.Context(), "web1", []string{"ingest:rclone-*"}, 0, "alice")
+	if err != nil {
+		t.Fatalf("could not create the token; %v", err)
+	}
+	read, _, err := tokens.Create(t.Context(), "monitoring", []string{"read:acme-*"}, 0, "alice")
+	if err != nil {
+		t.Fatalf("could not create the token; %v", err)
+	}
+
+	for name, tc := range map[string]struct {
+		r    *http.Request
+		code int
+	}{
+		"ingest":          {bearer("POST", "/cronlogger/api/runs", ingest), http.StatusOK},
+		"ingest reads":    {bearer("GET", "/cronlogger/api/runs", ingest), http.StatusForbidden},
+		"read":            {bearer("GET", "/cronlogger/api/export/csv", read), http.StatusOK},
+		"read ingests":    {bearer("POST", "/cronlogger/api/runs", read), http.StatusForbidden},
+		"read web UI":     {bearer("GET", "/cronlogger/StartPage", read), http.StatusForbidden},
+		"ingest admin":    {bearer("POST", "/cronlogger/Admin/Tokens", ingest), http.StatusForbidden},
+		"unscoped web UI": {bearer("GET", "/cronlogger/StartPage", token), http.StatusOK},
+	} {
+		if rec := serve(h, tc.r); rec.Code != tc.code {
+			t.Errorf("%s: expected %d, got %d %s", name, tc.code, rec.Code, rec.Body.String())
+		}
+	}
+
+	// the scopes replace the access rules
+	ctx := auth.WithPrincipal(t.Context(), auth.Principal{Name: "web1", Method: auth.MethodToken, Scopes: []string{"ingest:rclone-*", "read:acme-*"}})
+	if !s.Ingest(ctx, "rclone-photos") || s.Ingest(ctx, "acme-tls") {
+		t.Errorf("unexpected ingest scopes")
+	}
+	if !s.Allowed(ctx, auth.RoleRead, "acme-tls") || s.Allowed(ctx, auth.RoleRead, "rclone-photos") || s.Allowed(ctx, auth.RoleTrigger, "acme-tls") || s.Admin(ctx) {
+		t.Errorf("unexpected roles of the scopes")
+	}
+	if apps, ok := store.AppsOf(s.ScopeApps(ctx)); !ok || !slices.Equal(apps, []string{"acme-*"}) {
+		t.Errorf("unexpected applications of the scopes %v", apps)
+	}
+
+	// without scopes the trigger role allows to ingest
+	ctx = auth.WithPrincipal(t.Context(), auth.Principal{Name: "alice", Method: auth.MethodBasic})
+	if !s.Ingest(ctx, "rclone-photos") {
+		t.Errorf("expected a user without access rules to ingest")
+	}
+	restricted, err := auth.New(cronlogger.AuthConfig{Tokens: []cronlogger.APIToken{{Name: "web1", Token: token}}, Access: rules}, logger)
+	if err != nil {
+		t.Fatalf("could not create the authentication; %v", err)
+	}
+	if restricted.Ingest(auth.WithPrincipal(t.Context(), auth.Principal{Name: "bob"}), "acme-tls") {
+		t.Errorf("expected the read role not to ingest")
+	}
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"cronlogger"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// dummyHash is compared for unknown users, the response time does not reveal whether a user exists
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("cronlogger"), bcrypt.DefaultCost)
+
+// HashPassword creates the bcrypt hash of a password for the configuration of a user
+func HashPassword(password string) (string, error) {
+	if password == "" {
+		return "", fmt.Errorf("the password must not be empty")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("could not hash the password; %v", err)
+	}
+	return string(hash), nil
+}
+
+// Users verifies the passwords of the configured users, it authenticates requests with HTTP basic auth
+type Users struct {
+	users map[string]cronlogger.User
+}
+
+// NewUsers validates the configured users and their password hashes
+func NewUsers(users []cronlogger.User) (*Users, error) {
+	u := &Users{users: make(map[string]cronlogger.User, len(users))}
+	for _, user := range users {
+		if user.Name == "" {
+			return nil, fmt.Errorf("a user needs a name")
+		}
+		if _, ok := u.users[user.Name]; ok {
+			return nil, fmt.Errorf("the user '%s' is defined twice", user.Name)
+		}
+		if _, err := bcrypt.Cost([]byte(user.Password)); err != nil {
+			return nil, fmt.Errorf("the password of user '%s' is no bcrypt hash; %v", user.Name, err)
+		}
+		u.users[user.Name] = user
+	}
+	return u, nil
+}
+
+// Len returns the number of users
+func (u *Users) Len() int {
+	return len(u.users)
+}
+
+// Verify checks the password of a user
+func (u *Users) Verify(name, password string) (Principal, error) {
+	user, ok := u.users[name]
+	if !ok {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return Principal{}, ErrInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return Principal{}, ErrInvalidCredentials
+	}
+	return Principal{Name: user.Name, Groups: user.Groups}, nil
+}
+
+// Authenticate implements the HTTP basic auth
+func (u *Users) Authenticate(r *http.Request) (Principal, error) {
+	name, password, ok := r.BasicAuth()
+	if !ok {
+		return Principal{}, ErrNoCredentials
+	}
+	p, err := u.Verify(name, password)
+	if err != nil {
+		return Principal{}, err
+	}
+	p.Method = MethodBasic
+	return p, nil
+}
+
+// Tokens authenticates machine clients with static bearer tokens
+type Tokens struct {
+	// the tokens are looked up by their hash, the timing of the lookup does not reveal the tokens
+	tokens map[[sha256.Size]byte]string
+}
+
+// NewTokens validates the configured tokens
+func NewTokens(tokens []cronlogger.APIToken) (*Tokens, error) {
+	t := &Tokens{tokens: make(map[[sha256.Size]byte]string, len(tokens))}
+	for _, token := range tokens {
+		if token.Name == "" {
+			return nil, fmt.Errorf("an API token needs a name")
+		}
+		if len(token.Token) < 16 {
+			return nil, fmt.Errorf("the API token '%s' needs at least 16 characters", token.Name)
+		}
+		hash := sha256.Sum256([]byte(token.Token))
+		if _, ok := t.tokens[hash]; ok {
+			return nil, fmt.Errorf("the API token '%s' is defined twice", token.Name)
+		}
+		t.tokens[hash] = token.Name
+	}
+	return t, nil
+}
+
+// Authenticate checks the bearer token of the Authorization header. Unknown tokens are
+// left to the following authenticators.
+func (t *Tokens) Authenticate(r *http.Request) (Principal, error) {
+	token, ok := BearerToken(r)
+	if !ok {
+		return Principal{}, ErrNoCredentials
+	}
+	name, ok := t.tokens[sha256.Sum256([]byte(token))]
+	if !ok {
+		return Principal{}, ErrNoCredentials
+	}
+	return Principal{Name: name, Method: MethodToken}, nil
+}
+
+// BearerToken returns the token of the Authorization header
+func BearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
@@ -0,0 +1,245 @@
+package backup
+
+import (
+	"context"
+	"cronlogger"
+	"cronlogger/store"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// the backups are consistent online snapshots of the sqlite database. A snapshot is
+// written to a temporary file, verified with an integrity check and then renamed, the
+// directory therefore only contains complete snapshots. After a snapshot the older
+// snapshots are rotated by count and age.
+
+const (
+	filePrefix = "cronlog-backup-"
+	fileSuffix = ".db"
+	timeFormat = "20060102T150405.000Z"
+)
+
+// Snapshot is a backup file of the database
+type Snapshot struct {
+	Path    string
+	Created time.Time
+	Size    int64
+}
+
+// Status describes the configuration and the outcome of the backups
+type Status struct {
+	Dir      string
+	Schedule string
+	Keep     int
+	MaxAge   time.Duration
+	// LastRun is the time of the last backup attempt of this process
+	LastRun time.Time
+	// LastError is the error of the last backup attempt, empty if it succeeded
+	LastError string
+	// Snapshots are the available snapshots, the latest first
+	Snapshots []Snapshot
+}
+
+// Last returns the latest snapshot
+func (s Status) Last() (Snapshot, bool) {
+	if len(s.Snapshots) == 0 {
+		return Snapshot{}, false
+	}
+	return s.Snapshots[0], true
+}
+
+// Manager creates the snapshots on demand and according to the schedule
+type Manager struct {
+	con    store.Connection
+	logger *slog.Logger
+	config cronlogger.BackupConfig
+	cron   *cron.Cron
+	// run serializes the backups
+	run     sync.Mutex
+	mu      sync.Mutex
+	lastRun time.Time
+	lastErr error
+}
+
+// New validates the configuration and creates the manager of the backups
+func New(con store.Connection, logger *slog.Logger, config cronlogger.BackupConfig) (*Manager, error) {
+	if config.Dir == "" {
+		return nil, fmt.Errorf("no backup directory defined")
+	}
+	if config.Keep < 0 || config.MaxAge < 0 {
+		return nil, fmt.Errorf("the rotation of the backups needs positive values")
+	}
+	if err := os.MkdirAll(config.Dir, 0o750); err != nil {
+		return nil, fmt.Errorf("could not create the backup directory; %v", err)
+	}
+
+	m := &Manager{
+		con:    con,
+		logger: logger,
+		config: config,
+		cron:   cron.New(),
+	}
+	if config.Schedule != "" {
+		if _, err := m.cron.AddFunc(config.Schedule, m.scheduled); err != nil {
+			return nil, fmt.Errorf("invalid backup schedule '%s'; %v", config.Schedule, err)
+		}
+	}
+	return m, nil
+}
+
+// Start begins the scheduled backups
+func (m *Manager) Start() {
+	m.cron.Start()
+}
+
+// Stop ends the scheduled backups and waits for a running backup
+func (m *Manager) Stop() {
+	<-m.cron.Stop().Done()
+}
+
+func (m *Manager) scheduled() {
+	if _, err := m.Backup(context.Background()); err != nil {
+		m.logger.Error("the scheduled backup failed", "error", err)
+	}
+}
+
+// Backup creates a verified snapshot of the database and rotates the older snapshots
+func (m *Manager) Backup(ctx context.Context) (Snapshot, error) {
+	m.run.Lock()
+	defer m.run.Unlock()
+
+	started := time.Now()
+	snapshot, err := m.backup(ctx, started)
+
+	m.mu.Lock()
+	m.lastRun = started
+	m.lastErr = err
+	m.mu.Unlock()
+	if err != nil {
+		return Snapshot{}, err
+	}
+	m.logger.Info("created the backup", "file", snapshot.Path, "bytes", snapshot.Size, "duration", time.Since(started).Round(time.Millisecond))
+
+	removed, err := Rotate(m.config.Dir, m.config.Keep, m.config.MaxAge, started)
+	for _, path := range removed {
+		m.logger.Info("removed the backup", "file", path)
+	}
+	if err != nil {
+		m.logger.Warn("could not rotate the backups", "error", err)
+	}
+	return snapshot, nil
+}
+
+func (m *Manager) backup(ctx context.Context, now time.Time) (Snapshot, error) {
+	path := filepath.Join(m.config.Dir, fileName(now))
+	if _, err := os.Stat(path); err == nil {
+		return Snapshot{}, fmt.Errorf("the backup '%s' already exists", path)
+	}
+	// the snapshot is only visible as backup once it is complete and verified
+	tmp := filepath.Join(m.config.Dir, "."+fileName(now)+".tmp")
+	defer os.Remove(tmp)
+
+	if err := store.SnapshotSqlite(ctx, m.con, tmp); err != nil {
+		return Snapshot{}, err
+	}
+	if err := store.VerifySqliteFile(ctx, tmp); err != nil {
+		return Snapshot{}, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return Snapshot{}, fmt.Errorf("could not store the backup; %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("could not read the backup; %v", err)
+	}
+	return Snapshot{Path: path, Created: now.UTC().Truncate(time.Millisecond), Size: info.Size()}, nil
+}
+
+// Status returns the configuration, the outcome of the last backup and the available snapshots
+func (m *Manager) Status() (Status, error) {
+	snapshots, err := List(m.config.Dir)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	status := Status{
+		Dir:       m.config.Dir,
+		Schedule:  m.config.Schedule,
+		Keep:      m.config.Keep,
+		MaxAge:    m.config.MaxAge,
+		LastRun:   m.lastRun,
+		Snapshots: snapshots,
+	}
+	if m.lastErr != nil {
+		status.LastError = m.lastErr.Error()
+	}
+	return status, err
+}
+
+func fileName(t time.Time) string {
+	return filePrefix + t.UTC().Format(timeFormat) + fileSuffix
+}
+
+// List returns the snapshots of the directory, the latest first
+func List(dir string) ([]Snapshot, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the backup directory; %v", err)
+	}
+	var snapshots []Snapshot
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, filePrefix) || !strings.HasSuffix(name, fileSuffix) {
+			continue
+		}
+		created, err := time.Parse(timeFormat, strings.TrimSuffix(strings.TrimPrefix(name, filePrefix), fileSuffix))
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, Snapshot{Path: filepath.Join(dir, name), Created: created, Size: info.Size()})
+	}
+	slices.SortFunc(snapshots, func(a, b Snapshot) int {
+		return b.Created.Compare(a.Created)
+	})
+	return snapshots, nil
+}
+
+// Rotate removes the snapshots exceeding the number to keep or the maximum age.
+// The latest snapshot is never removed. A value of 0 disables the respective limit.
+func Rotate(dir string, keep int, maxAge time.Duration, now time.Time) ([]string, error) {
+	snapshots, err := List(dir)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		removed []string
+		errs    []error
+	)
+	for i, snapshot := range snapshots {
+		if i == 0 {
+			continue
+		}
+		tooMany := keep > 0 && i >= keep
+		tooOld := maxAge > 0 && now.Sub(snapshot.Created) > maxAge
+		if !tooMany && !tooOld {
+			continue
+		}
+		if err := os.Remove(snapshot.Path); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		removed = append(removed, snapshot.Path)
+	}
+	return removed, errors.Join(errs...)
+}
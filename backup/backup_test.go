@@ -0,0 +1,155 @@
+package backup_test
+
+import (
+	"cronlogger"
+	"cronlogger/backup"
+	"cronlogger/store"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func getCon(t *testing.T) (store.Connection, store.OpResultStore) {
+	path := filepath.Join(t.TempDir(), "cronlog-store.db")
+	createFile(t, path, "")
+	con, db, err := store.CreateSqliteConFromDbPath(path)
+	if err != nil {
+		t.Fatalf("cannot create database connection: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := store.Migrate(con); err != nil {
+		t.Fatalf("cannot migrate the database: %v", err)
+	}
+	return con, store.CreateStore(con, store.Options{})
+}
+
+func createFile(t *testing.T, path, content string) {
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("could not create file; %v", err)
+	}
+}
+
+func Test_Backup(t *testing.T) {
+	con, s := getCon(t)
+	item, err := s.Create(t.Context(), store.OpResultEntity{App: "acme-tls", Status: store.StatusSuccess, Output: "renewed"})
+	if err != nil {
+		t.Fatalf("could not create item; %v", err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "backups")
+	m, err := backup.New(con, logger, cronlogger.BackupConfig{Dir: dir, Keep: 3})
+	if err != nil {
+		t.Fatalf("could not create the backup manager; %v", err)
+	}
+	snapshot, err := m.Backup(t.Context())
+	if err != nil {
+		t.Fatalf("could not create a backup; %v", err)
+	}
+	if snapshot.Size == 0 || filepath.Dir(snapshot.Path) != dir {
+		t.Errorf("unexpected snapshot %+v", snapshot)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("expected only the snapshot in the backup directory, got %d files", len(entries))
+	}
+
+	// the snapshot is a complete database
+	copied, db, err := store.CreateSqliteStoreFromDbPath(snapshot.Path, store.Options{})
+	if err != nil {
+		t.Fatalf("could not open the snapshot; %v", err)
+	}
+	defer db.Close()
+	restored, err := copied.GetById(t.Context(), item.ID)
+	if err != nil {
+		t.Fatalf("the item is not available in the snapshot; %v", err)
+	}
+	if restored.Output != "renewed" {
+		t.Errorf("unexpected item in the snapshot: %+v", restored)
+	}
+
+	status, err := m.Status()
+	if err != nil {
+		t.Fatalf("could not get the status; %v", err)
+	}
+	last, ok := status.Last()
+	if !ok || last.Path != snapshot.Path || status.LastError != "" || status.LastRun.IsZero() {
+		t.Errorf("unexpected status %+v", status)
+	}
+}
+
+func Test_New_InvalidConfig(t *testing.T) {
+	if _, err := backup.New(store.Connection{}, logger, cronlogger.BackupConfig{}); err == nil {
+		t.Errorf("expected an error without backup directory")
+	}
+	if _, err := backup.New(store.Connection{}, logger, cronlogger.BackupConfig{Dir: t.TempDir(), Schedule: "* *"}); err == nil {
+		t.Errorf("expected an error for an invalid schedule")
+	}
+}
+
+func Test_Rotate(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	names := []string{
+		"cronlog-backup-20250310T030000.000Z.db",
+		"cronlog-backup-20250309T030000.000Z.db",
+		"cronlog-backup-20250308T030000.000Z.db",
+		"cronlog-backup-20250301T030000.000Z.db",
+		"cronlog-backup-20250201T030000.000Z.db",
+	}
+	for _, name := range names {
+		createFile(t, filepath.Join(dir, name), "db")
+	}
+	createFile(t, filepath.Join(dir, "other.db"), "other")
+
+	snapshots, err := backup.List(dir)
+	if err != nil {
+		t.Fatalf("could not list the snapshots; %v", err)
+	}
+	if len(snapshots) != len(names) || filepath.Base(snapshots[0].Path) != names[0] {
+		t.Fatalf("unexpected snapshots %+v", snapshots)
+	}
+
+	// older than 7 days
+	removed, err := backup.Rotate(dir, 0, 7*24*time.Hour, now)
+	if err != nil {
+		t.Fatalf("could not rotate; %v", err)
+	}
+	if len(removed) != 2 {
+		t.Errorf("expected 2 removed snapshots, got %v", removed)
+	}
+	// more than 2
+	removed, err = backup.Rotate(dir, 2, 0, now)
+	if err != nil {
+		t.Fatalf("could not rotate; %v", err)
+	}
+	if len(removed) != 1 || filepath.Base(removed[0]) != names[2] {
+		t.Errorf("expected the oldest snapshot to be removed, got %v", removed)
+	}
+	// the latest snapshot is kept regardless of its age
+	if _, err := backup.Rotate(dir, 0, time.Hour, now.Add(30*24*time.Hour)); err != nil {
+		t.Fatalf("could not rotate; %v", err)
+	}
+	snapshots, _ = backup.List(dir)
+	if len(snapshots) != 1 || filepath.Base(snapshots[0].Path) != names[0] {
+		t.Errorf("expected the latest snapshot to be kept, got %+v", snapshots)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "other.db")); err != nil {
+		t.Errorf("a file which is no snapshot was removed")
+	}
+}
+
+func Test_VerifySqliteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "corrupt.db")
+	createFile(t, path, "this is no sqlite database")
+	if err := store.VerifySqliteFile(t.Context(), path); err == nil {
+		t.Errorf("expected an error for a corrupt database")
+	}
+	if err := store.VerifySqliteFile(t.Context(), filepath.Join(t.TempDir(), "missing.db")); err == nil {
+		t.Errorf("expected an error for a missing database")
+	}
+}
@@ -1,63 +1,201 @@
 package main
 
 import (
+	"context"
 	"cronlogger"
+	"cronlogger/config"
+	"cronlogger/notify"
 	"cronlogger/store"
+	"cronlogger/tracing"
 	"flag"
 	"fmt"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // reads the stdin passed on via a pipe
 // the result-code is passed via a shell variable - this is typically $?
+//
+// alternatively the command is supplied as arguments after the flags and executed
+// by the cronlogger itself (exec mode):
+// cronlogger --app=<appname> --db=<path> --timeout=30m -- <command> <args>
 func main() {
+	os.Exit(run())
+}
+
+// run returns the exit code of the logger, the deferred span and the export of the trace
+// are therefore completed before the process exits
+func run() int {
 	var (
 		exitCode int
 		appName  string
-		dbPath   string
 	)
 	flag.IntVar(&exitCode, "code", -1, "the exit-code of the command")
 	flag.StringVar(&appName, "app", "", "the name of the application")
-	flag.StringVar(&dbPath, "db", "", "the path to the db file")
+	flag.String("db", "./cronlog-store.db", "the path to the db file or a PostgreSQL URL (postgres://...)")
+	flag.Duration("timeout", 0, "exec mode: the maximum runtime of the command (e.g. 30m), 0 means no timeout")
+	flag.Duration("grace", cronlogger.DefaultGracePeriod, "exec mode: the time between SIGTERM and SIGKILL once the timeout is reached")
+	flag.String("lock", "", "exec mode: prevent overlapping runs of the application (skip|wait|kill-previous)")
+	flag.String("lockdir", "", "exec mode: the directory of the lock files, defaults to the directory of the db file")
+	flag.Int("retries", 0, "exec mode: the number of retries if the command fails or times out")
+	flag.Duration("retry-delay", 30*time.Second, "exec mode: the wait time before the first retry")
+	flag.Float64("retry-backoff", 2, "exec mode: the factor the retry-delay is multiplied with after each retry (1 = constant delay)")
+	flag.String("otlp-endpoint", "", "exec mode: the OTLP/HTTP endpoint the trace of the run is exported to (e.g. http://localhost:4318)")
+	configFile := flag.String("config", "", "the config file or its directory, defaults to $"+config.EnvFile+" or the search of application.yaml")
 	flag.Parse()
 
 	if len(os.Args[1:]) == 0 {
 		flag.Usage()
-		os.Exit(0)
+		return 0
 	}
 
 	if appName == "" {
 		fmt.Println("No application-name supplied, exiting!")
-		os.Exit(1)
+		return 1
 	}
 
-	result, err := cronlogger.ReadStdin()
+	// the settings of the flags take precedence over the settings of the server configuration,
+	// an error in a section of the server must not prevent storing the run
+	cfg, err := config.Load(*configFile, flag.CommandLine, loggerFlags, loggerSections...)
 	if err != nil {
-		fmt.Printf("Could not read from Stdin: %v, exiting!\n", err)
-		os.Exit(1)
+		fmt.Printf("%v\nexiting!\n", err)
+		return 1
 	}
-	if result != "" {
-		str, db, err := store.CreateSqliteStoreFromDbPath(dbPath)
+	// invalid notifications are not sent, the run is stored nevertheless
+	if err := cfg.Validate(config.SectionNotification); err != nil {
+		fmt.Printf("The notifications are disabled, invalid configuration:\n%v\n", err)
+		cfg.Notification.Routes = nil
+	}
+	dbPath := cfg.Database.DSN
+
+	// the run is traced in exec mode if an endpoint is defined, the trace ends with the stored result
+	ctx := context.Background()
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing, "cronlogger", "")
+	if err != nil {
+		fmt.Printf("%v, exiting!\n", err)
+		return 1
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			fmt.Printf("Could not export the trace: %v\n", err)
+		}
+	}()
+
+	var (
+		item     store.OpResultEntity
+		duration time.Duration
+	)
+	if flag.NArg() > 0 {
+		var span trace.Span
+		ctx, span = tracing.Start(tracing.FromEnv(ctx), "run "+appName, attribute.String("cronlogger.application", appName))
+		defer span.End()
+
+		policy, err := cronlogger.ParseLockPolicy(cfg.Logger.Lock)
 		if err != nil {
 			fmt.Printf("%v, exiting!\n", err)
-			os.Exit(1)
+			return 1
 		}
-		defer db.Close()
-
-		var success bool
-		if exitCode == 0 {
-			success = true
+		lockDir := cfg.Logger.LockDir
+		if lockDir == "" {
+			lockDir = filepath.Dir(dbPath)
+			if store.IsPostgresDsn(dbPath) {
+				lockDir = os.TempDir()
+			}
 		}
 
-		_, err = str.Create(store.OpResultEntity{
+		// the store sets the creation date once the finished run is saved
+		started := time.Now()
+		item = cronlogger.Run(ctx, appName, cronlogger.RunParams{
+			Exec: cronlogger.ExecParams{
+				Command: flag.Args(),
+				// the command continues the trace of the run
+				Env:         tracing.Env(ctx),
+				Timeout:     cfg.Logger.Timeout,
+				GracePeriod: cfg.Logger.Grace,
+			},
+			Retry: cronlogger.RetryParams{
+				Retries: cfg.Logger.Retries,
+				Delay:   cfg.Logger.RetryDelay,
+				Backoff: cfg.Logger.RetryBackoff,
+			},
+			Lock:     policy,
+			LockPath: cronlogger.LockPath(lockDir, appName),
+		})
+		duration = time.Since(started)
+		span.SetAttributes(attribute.String("cronlogger.status", string(item.State())), attribute.Int("cronlogger.attempts", max(1, len(item.Attempts))))
+		if item.State() != store.StatusSuccess {
+			span.SetStatus(codes.Error, string(item.State()))
+		}
+	} else {
+		result, err := cronlogger.ReadStdin()
+		if err != nil {
+			fmt.Printf("Could not read from Stdin: %v, exiting!\n", err)
+			return 1
+		}
+		if result == "" {
+			return 0
+		}
+		item = store.OpResultEntity{
 			App:     appName,
-			Success: success,
+			Success: exitCode == 0,
 			Output:  result,
-		})
+		}
+	}
 
-		if err != nil {
-			fmt.Printf("Could not save item to store: %v, exiting!\n", err)
-			os.Exit(1)
+	con, db, err := store.CreateConFromDsn(dbPath)
+	if err != nil {
+		fmt.Printf("%v, exiting!\n", err)
+		return 1
+	}
+	defer db.Close()
+	if _, err := store.Migrate(con); err != nil {
+		fmt.Printf("could not migrate the database schema: %v, exiting!\n", err)
+		return 1
+	}
+	if err := tracing.InstrumentStore(con); err != nil {
+		fmt.Printf("%v, exiting!\n", err)
+		return 1
+	}
+
+	item, err = store.CreateStore(con, store.Options{}).Create(ctx, item)
+	if err != nil {
+		fmt.Printf("Could not save item to store: %v, exiting!\n", err)
+		return 1
+	}
+
+	// the server is not involved, the logger sends the notifications of its runs itself
+	if len(cfg.Notification.Routes) > 0 {
+		msg := notify.NewMessage(item)
+		if duration > 0 {
+			msg.Duration = duration.Truncate(time.Second).String()
+		}
+		if err := notify.New(slog.Default(), cfg.Notification).Send(ctx, msg); err != nil {
+			fmt.Printf("Could not send the notification: %v\n", err)
 		}
 	}
+	return 0
+}
+
+// loggerSections are the sections of the configuration used by the logger
+var loggerSections = []config.Section{config.SectionApplications, config.SectionDatabase, config.SectionTracing, config.SectionLogger}
+
+// loggerFlags override the settings of the configuration file and the environment
+var loggerFlags = config.Flags{
+	"db":            "database.dsn",
+	"timeout":       "logger.timeout",
+	"grace":         "logger.grace",
+	"lock":          "logger.lock",
+	"lockdir":       "logger.lockDir",
+	"retries":       "logger.retries",
+	"retry-delay":   "logger.retryDelay",
+	"retry-backoff": "logger.retryBackoff",
+	"otlp-endpoint": "tracing.endpoint",
 }
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"cronlogger"
+	"cronlogger/backup"
+	"cronlogger/config"
+	"cronlogger/store"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// backupFlags override the database and the backups of the configuration file
+var backupFlags = config.Flags{
+	"db":      "database.dsn",
+	"dir":     "backup.dir",
+	"keep":    "backup.keep",
+	"max-age": "backup.maxAge",
+}
+
+// runBackup implements the backup subcommand
+// cronlogger_server backup --config=<file|dir> --db=<path> --dir=<backup-dir> --keep=7 --max-age=720h
+func runBackup(args []string) int {
+	flags := flag.NewFlagSet("backup", flag.ExitOnError)
+	flags.String("db", "./cronlog-store.db", "the path to the sqlite db file, overrides the configuration")
+	flags.String("dir", "", "the directory of the snapshots, overrides backup.dir of the configuration")
+	flags.Int("keep", 0, "the number of snapshots to keep, 0 keeps all snapshots, overrides backup.keep of the configuration")
+	flags.Duration("max-age", 0, "remove snapshots older than the duration, 0 keeps the snapshots regardless of their age, overrides backup.maxAge of the configuration")
+	configFile := flags.String("config", "", "the config file or its directory, defaults to $"+config.EnvFile+" or the search of application.yaml")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s backup [flags]\n\n", AppName)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	cfg, err := config.Load(*configFile, flags, backupFlags, config.SectionDatabase, config.SectionBackup)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\nexiting\n", err)
+		return 1
+	}
+	if store.IsPostgresDsn(cfg.Database.DSN) {
+		fmt.Fprintf(os.Stderr, "backups are only available for sqlite databases, use pg_dump for PostgreSQL, exiting\n")
+		return 1
+	}
+	con, db, err := store.CreateConFromDsn(cfg.Database.DSN)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v, exiting\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	// the schedule of the configuration is only used by the server
+	backups, err := backup.New(con, logger, cronlogger.BackupConfig{Dir: cfg.Backup.Dir, Keep: cfg.Backup.Keep, MaxAge: cfg.Backup.MaxAge})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v, exiting\n", err)
+		return 1
+	}
+	started := time.Now()
+	snapshot, err := backups.Backup(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v, exiting\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "created the backup '%s' (%d bytes) in %v\n", snapshot.Path, snapshot.Size, time.Since(started).Round(time.Millisecond))
+	return 0
+}
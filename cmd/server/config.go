@@ -0,0 +1,39 @@
+package main
+
+import (
+	"cronlogger/config"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// runConfig implements the config subcommand
+// cronlogger_server config check --config=<file|dir>
+func runConfig(args []string) int {
+	flags := flag.NewFlagSet("config", flag.ExitOnError)
+	configFile := flags.String("config", "", "the config file or its directory, defaults to $"+config.EnvFile+" or the search of application.yaml")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s config check [flags]\n\n", AppName)
+		fmt.Fprintln(flags.Output(), "  check: validate the configuration file and the environment variables")
+		fmt.Fprintln(flags.Output())
+		flags.PrintDefaults()
+	}
+	if len(args) == 0 || args[0] != "check" {
+		flags.Usage()
+		return 1
+	}
+	flags.Parse(args[1:])
+
+	cfg, err := config.Load(*configFile, nil, nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if cfg.File == "" {
+		fmt.Println("no configuration file found, the defaults and the environment are valid")
+		return 0
+	}
+	fmt.Printf("the configuration '%s' is valid: %d applications, %d jobs, %d notification routes\n",
+		cfg.File, len(cfg.Applications), len(cfg.Jobs), len(cfg.Notification.Routes))
+	return 0
+}
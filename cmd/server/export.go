@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"cronlogger/config"
+	"cronlogger/store"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// runExport implements the export subcommand
+// cronlogger_server export --config=<file|dir> --db=<path|postgres-url> --format=csv|jsonl --from=2025-01-01 --until=2025-01-31 --app=<name> --out=<file>
+func runExport(args []string) int {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	flags.String("db", "./cronlog-store.db", "the path to the db file or a PostgreSQL URL (postgres://...), overrides the configuration")
+	configFile := flags.String("config", "", "the config file or its directory, defaults to $"+config.EnvFile+" or the search of application.yaml")
+	formatParam := flags.String("format", "csv", "the format of the export: csv or jsonl")
+	fromParam := flags.String("from", "", "export the runs created on or after the date (YYYY-MM-DD)")
+	untilParam := flags.String("until", "", "export the runs created on or before the date (YYYY-MM-DD)")
+	app := flags.String("app", "", "export only the runs of the application")
+	outFile := flags.String("out", "", "the file of the export, stdout if empty")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s export [flags]\n\n", AppName)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	format, err := store.ParseExportFormat(*formatParam)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v, exiting\n", err)
+		return 1
+	}
+	from, until, err := store.ParseDayRange(*fromParam, *untilParam)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v, exiting\n", err)
+		return 1
+	}
+
+	cfg, err := config.Load(*configFile, flags, databaseFlags, config.SectionDatabase)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\nexiting\n", err)
+		return 1
+	}
+	str, db, err := store.CreateStoreFromDsn(cfg.Database.DSN, store.Options{QueryTimeout: cfg.Database.QueryTimeout})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v, exiting\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	var out io.Writer = os.Stdout
+	if *outFile != "" {
+		f, err := os.Create(*outFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "could not create the export file; %v, exiting\n", err)
+			return 1
+		}
+		defer f.Close()
+		out = f
+	}
+
+	count, err := store.Export(context.Background(), str, out, format, from, until, *app)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v, exiting\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "exported %d runs\n", count)
+	return 0
+}
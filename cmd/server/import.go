@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"cronlogger/config"
+	"cronlogger/store"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// runImport implements the import subcommand
+// cronlogger_server import --config=<file|dir> --db=<path|postgres-url> --host=<origin> <export.jsonl|other.db|->
+func runImport(args []string) int {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	flags.String("db", "./cronlog-store.db", "the path to the db file or a PostgreSQL URL (postgres://...), overrides the configuration")
+	configFile := flags.String("config", "", "the config file or its directory, defaults to $"+config.EnvFile+" or the search of application.yaml")
+	host := flags.String("host", "", "the origin host of the imported runs, required")
+	formatParam := flags.String("format", "", "the format of the source: db or jsonl, derived from the file extension if empty")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s import [flags] <source>\n\n", AppName)
+		fmt.Fprintln(flags.Output(), "  source: a cronlogger db file, a PostgreSQL URL or a JSON Lines export (- reads stdin)")
+		fmt.Fprintln(flags.Output())
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 || *host == "" {
+		flags.Usage()
+		return 1
+	}
+	src := flags.Arg(0)
+
+	format := *formatParam
+	if format == "" {
+		format = "db"
+		if ext := strings.ToLower(filepath.Ext(src)); src == "-" || ext == ".jsonl" || ext == ".ndjson" {
+			format = "jsonl"
+		}
+	}
+
+	cfg, err := config.Load(*configFile, flags, databaseFlags, config.SectionDatabase)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\nexiting\n", err)
+		return 1
+	}
+	str, db, err := store.CreateStoreFromDsn(cfg.Database.DSN, store.Options{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v, exiting\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	var source store.ImportSource
+	switch format {
+	case "jsonl", "ndjson":
+		var in io.Reader = os.Stdin
+		if src != "-" {
+			f, err := os.Open(src)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "could not open the source; %v, exiting\n", err)
+				return 1
+			}
+			defer f.Close()
+			in = f
+		}
+		source = store.JSONLSource(in)
+	case "db":
+		if src == cfg.Database.DSN {
+			fmt.Fprintf(os.Stderr, "the source is the target database, exiting\n")
+			return 1
+		}
+		// the source is not changed, a sqlite file is read from a migrated copy
+		srcSource, srcDb, err := store.OpenStoreSource(context.Background(), src)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "could not open the source; %v, exiting\n", err)
+			return 1
+		}
+		defer srcDb.Close()
+		source = srcSource
+	default:
+		fmt.Fprintf(os.Stderr, "unknown source format '%s', expected db or jsonl, exiting\n", format)
+		return 1
+	}
+
+	result, err := store.ImportItems(context.Background(), str, source, *host)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v, exiting\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "read %d runs, imported %d, skipped %d duplicates\n", result.Read, result.Imported, result.Skipped)
+	return 0
+}
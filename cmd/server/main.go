@@ -3,8 +3,16 @@ package main
 import (
 	"context"
 	"cronlogger"
+	"cronlogger/auth"
+	"cronlogger/backup"
+	"cronlogger/config"
 	"cronlogger/handler"
+	"cronlogger/health"
+	"cronlogger/metrics"
+	"cronlogger/notify"
+	"cronlogger/scheduler"
 	"cronlogger/store"
+	"cronlogger/tracing"
 	"errors"
 	"flag"
 	"fmt"
@@ -14,10 +22,9 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
-
-	"github.com/spf13/viper"
 )
 
 var (
@@ -29,110 +36,219 @@ var (
 	AppName = "cronloggerserver"
 )
 
+// subcommands of the server binary, e.g. cronlogger_server migrate status
+var commands = map[string]func(args []string) int{
+	"migrate":       runMigrate,
+	"export":        runExport,
+	"import":        runImport,
+	"backup":        runBackup,
+	"hash-password": runHashPassword,
+	"config":        runConfig,
+}
+
+// serverFlags override the settings of the configuration file and the environment
+var serverFlags = config.Flags{
+	"port":          "server.port",
+	"host":          "server.host",
+	"db":            "database.dsn",
+	"loglevel":      "server.logLevel",
+	"logformat":     "server.logFormat",
+	"query-timeout": "database.queryTimeout",
+}
+
+// databaseFlags override the database of the configuration file for the subcommands
+var databaseFlags = config.Flags{"db": "database.dsn"}
+
 // start a http server to show the result of the collected data of the cronlogger
 func main() {
-	var (
-		port       int
-		host       string
-		dbPath     string
-		logLevel   string
-		configFile string
-		help       bool
-	)
-
-	flag.IntVar(&port, "port", 9000, "define the port of the server")
-	flag.StringVar(&host, "host", "localhost", "define the hostname of the server")
-	flag.StringVar(&dbPath, "db", "./cronlog-store.db", "the path to the db file")
-	flag.StringVar(&logLevel, "loglevel", "INFO", "the loglevel to use (DEBUG|INFO|WARN|ERROR)")
-	flag.StringVar(&configFile, "config", "./", "the path to the config file")
-	flag.BoolVar(&help, "help", false, "show the help information")
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			os.Exit(cmd(os.Args[2:]))
+		}
+	}
+
+	flag.Int("port", 9000, "define the port of the server")
+	flag.String("host", "localhost", "define the hostname of the server")
+	flag.String("db", "./cronlog-store.db", "the path to the db file or a PostgreSQL URL (postgres://...)")
+	flag.String("loglevel", "INFO", "the loglevel to use (DEBUG|INFO|WARN|ERROR)")
+	flag.String("logformat", "text", "the format of the log entries (text|json)")
+	flag.Duration("query-timeout", store.DefaultQueryTimeout, "the maximum duration of a database query, 0 means no timeout")
+	configFile := flag.String("config", "", "the config file or its directory, defaults to $"+config.EnvFile+" or the search of application.yaml")
+	help := flag.Bool("help", false, "show the help information")
 	flag.Parse()
 
 	ver := fmt.Sprintf("%s-%s", Version, Build)
-	if help {
+	if *help {
 		fmt.Printf("Cronlogger %s\n\n", ver)
 		flag.Usage()
 		os.Exit(0)
 	}
 
-	config, err := readConfig(configFile)
+	cfg, err := config.Load(*configFile, flag.CommandLine, serverFlags)
 	if err != nil {
-		fmt.Printf("%v, exiting", err)
+		fmt.Printf("%v\nexiting\n", err)
 		os.Exit(1)
 	}
 
-	store, db, err := store.CreateSqliteStoreFromDbPath(dbPath)
+	dsn := cfg.Database.DSN
+	// the lock files of the scheduler are kept next to a sqlite file
+	lockDir := filepath.Dir(dsn)
+	if store.IsPostgresDsn(dsn) {
+		lockDir = os.TempDir()
+	}
+	logger, err := setupLogging(cfg.Server.LogLevel, cfg.Server.LogFormat)
 	if err != nil {
 		fmt.Printf("%v, exiting", err)
 		os.Exit(1)
 	}
-	defer db.Close()
+	// the messages of the standard log package use the same format
+	slog.SetDefault(logger)
+	if cfg.File == "" {
+		logger.Info("no configuration file found, using the defaults and the environment")
+	} else {
+		logger.Info("read the configuration", "file", cfg.File)
+	}
 
-	handler := handler.New(store, setupLogging(logLevel), ver, config)
-	startServer(fmt.Sprintf("%s:%d", host, port), handler)
-}
+	// without an endpoint the spans are not recorded
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing, AppName, ver)
+	if err != nil {
+		fmt.Printf("%v, exiting", err)
+		os.Exit(1)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Error("could not export the pending spans", "error", err)
+		}
+	}()
 
-func setupLogging(level string) *slog.Logger {
-	logLevel := &slog.LevelVar{} // INFO
+	// the changes of the runs are published to the subscribers of the event bus
+	events := store.NewEventBus(logger)
+	defer events.Close()
+	events.Subscribe("log", store.DefaultEventQueueSize, store.SubscriberFunc(func(e store.Event) {
+		logger.Debug("run event", "type", e.Type, "run", e.Run.ID, "app", e.Run.App, "status", e.Run.State())
+	}))
 
-	switch level {
-	case "INFO":
-		logLevel.Set(slog.LevelInfo)
-	case "DEBUG":
-		logLevel.Set(slog.LevelInfo)
-	case "WARN":
-		logLevel.Set(slog.LevelWarn)
-	case "ERROR":
-		logLevel.Set(slog.LevelError)
+	con, db, err := store.CreateConFromDsn(dsn)
+	if err != nil {
+		fmt.Printf("%v, exiting", err)
+		os.Exit(1)
 	}
-
-	opts := &slog.HandlerOptions{
-		Level: logLevel,
+	defer db.Close()
+	if _, err := store.Migrate(con); err != nil {
+		fmt.Printf("could not migrate the database schema: %v, exiting", err)
+		os.Exit(1)
 	}
+	if err := tracing.InstrumentStore(con); err != nil {
+		fmt.Printf("%v, exiting", err)
+		os.Exit(1)
+	}
+	tokenStore := store.CreateTokenStore(con, store.Options{QueryTimeout: cfg.Database.QueryTimeout})
+	store := store.CreateStore(con, store.Options{QueryTimeout: cfg.Database.QueryTimeout, Events: events})
 
-	logger := slog.New(slog.NewTextHandler(os.Stdout, opts))
-	return logger
-}
-
-func readConfig(configPath string) (cronlogger.AppConfig, error) {
-	viper.SetConfigName("application")
-	viper.SetConfigType("yaml")
-
-	// Add search paths to find the file
-	viper.AddConfigPath("/etc/cronlogger/")
-	viper.AddConfigPath("/var/cronlogger/")
-	viper.AddConfigPath("$HOME/.cronlogger")
-
-	configPath = filepath.Clean(configPath)
-	configPath, err := filepath.Abs(configPath)
-	if err == nil {
-		_, err = os.Stat(configPath)
-		if !os.IsNotExist(err) {
-			viper.AddConfigPath(configPath)
+	// the backups of the sqlite database are only created if a backup directory is configured
+	var backups *backup.Manager
+	if cfg.Backup.Dir != "" {
+		backups, err = backup.New(con, logger, cfg.Backup)
+		if err != nil {
+			fmt.Printf("%v, exiting", err)
+			os.Exit(1)
 		}
-	} else {
-		fmt.Printf("cannot resolve supplied path '%s'; %v", configPath, err)
+		backups.Start()
+		defer backups.Stop()
 	}
 
-	// current executable path
-	path, err := os.Executable()
-	if err == nil {
-		basePath := filepath.Dir(path)
-		viper.AddConfigPath(basePath)
+	// the built-in scheduler is only used if jobs are configured
+	var sched *scheduler.Scheduler
+	if len(cfg.Jobs) > 0 {
+		sched, err = scheduler.New(store, logger, cfg.AppConfig, lockDir)
+		if err != nil {
+			fmt.Printf("%v, exiting", err)
+			os.Exit(1)
+		}
+		sched.Start()
 	}
 
-	var config cronlogger.AppConfig
-	err = viper.ReadInConfig()
+	authn, err := auth.New(cfg.Auth, logger)
 	if err != nil {
-		return config, fmt.Errorf("cannot read configuration; %v", err)
+		fmt.Printf("%v, exiting", err)
+		os.Exit(1)
+	}
+	if !authn.Enabled() {
+		logger.Warn("no users, API tokens or OIDC provider are configured, the server is available without authentication")
+	}
+	// the tokens of the token store are only accepted if the authentication is enabled,
+	// they would otherwise enable the authentication without users to log in
+	opts := handler.Options{Auth: authn}
+	if authn.Enabled() {
+		tokens := auth.NewManagedTokens(tokenStore, logger)
+		authn.Use(tokens)
+		opts.Tokens = tokens
+	}
+	if sched != nil {
+		opts.Runner = sched
+	}
+	if backups != nil {
+		opts.Backups = backups
 	}
 
-	err = viper.Unmarshal(&config)
+	// the durations of the runs are observed from the events, the other metrics are read from the store
+	metrics, err := metrics.New(store, logger, cfg.AppConfig)
 	if err != nil {
-		return config, fmt.Errorf("cannot parse configuration; %v", err)
+		fmt.Printf("%v, exiting", err)
+		os.Exit(1)
+	}
+	events.Subscribe("metrics", 0, metrics)
+	// the finished runs are sent to the webhooks of the notification routes
+	notifier := notify.New(logger, cfg.Notification)
+	events.Subscribe("notify", 0, notifier)
+
+	// the probes of a load balancer or uptime checker are available without authentication
+	authn.Public("/healthz", "/readyz")
+	opts.Health = health.New(con, dsn, cfg.Health.MinFreeMB)
+	opts.Metrics = metrics.Handler()
+
+	// the applications, the default color and the notification routes are reloaded without a restart
+	reloader := config.NewReloader(*configFile, flag.CommandLine, serverFlags, cfg, logger)
+	opts.Reloader = reloader
+	handler := handler.New(store, logger, ver, cfg.AppConfig, opts)
+	reloader.OnReload(func(config cronlogger.AppConfig) error {
+		handler.SetConfig(config)
+		notifier.Update(config.Notification)
+		return nil
+	})
+	reloader.OnReload(metrics.Update)
+	startServer(fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port), handler, authn, metrics, logger, sched, reloader)
+}
+
+// setupLogging creates the logger of the server, the entries are written as text or as JSON
+func setupLogging(level, format string) (*slog.Logger, error) {
+	var logLevel slog.Level
+	switch strings.ToUpper(level) {
+	case "DEBUG":
+		logLevel = slog.LevelDebug
+	case "INFO":
+		logLevel = slog.LevelInfo
+	case "WARN":
+		logLevel = slog.LevelWarn
+	case "ERROR":
+		logLevel = slog.LevelError
+	default:
+		return nil, fmt.Errorf("invalid loglevel '%s', expected DEBUG, INFO, WARN or ERROR", level)
 	}
-	return config, nil
 
+	opts := &slog.HandlerOptions{
+		Level: logLevel,
+	}
+	switch strings.ToLower(format) {
+	case "text":
+		return slog.New(slog.NewTextHandler(os.Stdout, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(os.Stdout, opts)), nil
+	default:
+		return nil, fmt.Errorf("invalid logformat '%s', expected text or json", format)
+	}
 }
 
 func printServerBanner(name, version, build, addr string) {
@@ -142,13 +258,14 @@ func printServerBanner(name, version, build, addr string) {
 	fmt.Printf("%s Ready!\n", "🏁")
 }
 
-func startServer(addr string, hdlr *handler.CronLogHandler) {
+func startServer(addr string, hdlr *handler.CronLogHandler, authn *auth.Service, m *metrics.Metrics, logger *slog.Logger, sched *scheduler.Scheduler, reloader *config.Reloader) {
 	mux := http.NewServeMux()
 	handler.SetupRoutes(mux, hdlr)
 
+	// the HTTP metrics, the spans and the access log include the requests rejected by the authentication
 	srv := &http.Server{
 		Addr:    addr,
-		Handler: mux,
+		Handler: m.Middleware(mux, tracing.Middleware(handler.AccessLog(logger, authn.Middleware(mux)), metrics.RouteOf)),
 	}
 
 	printServerBanner(AppName, Version, Build, addr)
@@ -159,9 +276,24 @@ func startServer(addr string, hdlr *handler.CronLogHandler) {
 		log.Println("Stopped serving new connections.")
 	}()
 
+	// the configuration is reloaded once the file changes or with a SIGHUP (systemctl reload)
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	go func() {
+		if err := reloader.Watch(watchCtx); err != nil {
+			logger.Warn("the configuration is only reloaded by SIGHUP or the API", "error", err)
+		}
+	}()
+
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigChan
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range sigChan {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		// the reloader logs the changes and the errors
+		reloader.Reload()
+	}
 
 	shutdownCtx, shutdownRelease := context.WithTimeout(context.Background(), 10*time.Second)
 	defer shutdownRelease()
@@ -169,5 +301,9 @@ func startServer(addr string, hdlr *handler.CronLogHandler) {
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		log.Fatalf("HTTP shutdown error: %v", err)
 	}
+	if sched != nil {
+		sched.Stop(shutdownCtx)
+		log.Println("Scheduler stopped.")
+	}
 	log.Println("Graceful shutdown complete.")
 }
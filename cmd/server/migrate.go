@@ -0,0 +1,68 @@
+package main
+
+import (
+	"cronlogger/config"
+	"cronlogger/store"
+	"flag"
+	"fmt"
+)
+
+// runMigrate implements the migrate subcommand
+// cronlogger_server migrate status|up --config=<file|dir> --db=<path|postgres-url>
+func runMigrate(args []string) int {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	flags.String("db", "./cronlog-store.db", "the path to the db file or a PostgreSQL URL (postgres://...), overrides the configuration")
+	configFile := flags.String("config", "", "the config file or its directory, defaults to $"+config.EnvFile+" or the search of application.yaml")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s migrate status|up [flags]\n\n", AppName)
+		fmt.Fprintln(flags.Output(), "  status: show the applied and pending migrations")
+		fmt.Fprintln(flags.Output(), "  up:     apply the pending migrations")
+		fmt.Fprintln(flags.Output())
+		flags.PrintDefaults()
+	}
+	if len(args) == 0 {
+		flags.Usage()
+		return 1
+	}
+	action := args[0]
+	flags.Parse(args[1:])
+
+	cfg, err := config.Load(*configFile, flags, databaseFlags, config.SectionDatabase)
+	if err != nil {
+		fmt.Printf("%v\nexiting\n", err)
+		return 1
+	}
+	con, db, err := store.CreateConFromDsn(cfg.Database.DSN)
+	if err != nil {
+		fmt.Printf("%v, exiting\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	switch action {
+	case "status":
+		state, err := store.MigrationState(con)
+		if err != nil {
+			fmt.Printf("%v, exiting\n", err)
+			return 1
+		}
+		for _, m := range state {
+			applied := "pending"
+			if m.Applied != nil {
+				applied = m.Applied.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%3d  %-19s  %s\n", m.Version, applied, m.Description)
+		}
+	case "up":
+		applied, err := store.Migrate(con)
+		if err != nil {
+			fmt.Printf("%v, exiting\n", err)
+			return 1
+		}
+		fmt.Printf("applied %d migrations, the schema version is %d\n", applied, store.LatestSchemaVersion())
+	default:
+		flags.Usage()
+		return 1
+	}
+	return 0
+}
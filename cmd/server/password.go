@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bufio"
+	"cronlogger/auth"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// runHashPassword implements the hash-password subcommand, the password is read from stdin
+// echo -n 'secret' | cronlogger_server hash-password
+func runHashPassword(args []string) int {
+	flags := flag.NewFlagSet("hash-password", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s hash-password < password\n\n", AppName)
+		fmt.Fprintf(flags.Output(), "Reads the password from stdin and prints the bcrypt hash for the users of the application.yaml\n")
+	}
+	flags.Parse(args)
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		fmt.Fprintf(os.Stderr, "could not read the password; %v, exiting\n", err)
+		return 1
+	}
+	hash, err := auth.HashPassword(strings.TrimRight(line, "\r\n"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v, exiting\n", err)
+		return 1
+	}
+	fmt.Println(hash)
+	return 0
+}
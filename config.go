@@ -1,11 +1,225 @@
 package cronlogger
 
+import "time"
+
 type Application struct {
 	Name  string `json:"name,omitempty"`
 	Color string `json:"color,omitempty"`
+	// Schedule is the expected cron expression of an application which is started outside of the
+	// built-in scheduler, e.g. by the crontab. The application is overdue if a scheduled run is missing.
+	Schedule string `json:"schedule,omitempty"`
+	// Grace is the delay after a scheduled run until the application is overdue, defaults to 1h
+	Grace time.Duration `json:"grace,omitempty"`
+}
+
+// Job defines a command which is executed by the built-in scheduler
+type Job struct {
+	// Name is the application name the results are stored for
+	Name string `json:"name,omitempty"`
+	// Schedule is a standard cron expression (e.g. "0 3 * * *") or a descriptor like "@daily".
+	// Jobs without a schedule can only be started manually.
+	Schedule string `json:"schedule,omitempty"`
+	// Command is executed via /bin/sh -c
+	Command string `json:"command,omitempty"`
+	// WorkDir is the working directory of the command
+	WorkDir string `json:"workDir,omitempty"`
+	// Env holds additional environment variables in the form KEY=value
+	Env []string `json:"env,omitempty"`
+	// Timeout is the maximum runtime of the command, 0 means no timeout
+	Timeout time.Duration `json:"timeout,omitempty"`
+	// Retries is the number of retries if the command fails or times out
+	Retries int `json:"retries,omitempty"`
+	// RetryDelay is the wait time before the first retry
+	RetryDelay time.Duration `json:"retryDelay,omitempty"`
+	// Lock defines how overlapping runs are handled (skip|wait|kill-previous)
+	Lock string `json:"lock,omitempty"`
+}
+
+// SchedulerConfig defines the settings of the built-in scheduler
+type SchedulerConfig struct {
+	// MaxConcurrency limits the number of jobs executed at the same time
+	MaxConcurrency int `json:"maxConcurrency,omitempty"`
+	// LockDir is the directory of the lock files, defaults to the directory of the db file
+	LockDir string `json:"lockDir,omitempty"`
+}
+
+// DatabaseConfig defines the storage of the results, it is shared by the server and the logger
+type DatabaseConfig struct {
+	// DSN is a PostgreSQL URL (postgres://...) or the path of a sqlite file, defaults to ./cronlog-store.db
+	DSN string `json:"dsn,omitempty"`
+	// QueryTimeout is the maximum duration of a query of the server, 0 means no timeout
+	QueryTimeout time.Duration `json:"queryTimeout,omitempty"`
+}
+
+// BackupConfig defines the snapshots of the sqlite database
+type BackupConfig struct {
+	// Dir is the directory of the snapshots, backups are disabled if it is empty
+	Dir string `json:"dir,omitempty"`
+	// Schedule is a cron expression or a descriptor like "@daily", without schedule backups are only created on demand
+	Schedule string `json:"schedule,omitempty"`
+	// Keep is the number of snapshots which are kept, 0 keeps all snapshots
+	Keep int `json:"keep,omitempty"`
+	// MaxAge removes older snapshots, 0 keeps the snapshots regardless of their age
+	MaxAge time.Duration `json:"maxAge,omitempty"`
+}
+
+// HealthConfig defines the readiness checks of the server
+type HealthConfig struct {
+	// MinFreeMB is the free disk space of the database directory below which the server is not ready, defaults to 100
+	MinFreeMB int `json:"minFreeMB,omitempty"`
+}
+
+// TracingConfig defines the export of OpenTelemetry traces via OTLP/HTTP
+type TracingConfig struct {
+	// Endpoint is the URL of the OTLP/HTTP receiver, e.g. http://localhost:4318, the tracing is disabled if it is empty
+	Endpoint string `json:"endpoint,omitempty"`
+	// Headers are added to the export requests, e.g. the API key of a tracing service
+	Headers map[string]string `json:"headers,omitempty"`
+	// ServiceName defaults to the name of the binary
+	ServiceName string `json:"serviceName,omitempty"`
+	// SampleRatio is the fraction of the recorded traces between 0 and 1, defaults to 1
+	SampleRatio float64 `json:"sampleRatio,omitempty"`
+}
+
+// NotificationRoute posts the finished runs of the matching applications to a webhook
+type NotificationRoute struct {
+	// Name identifies the route in the log
+	Name string `json:"name,omitempty"`
+	// Apps are the names of applications, * and ? are wildcards, e.g. "rclone-*". Without apps all applications match.
+	Apps []string `json:"apps,omitempty"`
+	// Statuses of the runs which are sent, defaults to failure and timeout
+	Statuses []string `json:"statuses,omitempty"`
+	// Webhook is the URL the run is posted to as JSON, e.g. an incoming webhook of a chat
+	Webhook string `json:"webhook,omitempty"`
+	// Headers are added to the requests, e.g. the API key of an alerting service
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// NotificationConfig defines the notifications of finished runs
+type NotificationConfig struct {
+	Routes []NotificationRoute `json:"routes,omitempty"`
+	// Timeout limits the duration of a webhook request, defaults to 10s
+	Timeout time.Duration `json:"timeout,omitempty"`
+}
+
+// User is a user of the web UI and the API
+type User struct {
+	Name string `json:"name,omitempty"`
+	// Password is the bcrypt hash of the password, see the hash-password subcommand
+	Password string   `json:"password,omitempty"`
+	Groups   []string `json:"groups,omitempty"`
+}
+
+// APIToken is a static bearer token of a machine client
+type APIToken struct {
+	// Name identifies the client, e.g. as user of manually started jobs
+	Name  string `json:"name,omitempty"`
+	Token string `json:"token,omitempty"`
+}
+
+// OIDCConfig defines the single sign-on of an OpenID Connect provider
+type OIDCConfig struct {
+	// Name is shown on the login button, e.g. the name of the provider
+	Name string `json:"name,omitempty"`
+	// Issuer is the URL of the provider, the single sign-on is disabled if it is empty
+	Issuer       string `json:"issuer,omitempty"`
+	ClientID     string `json:"clientID,omitempty"`
+	ClientSecret string `json:"clientSecret,omitempty"`
+	// RedirectURL is the callback of the server, e.g. https://cron.example.com/cronlogger/oidc/callback
+	RedirectURL string `json:"redirectURL,omitempty"`
+	// Scopes are requested in addition to openid, defaults to profile and email
+	Scopes []string `json:"scopes,omitempty"`
+	// GroupsClaim is the claim of the ID token with the groups of the user, defaults to groups
+	GroupsClaim string `json:"groupsClaim,omitempty"`
+	// AllowedGroups and AllowedEmails restrict the login, an email starting with @ allows the whole domain.
+	// Without restrictions every user of the provider may log in.
+	AllowedGroups []string `json:"allowedGroups,omitempty"`
+	AllowedEmails []string `json:"allowedEmails,omitempty"`
+}
+
+// AccessRule grants a role on the applications matching the patterns to users and groups
+type AccessRule struct {
+	Users  []string `json:"users,omitempty"`
+	Groups []string `json:"groups,omitempty"`
+	// Apps are the names of applications, * and ? are wildcards, e.g. "rclone-*". "*" matches all applications.
+	Apps []string `json:"apps,omitempty"`
+	// Role is read, trigger or admin; trigger includes read and admin includes trigger
+	Role string `json:"role,omitempty"`
+}
+
+// AuthConfig defines the authentication of the server, it is disabled if neither users, tokens nor OIDC are defined
+type AuthConfig struct {
+	Users  []User     `json:"users,omitempty"`
+	Tokens []APIToken `json:"tokens,omitempty"`
+	OIDC   OIDCConfig `json:"oidc,omitempty"`
+	// SessionKey signs the session cookies, a random key is used if empty (the sessions end with a restart)
+	SessionKey string `json:"sessionKey,omitempty"`
+	// SessionTTL is the lifetime of a session after the login, defaults to 12h. The session cookie keeps the
+	// groups of the login, changed groups apply with the next login. A logout revokes the session in the
+	// memory of the server only: a copy of the cookie is valid again after a restart with the same SessionKey
+	// and at the other instances sharing the key, until the TTL ends.
+	SessionTTL time.Duration `json:"sessionTTL,omitempty"`
+	// Access restricts the applications of the users, without rules every user may access all applications
+	Access []AccessRule `json:"access,omitempty"`
 }
 
 type AppConfig struct {
-	Applications []Application `json:"applications,omitempty"`
-	DefaultColor string        `json:"defaultColor,omitempty"`
+	Applications []Application      `json:"applications,omitempty"`
+	DefaultColor string             `json:"defaultColor,omitempty"`
+	Database     DatabaseConfig     `json:"database,omitempty"`
+	Scheduler    SchedulerConfig    `json:"scheduler,omitempty"`
+	Jobs         []Job              `json:"jobs,omitempty"`
+	Backup       BackupConfig       `json:"backup,omitempty"`
+	Health       HealthConfig       `json:"health,omitempty"`
+	Tracing      TracingConfig      `json:"tracing,omitempty"`
+	Notification NotificationConfig `json:"notification,omitempty"`
+	Auth         AuthConfig         `json:"auth,omitempty"`
+}
+
+// Application returns the configuration of the given application
+func (c AppConfig) Application(name string) (Application, bool) {
+	for _, app := range c.Applications {
+		if app.Name == name {
+			return app, true
+		}
+	}
+	return Application{}, false
+}
+
+// Job returns the job configuration of the given application
+func (c AppConfig) Job(name string) (Job, bool) {
+	for _, job := range c.Jobs {
+		if job.Name == name {
+			return job, true
+		}
+	}
+	return Job{}, false
+}
+
+// RunParams creates the parameters to execute the job
+func (j Job) RunParams(lockDir string) (RunParams, error) {
+	policy, err := ParseLockPolicy(j.Lock)
+	if err != nil {
+		return RunParams{}, err
+	}
+	retryDelay := j.RetryDelay
+	if retryDelay == 0 {
+		retryDelay = 30 * time.Second
+	}
+	return RunParams{
+		Exec: ExecParams{
+			Command:     []string{"/bin/sh", "-c", j.Command},
+			Dir:         j.WorkDir,
+			Env:         j.Env,
+			Timeout:     j.Timeout,
+			GracePeriod: DefaultGracePeriod,
+		},
+		Retry: RetryParams{
+			Retries: j.Retries,
+			Delay:   retryDelay,
+			Backoff: 2,
+		},
+		Lock:     policy,
+		LockPath: LockPath(lockDir, j.Name),
+	}, nil
 }
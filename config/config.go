@@ -0,0 +1,183 @@
+package config
+
+import (
+	"cronlogger"
+	"cronlogger/store"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// the server and the logger binary share application.yaml. A setting is taken from, in
+// increasing precedence: the defaults, the file, the environment variables and the
+// explicitly set command line flags. The file is optional, e.g. a container can be
+// configured by environment variables alone.
+
+// Name is the name of the configuration file without extension
+const Name = "application"
+
+// EnvFile is the environment variable of the configuration file or its directory
+const EnvFile = "CRONLOGGER_CONFIG"
+
+// Env maps the environment variables to the keys of the configuration
+var Env = []struct {
+	Var string
+	Key string
+}{
+	{"CRONLOGGER_DB", "database.dsn"},
+	{"CRONLOGGER_QUERY_TIMEOUT", "database.queryTimeout"},
+	{"CRONLOGGER_HOST", "server.host"},
+	{"CRONLOGGER_PORT", "server.port"},
+	{"CRONLOGGER_LOGLEVEL", "server.logLevel"},
+	{"CRONLOGGER_LOGFORMAT", "server.logFormat"},
+	{"CRONLOGGER_LOCKDIR", "logger.lockDir"},
+	{"CRONLOGGER_BACKUP_DIR", "backup.dir"},
+	{"CRONLOGGER_SESSION_KEY", "auth.sessionKey"},
+	{"CRONLOGGER_OIDC_CLIENT_SECRET", "auth.oidc.clientSecret"},
+	{"CRONLOGGER_OTLP_ENDPOINT", "tracing.endpoint"},
+}
+
+// ServerConfig defines the HTTP server and its log
+type ServerConfig struct {
+	Host string `json:"host,omitempty"`
+	Port int    `json:"port,omitempty"`
+	// LogLevel is DEBUG, INFO, WARN or ERROR
+	LogLevel string `json:"logLevel,omitempty"`
+	// LogFormat is text or json
+	LogFormat string `json:"logFormat,omitempty"`
+}
+
+// LoggerConfig defines the defaults of the exec mode of the logger binary
+type LoggerConfig struct {
+	// Timeout is the maximum runtime of the command, 0 means no timeout
+	Timeout time.Duration `json:"timeout,omitempty"`
+	// Grace is the time between SIGTERM and SIGKILL once the timeout is reached
+	Grace time.Duration `json:"grace,omitempty"`
+	// Lock defines how overlapping runs are handled (skip|wait|kill-previous)
+	Lock string `json:"lock,omitempty"`
+	// LockDir is the directory of the lock files, defaults to the directory of the db file
+	LockDir string `json:"lockDir,omitempty"`
+	// Retries is the number of retries if the command fails or times out
+	Retries int `json:"retries,omitempty"`
+	// RetryDelay is the wait time before the first retry
+	RetryDelay time.Duration `json:"retryDelay,omitempty"`
+	// RetryBackoff is the factor the delay is multiplied with after each retry (1 = constant delay)
+	RetryBackoff float64 `json:"retryBackoff,omitempty"`
+}
+
+// Config is the configuration of the server and the logger binary
+type Config struct {
+	cronlogger.AppConfig `mapstructure:",squash"`
+	Server               ServerConfig `json:"server,omitempty"`
+	Logger               LoggerConfig `json:"logger,omitempty"`
+	// File is the path of the read configuration file, empty if no file was found
+	File string `json:"-" mapstructure:"-"`
+}
+
+var defaults = map[string]any{
+	"database.dsn":          "./cronlog-store.db",
+	"database.queryTimeout": store.DefaultQueryTimeout,
+	"server.host":           "localhost",
+	"server.port":           9000,
+	"server.logLevel":       "INFO",
+	"server.logFormat":      "text",
+	"logger.grace":          cronlogger.DefaultGracePeriod,
+	"logger.retryDelay":     30 * time.Second,
+	"logger.retryBackoff":   2.0,
+}
+
+// Flags maps the names of command line flags to the keys of the configuration
+type Flags map[string]string
+
+// Load reads and validates the configuration. The path is the configuration file or its
+// directory, without a path the file of CRONLOGGER_CONFIG is read or the file is searched
+// in the default directories. The flags of the set which were passed explicitly override
+// the settings of the file and the environment. Only the given sections are validated, all
+// sections without a section; the settings of the other sections are read nevertheless.
+func Load(path string, set *flag.FlagSet, flags Flags, sections ...Section) (Config, error) {
+	v := viper.New()
+	v.SetConfigName(Name)
+	v.SetConfigType("yaml")
+	for key, value := range defaults {
+		v.SetDefault(key, value)
+	}
+	for _, env := range Env {
+		if err := v.BindEnv(env.Key, env.Var); err != nil {
+			return Config{}, fmt.Errorf("could not bind the environment variable %s; %v", env.Var, err)
+		}
+	}
+
+	if path == "" {
+		path = os.Getenv(EnvFile)
+	}
+	explicit := path != ""
+	if explicit {
+		if err := addPath(v, path); err != nil {
+			return Config{}, err
+		}
+	} else {
+		addDefaultPaths(v)
+	}
+	if err := v.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if explicit || !errors.As(err, &notFound) {
+			return Config{}, fmt.Errorf("cannot read configuration; %v", err)
+		}
+	}
+
+	if set != nil {
+		set.Visit(func(f *flag.Flag) {
+			if key, ok := flags[f.Name]; ok {
+				v.Set(key, f.Value.String())
+			}
+		})
+	}
+
+	var config Config
+	if err := v.Unmarshal(&config); err != nil {
+		return config, fmt.Errorf("cannot parse configuration; %v", err)
+	}
+	config.File = v.ConfigFileUsed()
+	if err := config.Validate(sections...); err != nil {
+		name := config.File
+		if name == "" {
+			name = "defaults and environment"
+		}
+		return config, fmt.Errorf("invalid configuration (%s):\n%w", name, err)
+	}
+	return config, nil
+}
+
+// addPath uses the file of the path or application.yaml in the directory of the path
+func addPath(v *viper.Viper, path string) error {
+	path, err := filepath.Abs(filepath.Clean(path))
+	if err != nil {
+		return fmt.Errorf("cannot resolve the configuration path '%s'; %v", path, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("cannot read configuration; %v", err)
+	}
+	if info.IsDir() {
+		v.AddConfigPath(path)
+	} else {
+		v.SetConfigFile(path)
+	}
+	return nil
+}
+
+func addDefaultPaths(v *viper.Viper) {
+	v.AddConfigPath("/etc/cronlogger/")
+	v.AddConfigPath("/var/cronlogger/")
+	v.AddConfigPath("$HOME/.cronlogger")
+	v.AddConfigPath(".")
+	// the directory of the executable
+	if path, err := os.Executable(); err == nil {
+		v.AddConfigPath(filepath.Dir(path))
+	}
+}
@@ -0,0 +1,228 @@
+package config_test
+
+import (
+	"cronlogger/config"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "application.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("could not write the config; %v", err)
+	}
+	return path
+}
+
+func Test_Load_Precedence(t *testing.T) {
+	path := writeConfig(t, `
+applications:
+  - name: "rclone-gdrive"
+    color: "#4285F4"
+database:
+  dsn: "/var/cronlog/file.db"
+server:
+  port: 8000
+  logLevel: "DEBUG"
+logger:
+  timeout: "30m"
+`)
+	t.Setenv("CRONLOGGER_DB", "/var/cronlog/env.db")
+	t.Setenv("CRONLOGGER_PORT", "8001")
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.Int("port", 9000, "")
+	flags.Duration("timeout", 0, "")
+	flags.String("host", "localhost", "")
+	if err := flags.Parse([]string{"--port=8002"}); err != nil {
+		t.Fatalf("could not parse the flags; %v", err)
+	}
+
+	cfg, err := config.Load(path, flags, config.Flags{"port": "server.port", "timeout": "logger.timeout", "host": "server.host"})
+	if err != nil {
+		t.Fatalf("could not load the config; %v", err)
+	}
+	if cfg.File != path {
+		t.Errorf("expected the file '%s', got '%s'", path, cfg.File)
+	}
+	if len(cfg.Applications) != 1 || cfg.Applications[0].Color != "#4285F4" {
+		t.Errorf("expected the applications of the file, got %v", cfg.Applications)
+	}
+	// the environment overrides the file, the flag overrides the environment
+	if cfg.Database.DSN != "/var/cronlog/env.db" {
+		t.Errorf("expected the dsn of the environment, got '%s'", cfg.Database.DSN)
+	}
+	if cfg.Server.Port != 8002 {
+		t.Errorf("expected the port of the flag, got %d", cfg.Server.Port)
+	}
+	// flags which are not set do not override the file
+	if cfg.Logger.Timeout != 30*time.Minute || cfg.Server.LogLevel != "DEBUG" {
+		t.Errorf("expected the settings of the file, got %v and '%s'", cfg.Logger.Timeout, cfg.Server.LogLevel)
+	}
+	if cfg.Server.Host != "localhost" || cfg.Logger.RetryBackoff != 2 || cfg.Database.QueryTimeout == 0 {
+		t.Errorf("expected the defaults, got %+v and %+v", cfg.Server, cfg.Logger)
+	}
+}
+
+func Test_Load_Files(t *testing.T) {
+	path := writeConfig(t, `defaultColor: "#212529"`)
+
+	// the directory of the file
+	cfg, err := config.Load(filepath.Dir(path), nil, nil)
+	if err != nil || cfg.File != path {
+		t.Errorf("expected the file in the directory, got '%s'; %v", cfg.File, err)
+	}
+
+	// the file of the environment
+	t.Setenv(config.EnvFile, path)
+	cfg, err = config.Load("", nil, nil)
+	if err != nil || cfg.DefaultColor != "#212529" {
+		t.Errorf("expected the file of the environment, got '%s'; %v", cfg.File, err)
+	}
+
+	// an explicit file has to exist
+	if _, err := config.Load(filepath.Join(t.TempDir(), "missing.yaml"), nil, nil); err == nil {
+		t.Errorf("expected an error for a missing file")
+	}
+	if _, err := config.Load(writeConfig(t, "applications: ["), nil, nil); err == nil {
+		t.Errorf("expected an error for an invalid file")
+	}
+}
+
+func Test_Load_Invalid(t *testing.T) {
+	path := writeConfig(t, `
+applications:
+  - name: "rclone-gdrive"
+    color: "blue;}"
+  - name: "rclone gdrive"
+    schedule: "every day"
+  - name: "rclone-gdrive"
+jobs:
+  - name: "backup"
+    lock: "never"
+notification:
+  routes:
+    - webhook: "hooks.example.com"
+      statuses: ["failed"]
+auth:
+  users:
+    - name: "alice"
+      password: "secret"
+    - name: "oidc:alice"
+      password: "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy"
+  access:
+    - users: ["alice"]
+      apps: ["*"]
+      role: "owner"
+server:
+  port: 70000
+`)
+	_, err := config.Load(path, nil, nil)
+	if err == nil {
+		t.Fatalf("expected the validation errors")
+	}
+	for _, expected := range []string{
+		"applications[0].color: invalid color 'blue;}'",
+		"applications[1].name: invalid name 'rclone gdrive'",
+		"applications[1].schedule: invalid schedule 'every day'",
+		"applications[2].name: the application 'rclone-gdrive' is defined twice",
+		"jobs[0].command: the command is required",
+		"jobs[0].lock: unknown lock policy 'never'",
+		"notification.routes[0].webhook: invalid URL 'hooks.example.com'",
+		"notification.routes[0].statuses: unknown status 'failed'",
+		"auth.users[0].password: expected a bcrypt hash",
+		"auth.users[1].name: the prefix 'oidc:' is reserved for the users of the OIDC provider",
+		"auth.access[0].role: unknown role 'owner'",
+		"server.port: invalid port 70000",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected the error '%s' in\n%v", expected, err)
+		}
+	}
+
+	// the values of the environment are validated as well
+	t.Setenv("CRONLOGGER_LOGFORMAT", "xml")
+	if _, err := config.Load(writeConfig(t, ""), nil, nil); err == nil || !strings.Contains(err.Error(), "server.logFormat: invalid logformat 'xml'") {
+		t.Errorf("expected the error of the environment, got %v", err)
+	}
+}
+
+func Test_Validate_Colors(t *testing.T) {
+	for color, valid := range map[string]bool{
+		"#4285F4":            true,
+		"#fff":               true,
+		"#4285F480":          true,
+		"teal":               true,
+		"rgb(66, 133, 244)":  true,
+		"#4285F":             false,
+		"red;display:none":   false,
+		"url(http://x.y/z)":  false,
+		"rgb(1,2,3)}body{x:": false,
+	} {
+		cfg, err := config.Load(writeConfig(t, ""), nil, nil)
+		if err != nil {
+			t.Fatalf("could not load the config; %v", err)
+		}
+		cfg.DefaultColor = color
+		if err := cfg.Validate(); (err == nil) != valid {
+			t.Errorf("%s: expected valid=%v, got %v", color, valid, err)
+		}
+	}
+}
+
+func Test_Validate_Backup(t *testing.T) {
+	content := `
+database:
+  dsn: "%s"
+backup:
+  dir: "/var/backups/cronlogger"
+`
+	if _, err := config.Load(writeConfig(t, fmt.Sprintf(content, "/var/cronlog/cronlog-store.db")), nil, nil); err != nil {
+		t.Errorf("expected the backups of a sqlite database to be valid, got %v", err)
+	}
+	_, err := config.Load(writeConfig(t, fmt.Sprintf(content, "postgres://cronlogger@localhost/cronlogger")), nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "backup.dir: the backups are only available for sqlite databases") {
+		t.Errorf("expected an error for the backups of a PostgreSQL database, got %v", err)
+	}
+}
+
+func Test_Load_Sections(t *testing.T) {
+	path := writeConfig(t, `
+database:
+  dsn: "/var/cronlog/file.db"
+server:
+  port: 70000
+auth:
+  sessionKey: "short"
+logger:
+  retries: -1
+`)
+	// the errors of the other sections are ignored, the settings are read nevertheless
+	cfg, err := config.Load(path, nil, nil, config.SectionDatabase, config.SectionApplications)
+	if err != nil {
+		t.Fatalf("expected the database to be valid, got %v", err)
+	}
+	if cfg.Database.DSN != "/var/cronlog/file.db" || cfg.Server.Port != 70000 {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+
+	_, err = config.Load(path, nil, nil, config.SectionDatabase, config.SectionLogger)
+	if err == nil || !strings.Contains(err.Error(), "logger.retries") || strings.Contains(err.Error(), "server.port") {
+		t.Errorf("expected only the error of the logger, got %v", err)
+	}
+
+	// without sections the whole file is validated
+	_, err = config.Load(path, nil, nil)
+	for _, expected := range []string{"server.port", "auth.sessionKey", "logger.retries"} {
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected the error of %s, got %v", expected, err)
+		}
+	}
+}
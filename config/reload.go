@@ -0,0 +1,208 @@
+package config
+
+import (
+	"context"
+	"cronlogger"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// a reload applies the applications, the default color and the notification routes while
+// the server is running. The other sections configure the components created at the start,
+// e.g. the database or the scheduler, their changes are logged and applied by a restart.
+// An invalid file is rejected as a whole, the previous configuration remains active.
+// The hooks are called while the components are in use, e.g. while requests are served
+// or runs are sent, the components therefore swap their configuration atomically.
+
+// watchDelay collects the events of an editor or a deployment which writes the file in several steps
+const watchDelay = 500 * time.Millisecond
+
+// Reloader reads the configuration file again and passes the changes on to the components
+type Reloader struct {
+	path   string
+	set    *flag.FlagSet
+	flags  Flags
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	current Config
+	hooks   []func(config cronlogger.AppConfig) error
+}
+
+// NewReloader creates the reloader of the loaded configuration, the path and the flags are
+// the arguments of Load. The file of the configuration is read again, not the result of a search.
+func NewReloader(path string, set *flag.FlagSet, flags Flags, current Config, logger *slog.Logger) *Reloader {
+	if current.File != "" {
+		path = current.File
+	}
+	return &Reloader{path: path, set: set, flags: flags, logger: logger, current: current}
+}
+
+// OnReload registers a hook which receives the configuration after a reload
+func (r *Reloader) OnReload(hook func(config cronlogger.AppConfig) error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks = append(r.hooks, hook)
+}
+
+// Current returns the active configuration
+func (r *Reloader) Current() Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// Reload reads and validates the configuration and applies the changes, the returned
+// changes describe the differences to the previous configuration
+func (r *Reloader) Reload() ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	loaded, err := Load(r.path, r.set, r.flags)
+	if err != nil {
+		r.logger.Error("could not reload the configuration, the previous configuration remains active", "error", err)
+		return nil, err
+	}
+	changes := Changes(r.current, loaded)
+	if len(changes) == 0 {
+		r.logger.Info("reloaded the configuration, nothing changed", "file", loaded.File)
+		return []string{}, nil
+	}
+
+	// the sections of the components created at the start keep their values until the restart
+	next := loaded
+	next.Database = r.current.Database
+	next.Scheduler = r.current.Scheduler
+	next.Jobs = r.current.Jobs
+	next.Backup = r.current.Backup
+	next.Health = r.current.Health
+	next.Tracing = r.current.Tracing
+	next.Auth = r.current.Auth
+	next.Server = r.current.Server
+
+	var hookErr error
+	for _, hook := range r.hooks {
+		if err := hook(next.AppConfig); err != nil {
+			hookErr = err
+			r.logger.Error("could not apply the reloaded configuration", "error", err)
+		}
+	}
+	r.current = next
+	for _, change := range changes {
+		r.logger.Info("reloaded the configuration", "file", loaded.File, "change", change)
+	}
+	return changes, hookErr
+}
+
+// section is a part of the configuration compared by Changes
+type section struct {
+	name     string
+	old, new any
+	restart  bool
+}
+
+// Changes describes the differences between the configurations. The values of the sections
+// are not part of the description, they may contain secrets.
+func Changes(old, new Config) []string {
+	var changes []string
+	for _, app := range new.Applications {
+		prev, ok := old.Application(app.Name)
+		switch {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("applications: added '%s'", app.Name))
+		case !reflect.DeepEqual(prev, app):
+			changes = append(changes, fmt.Sprintf("applications: changed '%s'", app.Name))
+		}
+	}
+	for _, app := range old.Applications {
+		if _, ok := new.Application(app.Name); !ok {
+			changes = append(changes, fmt.Sprintf("applications: removed '%s'", app.Name))
+		}
+	}
+	if old.DefaultColor != new.DefaultColor {
+		changes = append(changes, fmt.Sprintf("defaultColor: '%s' -> '%s'", old.DefaultColor, new.DefaultColor))
+	}
+
+	for _, s := range []section{
+		{"notification", old.Notification, new.Notification, false},
+		{"logger", old.Logger, new.Logger, false},
+		{"database", old.Database, new.Database, true},
+		{"scheduler", old.Scheduler, new.Scheduler, true},
+		{"jobs", old.Jobs, new.Jobs, true},
+		{"backup", old.Backup, new.Backup, true},
+		{"health", old.Health, new.Health, true},
+		{"tracing", old.Tracing, new.Tracing, true},
+		{"auth", old.Auth, new.Auth, true},
+		{"server", old.Server, new.Server, true},
+	} {
+		if reflect.DeepEqual(s.old, s.new) {
+			continue
+		}
+		if s.restart {
+			changes = append(changes, fmt.Sprintf("%s: changed, the change requires a restart", s.name))
+		} else {
+			changes = append(changes, fmt.Sprintf("%s: changed", s.name))
+		}
+	}
+	return changes
+}
+
+// Watch reloads the configuration once the file is changed until the context is done.
+// The directory is watched, editors and Kubernetes replace the file instead of writing it.
+func (r *Reloader) Watch(ctx context.Context) error {
+	file := r.Current().File
+	if file == "" {
+		r.logger.Info("no configuration file to watch")
+		return nil
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("could not watch the configuration file; %v", err)
+	}
+	defer watcher.Close()
+	dir := filepath.Dir(file)
+	if err := watcher.Add(dir); err != nil {
+		return fmt.Errorf("could not watch the directory '%s'; %v", dir, err)
+	}
+
+	// a symlinked file changes its target instead of its content
+	target, _ := filepath.EvalSymlinks(file)
+	delay := time.NewTimer(watchDelay)
+	delay.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			current, _ := filepath.EvalSymlinks(file)
+			written := filepath.Clean(event.Name) == file && (event.Has(fsnotify.Write) || event.Has(fsnotify.Create))
+			if written || (current != "" && current != target) {
+				target = current
+				delay.Reset(watchDelay)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			r.logger.Warn("could not watch the configuration file", "error", err)
+		case <-delay.C:
+			if _, err := os.Stat(file); err != nil {
+				r.logger.Warn("the configuration file is not available", "file", file, "error", err)
+				continue
+			}
+			// the error is logged by the reload
+			_, _ = r.Reload()
+		}
+	}
+}
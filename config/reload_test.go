@@ -0,0 +1,145 @@
+package config_test
+
+import (
+	"context"
+	"cronlogger"
+	"cronlogger/config"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+const initialConfig = `
+applications:
+  - name: "rclone-gdrive"
+    color: "#4285F4"
+  - name: "acme-tls"
+database:
+  dsn: "/var/cronlog/cronlog-store.db"
+`
+
+func Test_Reload(t *testing.T) {
+	path := writeConfig(t, initialConfig)
+	cfg, err := config.Load(path, nil, nil)
+	if err != nil {
+		t.Fatalf("could not load the config; %v", err)
+	}
+	r := config.NewReloader(path, nil, nil, cfg, logger)
+	var applied []cronlogger.AppConfig
+	r.OnReload(func(config cronlogger.AppConfig) error {
+		applied = append(applied, config)
+		return nil
+	})
+
+	// without changes the hooks are not called
+	if changes, err := r.Reload(); err != nil || len(changes) != 0 || len(applied) != 0 {
+		t.Errorf("expected no changes, got %v; %v", changes, err)
+	}
+
+	if err := os.WriteFile(path, []byte(`
+applications:
+  - name: "rclone-gdrive"
+    color: "#ff6200"
+  - name: "rclone-aws"
+defaultColor: "#212529"
+database:
+  dsn: "postgres://cronlogger@localhost/cronlogger"
+notification:
+  routes:
+    - webhook: "https://chat.example.com/hooks/abc"
+`), 0o600); err != nil {
+		t.Fatalf("could not write the config; %v", err)
+	}
+	changes, err := r.Reload()
+	if err != nil {
+		t.Fatalf("could not reload the config; %v", err)
+	}
+	expected := []string{
+		"applications: changed 'rclone-gdrive'",
+		"applications: added 'rclone-aws'",
+		"applications: removed 'acme-tls'",
+		"defaultColor: '' -> '#212529'",
+		"notification: changed",
+		"database: changed, the change requires a restart",
+	}
+	if !slices.Equal(changes, expected) {
+		t.Errorf("expected the changes\n%v, got\n%v", strings.Join(expected, "\n"), strings.Join(changes, "\n"))
+	}
+	if len(applied) != 1 {
+		t.Fatalf("expected the reloaded config to be applied once, got %d", len(applied))
+	}
+	app, _ := applied[0].Application("rclone-gdrive")
+	if app.Color != "#ff6200" || len(applied[0].Notification.Routes) != 1 {
+		t.Errorf("expected the reloaded applications and routes, got %+v", applied[0])
+	}
+	// the database is used until the restart
+	if applied[0].Database.DSN != "/var/cronlog/cronlog-store.db" {
+		t.Errorf("expected the database of the start, got '%s'", applied[0].Database.DSN)
+	}
+
+	// an invalid config is not applied
+	if err := os.WriteFile(path, []byte(`defaultColor: "#12"`), 0o600); err != nil {
+		t.Fatalf("could not write the config; %v", err)
+	}
+	if _, err := r.Reload(); err == nil || !strings.Contains(err.Error(), "defaultColor: invalid color '#12'") {
+		t.Errorf("expected the validation error, got %v", err)
+	}
+	if len(applied) != 1 || r.Current().DefaultColor != "#212529" {
+		t.Errorf("expected the previous config to remain active")
+	}
+}
+
+func Test_Watch(t *testing.T) {
+	path := writeConfig(t, initialConfig)
+	cfg, err := config.Load(path, nil, nil)
+	if err != nil {
+		t.Fatalf("could not load the config; %v", err)
+	}
+	r := config.NewReloader("", nil, nil, cfg, logger)
+	var (
+		mu      sync.Mutex
+		applied []cronlogger.AppConfig
+	)
+	r.OnReload(func(config cronlogger.AppConfig) error {
+		mu.Lock()
+		defer mu.Unlock()
+		applied = append(applied, config)
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error)
+	go func() {
+		done <- r.Watch(ctx)
+	}()
+	// the watcher is set up asynchronously
+	time.Sleep(100 * time.Millisecond)
+
+	if err := os.WriteFile(path, []byte(initialConfig+"defaultColor: \"#000\"\n"), 0o600); err != nil {
+		t.Fatalf("could not write the config; %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		mu.Lock()
+		n := len(applied)
+		mu.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("could not watch the config; %v", err)
+	}
+	if len(applied) != 1 || applied[0].DefaultColor != "#000" {
+		t.Errorf("expected the changed file to be reloaded once, got %d reloads", len(applied))
+	}
+}
@@ -0,0 +1,300 @@
+package config
+
+import (
+	"cronlogger"
+	"cronlogger/auth"
+	"cronlogger/store"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// the validation reports all errors of the configuration at once, every error names the
+// setting like applications[1].color, the errors can therefore be fixed in a single pass
+
+// validName allows the characters used in URLs, file names and metric labels
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// maxName is the size of the application column
+const maxName = 255
+
+// validColor allows hex colors, color names and the rgb/hsl functions of CSS
+var validColor = regexp.MustCompile(`^(#([0-9a-fA-F]{3,4}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})|[a-zA-Z]+|(rgb|rgba|hsl|hsla)\([0-9a-z.,%/ ]+\))$`)
+
+var statuses = []string{string(store.StatusSuccess), string(store.StatusFailure), string(store.StatusTimeout), string(store.StatusSkipped)}
+
+type validator struct {
+	errs []error
+}
+
+func (v *validator) add(field, format string, args ...any) {
+	v.errs = append(v.errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+}
+
+func (v *validator) name(field, name string) {
+	switch {
+	case name == "":
+		v.add(field, "the name is required")
+	case len(name) > maxName:
+		v.add(field, "the name '%s' exceeds %d characters", name, maxName)
+	case !validName.MatchString(name):
+		v.add(field, "invalid name '%s', expected letters, digits, '.', '_' and '-'", name)
+	}
+}
+
+func (v *validator) color(field, color string) {
+	if color != "" && !validColor.MatchString(color) {
+		v.add(field, "invalid color '%s', expected a hex color like #4285F4, a color name or rgb(...)", color)
+	}
+}
+
+func (v *validator) schedule(field, schedule string) {
+	if schedule == "" {
+		return
+	}
+	if _, err := cron.ParseStandard(schedule); err != nil {
+		v.add(field, "invalid schedule '%s'; %v", schedule, err)
+	}
+}
+
+func (v *validator) duration(field string, d time.Duration) {
+	if d < 0 {
+		v.add(field, "negative duration %v", d)
+	}
+}
+
+func (v *validator) notNegative(field string, n int) {
+	if n < 0 {
+		v.add(field, "negative value %d", n)
+	}
+}
+
+func (v *validator) url(field, value string) {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.add(field, "invalid URL '%s', expected http:// or https://", value)
+	}
+}
+
+func (v *validator) lock(field, lock string) {
+	if _, err := cronlogger.ParseLockPolicy(lock); err != nil {
+		v.add(field, "%v", err)
+	}
+}
+
+// Section is a part of the configuration, e.g. the logger binary only validates the sections it uses
+type Section string
+
+const (
+	SectionApplications Section = "applications"
+	SectionDatabase     Section = "database"
+	SectionJobs         Section = "jobs"
+	SectionBackup       Section = "backup"
+	SectionHealth       Section = "health"
+	SectionTracing      Section = "tracing"
+	SectionNotification Section = "notification"
+	SectionAuth         Section = "auth"
+	SectionServer       Section = "server"
+	SectionLogger       Section = "logger"
+)
+
+// Validate checks the settings of the sections, all sections without a section. The returned error joins all errors.
+func (c Config) Validate(sections ...Section) error {
+	v := &validator{}
+	validate := func(section Section, check func(*validator)) {
+		if len(sections) == 0 || slices.Contains(sections, section) {
+			check(v)
+		}
+	}
+	validate(SectionApplications, c.validateApplications)
+	validate(SectionDatabase, c.validateDatabase)
+	validate(SectionJobs, c.validateJobs)
+	validate(SectionBackup, c.validateBackup)
+	validate(SectionHealth, c.validateHealth)
+	validate(SectionTracing, c.validateTracing)
+	validate(SectionNotification, c.validateNotification)
+	validate(SectionAuth, c.validateAuth)
+	validate(SectionServer, c.validateServer)
+	validate(SectionLogger, c.validateLogger)
+	return errors.Join(v.errs...)
+}
+
+func (c Config) validateApplications(v *validator) {
+	v.color("defaultColor", c.DefaultColor)
+
+	names := map[string]bool{}
+	for i, app := range c.Applications {
+		field := fmt.Sprintf("applications[%d]", i)
+		v.name(field+".name", app.Name)
+		if names[app.Name] {
+			v.add(field+".name", "the application '%s' is defined twice", app.Name)
+		}
+		names[app.Name] = true
+		v.color(field+".color", app.Color)
+		v.schedule(field+".schedule", app.Schedule)
+		if job, ok := c.Job(app.Name); ok && app.Schedule != "" && job.Schedule != "" {
+			v.add(field+".schedule", "the application '%s' is a scheduled job, the schedule is defined by the job", app.Name)
+		}
+		v.duration(field+".grace", app.Grace)
+	}
+}
+
+func (c Config) validateDatabase(v *validator) {
+	if c.Database.DSN == "" {
+		v.add("database.dsn", "the path of the db file or a PostgreSQL URL is required")
+	}
+	v.duration("database.queryTimeout", c.Database.QueryTimeout)
+}
+
+// validateJobs checks the jobs and their scheduler
+func (c Config) validateJobs(v *validator) {
+	v.notNegative("scheduler.maxConcurrency", c.Scheduler.MaxConcurrency)
+	jobs := map[string]bool{}
+	for i, job := range c.Jobs {
+		field := fmt.Sprintf("jobs[%d]", i)
+		v.name(field+".name", job.Name)
+		if jobs[job.Name] {
+			v.add(field+".name", "the job '%s' is defined twice", job.Name)
+		}
+		jobs[job.Name] = true
+		if strings.TrimSpace(job.Command) == "" {
+			v.add(field+".command", "the command is required")
+		}
+		v.schedule(field+".schedule", job.Schedule)
+		v.duration(field+".timeout", job.Timeout)
+		v.notNegative(field+".retries", job.Retries)
+		v.duration(field+".retryDelay", job.RetryDelay)
+		v.lock(field+".lock", job.Lock)
+	}
+}
+
+func (c Config) validateBackup(v *validator) {
+	v.schedule("backup.schedule", c.Backup.Schedule)
+	v.notNegative("backup.keep", c.Backup.Keep)
+	v.duration("backup.maxAge", c.Backup.MaxAge)
+	if c.Backup.Dir != "" && store.IsPostgresDsn(c.Database.DSN) {
+		v.add("backup.dir", "the backups are only available for sqlite databases, use pg_dump for PostgreSQL")
+	}
+}
+
+func (c Config) validateHealth(v *validator) {
+	v.notNegative("health.minFreeMB", c.Health.MinFreeMB)
+}
+
+func (c Config) validateTracing(v *validator) {
+	if c.Tracing.Endpoint != "" {
+		v.url("tracing.endpoint", c.Tracing.Endpoint)
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		v.add("tracing.sampleRatio", "invalid ratio %v, expected a value between 0 and 1", c.Tracing.SampleRatio)
+	}
+}
+
+func (c Config) validateNotification(v *validator) {
+	v.duration("notification.timeout", c.Notification.Timeout)
+	for i, route := range c.Notification.Routes {
+		field := fmt.Sprintf("notification.routes[%d]", i)
+		if route.Webhook == "" {
+			v.add(field+".webhook", "the URL of the webhook is required")
+		} else {
+			v.url(field+".webhook", route.Webhook)
+		}
+		for _, status := range route.Statuses {
+			if !slices.Contains(statuses, status) {
+				v.add(field+".statuses", "unknown status '%s', expected %s", status, strings.Join(statuses, ", "))
+			}
+		}
+		if slices.Contains(route.Apps, "") {
+			v.add(field+".apps", "empty application pattern")
+		}
+	}
+}
+
+func (c Config) validateServer(v *validator) {
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		v.add("server.port", "invalid port %d, expected 1-65535", c.Server.Port)
+	}
+	if !slices.Contains([]string{"DEBUG", "INFO", "WARN", "ERROR"}, strings.ToUpper(c.Server.LogLevel)) {
+		v.add("server.logLevel", "invalid loglevel '%s', expected DEBUG, INFO, WARN or ERROR", c.Server.LogLevel)
+	}
+	if !slices.Contains([]string{"text", "json"}, strings.ToLower(c.Server.LogFormat)) {
+		v.add("server.logFormat", "invalid logformat '%s', expected text or json", c.Server.LogFormat)
+	}
+}
+
+func (c Config) validateLogger(v *validator) {
+	v.duration("logger.timeout", c.Logger.Timeout)
+	v.duration("logger.grace", c.Logger.Grace)
+	v.lock("logger.lock", c.Logger.Lock)
+	v.notNegative("logger.retries", c.Logger.Retries)
+	v.duration("logger.retryDelay", c.Logger.RetryDelay)
+	if c.Logger.RetryBackoff < 1 {
+		v.add("logger.retryBackoff", "invalid factor %v, expected at least 1", c.Logger.RetryBackoff)
+	}
+}
+
+func (c Config) validateAuth(v *validator) {
+	users := map[string]bool{}
+	for i, user := range c.Auth.Users {
+		field := fmt.Sprintf("auth.users[%d]", i)
+		if user.Name == "" {
+			v.add(field+".name", "the name is required")
+		}
+		if users[user.Name] {
+			v.add(field+".name", "the user '%s' is defined twice", user.Name)
+		}
+		if strings.HasPrefix(user.Name, auth.OIDCPrefix) {
+			v.add(field+".name", "the prefix '%s' is reserved for the users of the OIDC provider", auth.OIDCPrefix)
+		}
+		users[user.Name] = true
+		if _, err := bcrypt.Cost([]byte(user.Password)); err != nil {
+			v.add(field+".password", "expected a bcrypt hash, see the hash-password subcommand")
+		}
+	}
+	for i, token := range c.Auth.Tokens {
+		field := fmt.Sprintf("auth.tokens[%d]", i)
+		if token.Name == "" {
+			v.add(field+".name", "the name is required")
+		}
+		if strings.HasPrefix(token.Name, auth.OIDCPrefix) {
+			v.add(field+".name", "the prefix '%s' is reserved for the users of the OIDC provider", auth.OIDCPrefix)
+		}
+		if len(token.Token) < 16 {
+			v.add(field+".token", "the token needs at least 16 characters")
+		}
+	}
+	if key := c.Auth.SessionKey; key != "" && len(key) < 16 {
+		v.add("auth.sessionKey", "the session key needs at least 16 characters")
+	}
+	v.duration("auth.sessionTTL", c.Auth.SessionTTL)
+	if oidc := c.Auth.OIDC; oidc.Issuer != "" {
+		v.url("auth.oidc.issuer", oidc.Issuer)
+		if oidc.ClientID == "" {
+			v.add("auth.oidc.clientID", "the client ID is required")
+		}
+		if oidc.RedirectURL == "" {
+			v.add("auth.oidc.redirectURL", "the redirect URL is required")
+		} else {
+			v.url("auth.oidc.redirectURL", oidc.RedirectURL)
+		}
+	}
+	for i, rule := range c.Auth.Access {
+		field := fmt.Sprintf("auth.access[%d]", i)
+		if _, err := auth.ParseRole(rule.Role); err != nil {
+			v.add(field+".role", "%v", err)
+		}
+		if len(rule.Users) == 0 && len(rule.Groups) == 0 {
+			v.add(field, "the rule needs users or groups")
+		}
+		if len(rule.Apps) == 0 || slices.Contains(rule.Apps, "") {
+			v.add(field+".apps", "the rule needs applications")
+		}
+	}
+}
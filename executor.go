@@ -0,0 +1,167 @@
+package cronlogger
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// DefaultGracePeriod is the time a timed-out command gets to shut down after SIGTERM
+const DefaultGracePeriod = 10 * time.Second
+
+// ExecParams defines the command to execute and how long it may run
+type ExecParams struct {
+	// Command is the executable and its arguments
+	Command []string
+	// Dir is the working directory of the command, defaults to the current directory
+	Dir string
+	// Env holds additional environment variables in the form KEY=value
+	Env []string
+	// Timeout is the maximum runtime of the command, 0 means no timeout
+	Timeout time.Duration
+	// GracePeriod is the time between SIGTERM and SIGKILL if the timeout is reached
+	GracePeriod time.Duration
+	// OnStart is called with the pid of the command once it is started, optional
+	OnStart func(pid int)
+}
+
+// ExecResult holds the outcome of an executed command
+type ExecResult struct {
+	// Output is the combined stdout/stderr of the command
+	Output string
+	// ExitCode of the command, -1 if the command was terminated by a signal
+	ExitCode int
+	// TimedOut is set if the command was terminated because the timeout was reached
+	TimedOut bool
+	// Started is the time the command was started
+	Started time.Time
+}
+
+// Execute runs the given command and captures its combined output.
+// The command is started in its own process group. If the timeout is reached or the
+// context is canceled the whole group receives a SIGTERM, after the grace period
+// a SIGKILL follows. This way hanging child-processes (e.g. a blocked rclone mount)
+// do not block the execution forever.
+// An error is only returned if the command could not be started at all.
+func Execute(ctx context.Context, params ExecParams) (ExecResult, error) {
+	if len(params.Command) == 0 {
+		return ExecResult{}, fmt.Errorf("no command supplied")
+	}
+	grace := params.GracePeriod
+	if grace <= 0 {
+		grace = DefaultGracePeriod
+	}
+
+	if params.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, params.Timeout)
+		defer cancel()
+	}
+
+	var output bytes.Buffer
+	cmd := exec.Command(params.Command[0], params.Command[1:]...)
+	cmd.Dir = params.Dir
+	if len(params.Env) > 0 {
+		cmd.Env = append(os.Environ(), params.Env...)
+	}
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	// a child which left the process group (e.g. a daemon) can hold the output
+	// open after the command exited, Wait does not block longer than the grace period for it
+	cmd.WaitDelay = grace
+
+	started := time.Now()
+	if err := cmd.Start(); err != nil {
+		return ExecResult{}, fmt.Errorf("cannot start command '%s'; %v", params.Command[0], err)
+	}
+
+	if params.OnStart != nil {
+		params.OnStart(cmd.Process.Pid)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	var (
+		err      error
+		timedOut bool
+	)
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		timedOut = errors.Is(ctx.Err(), context.DeadlineExceeded)
+		// a negative pid addresses the whole process group
+		pgid := -cmd.Process.Pid
+		syscall.Kill(pgid, syscall.SIGTERM)
+		select {
+		case err = <-done:
+		case <-time.After(grace):
+			syscall.Kill(pgid, syscall.SIGKILL)
+			err = <-done
+		}
+	}
+
+	result := ExecResult{
+		Output:   output.String(),
+		TimedOut: timedOut,
+		Started:  started,
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		result.ExitCode = exitErr.ExitCode()
+	} else if err != nil && !errors.Is(err, exec.ErrWaitDelay) {
+		result.ExitCode = -1
+	}
+	return result, nil
+}
+
+// RetryParams defines how often a failed command is executed again
+type RetryParams struct {
+	// Retries is the number of additional executions after a failed execution
+	Retries int
+	// Delay is the wait time before the first retry
+	Delay time.Duration
+	// Backoff is the factor the delay is multiplied with after each retry, values <= 1 keep the delay constant
+	Backoff float64
+}
+
+// ExecuteWithRetries runs the command and retries it if it fails or times out.
+// A command which was terminated by a signal of another process (e.g. the kill-previous
+// policy of a newer run) or by the cancellation of the context is not retried.
+// The results of all attempts are returned in the order of execution.
+// An error is returned if the command could not be started at all or the
+// context was canceled while waiting for the next attempt.
+func ExecuteWithRetries(ctx context.Context, params ExecParams, retry RetryParams) ([]ExecResult, error) {
+	var results []ExecResult
+	delay := retry.Delay
+	for attempt := 0; ; attempt++ {
+		result, err := Execute(ctx, params)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, result)
+		if result.ExitCode == 0 && !result.TimedOut || attempt >= retry.Retries {
+			return results, nil
+		}
+		if ctx.Err() != nil || result.ExitCode == -1 && !result.TimedOut {
+			return results, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return results, fmt.Errorf("retry aborted; %v", ctx.Err())
+		case <-time.After(delay):
+		}
+		if retry.Backoff > 1 {
+			delay = time.Duration(float64(delay) * retry.Backoff)
+		}
+	}
+}
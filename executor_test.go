@@ -0,0 +1,208 @@
+package cronlogger_test
+
+import (
+	"context"
+	"cronlogger"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Execute_Success(t *testing.T) {
+	result, err := cronlogger.Execute(context.Background(), cronlogger.ExecParams{
+		Command: []string{"sh", "-c", "echo stdout; echo stderr 1>&2"},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if result.ExitCode != 0 {
+		t.Errorf("expected exit-code 0, got %d", result.ExitCode)
+	}
+	if result.TimedOut {
+		t.Errorf("the command should not time out")
+	}
+	if !strings.Contains(result.Output, "stdout") || !strings.Contains(result.Output, "stderr") {
+		t.Errorf("expected stdout and stderr in output, got %q", result.Output)
+	}
+}
+
+func Test_Execute_Failure(t *testing.T) {
+	result, err := cronlogger.Execute(context.Background(), cronlogger.ExecParams{
+		Command: []string{"sh", "-c", "exit 3"},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if result.ExitCode != 3 {
+		t.Errorf("expected exit-code 3, got %d", result.ExitCode)
+	}
+	if result.TimedOut {
+		t.Errorf("the command should not time out")
+	}
+}
+
+func Test_Execute_UnknownCommand(t *testing.T) {
+	_, err := cronlogger.Execute(context.Background(), cronlogger.ExecParams{
+		Command: []string{"/no/such/command"},
+	})
+	if err == nil {
+		t.Errorf("expected an error for an unknown command")
+	}
+
+	_, err = cronlogger.Execute(context.Background(), cronlogger.ExecParams{})
+	if err == nil {
+		t.Errorf("expected an error for an empty command")
+	}
+}
+
+func Test_Execute_Timeout(t *testing.T) {
+	start := time.Now()
+	result, err := cronlogger.Execute(context.Background(), cronlogger.ExecParams{
+		Command:     []string{"sh", "-c", "echo started; sleep 30"},
+		Timeout:     200 * time.Millisecond,
+		GracePeriod: time.Second,
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if !result.TimedOut {
+		t.Errorf("expected the command to time out")
+	}
+	if result.ExitCode == 0 {
+		t.Errorf("expected a non-zero exit-code for a terminated command")
+	}
+	if !strings.Contains(result.Output, "started") {
+		t.Errorf("expected the output before the timeout, got %q", result.Output)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("the command was not terminated in time")
+	}
+}
+
+func Test_Execute_Timeout_KillProcessGroup(t *testing.T) {
+	// the shell and its child ignore SIGTERM, only the SIGKILL of the whole
+	// process group ends the execution
+	start := time.Now()
+	result, err := cronlogger.Execute(context.Background(), cronlogger.ExecParams{
+		Command:     []string{"sh", "-c", "trap '' TERM; sleep 30 & wait"},
+		Timeout:     200 * time.Millisecond,
+		GracePeriod: 300 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if !result.TimedOut {
+		t.Errorf("expected the command to time out")
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("the process group was not killed in time")
+	}
+}
+
+func Test_Execute_DetachedChild(t *testing.T) {
+	// the child leaves the process group and keeps the output open
+	start := time.Now()
+	result, err := cronlogger.Execute(context.Background(), cronlogger.ExecParams{
+		Command:     []string{"sh", "-c", "setsid sleep 30 & echo started"},
+		GracePeriod: 300 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if result.ExitCode != 0 || !strings.Contains(result.Output, "started") {
+		t.Errorf("expected the exit-code and the output of the command, got %d and %q", result.ExitCode, result.Output)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("the execution waited for the detached child")
+	}
+}
+
+func Test_ExecuteWithRetries(t *testing.T) {
+	// the command fails for the first two executions and succeeds afterwards
+	counter := t.TempDir() + "/counter"
+	script := "echo x >> " + counter + "; test $(wc -l < " + counter + ") -ge 3"
+
+	results, err := cronlogger.ExecuteWithRetries(context.Background(), cronlogger.ExecParams{
+		Command: []string{"sh", "-c", script},
+	}, cronlogger.RetryParams{
+		Retries: 5,
+		Delay:   10 * time.Millisecond,
+		Backoff: 2,
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 attempts, got %d", len(results))
+	}
+	if results[0].ExitCode == 0 || results[1].ExitCode == 0 || results[2].ExitCode != 0 {
+		t.Errorf("unexpected exit-codes of the attempts: %v", results)
+	}
+	if !results[0].Started.Before(results[1].Started) || !results[1].Started.Before(results[2].Started) {
+		t.Errorf("expected the start time of each attempt, got %v", results)
+	}
+
+	// no retries
+	results, err = cronlogger.ExecuteWithRetries(context.Background(), cronlogger.ExecParams{
+		Command: []string{"sh", "-c", "exit 1"},
+	}, cronlogger.RetryParams{})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("expected 1 attempt, got %d", len(results))
+	}
+
+	// all retries fail
+	results, err = cronlogger.ExecuteWithRetries(context.Background(), cronlogger.ExecParams{
+		Command: []string{"sh", "-c", "exit 1"},
+	}, cronlogger.RetryParams{Retries: 2, Delay: time.Millisecond})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(results) != 3 {
+		t.Errorf("expected 3 attempts, got %d", len(results))
+	}
+
+	// a canceled context aborts the retries
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	results, err = cronlogger.ExecuteWithRetries(ctx, cronlogger.ExecParams{
+		Command: []string{"sh", "-c", "exit 1"},
+	}, cronlogger.RetryParams{Retries: 2, Delay: time.Minute})
+	if err == nil {
+		t.Errorf("expected an error for a canceled retry")
+	}
+	if len(results) != 1 {
+		t.Errorf("expected 1 attempt, got %d", len(results))
+	}
+
+	// a command terminated by a signal is not retried
+	results, err = cronlogger.ExecuteWithRetries(context.Background(), cronlogger.ExecParams{
+		Command: []string{"sh", "-c", "kill -TERM $$"},
+	}, cronlogger.RetryParams{Retries: 2, Delay: time.Millisecond})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(results) != 1 || results[0].ExitCode != -1 {
+		t.Errorf("expected 1 attempt terminated by the signal, got %v", results)
+	}
+
+	// a command interrupted by the cancellation of the context is not retried
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	results, err = cronlogger.ExecuteWithRetries(ctx, cronlogger.ExecParams{
+		Command: []string{"sh", "-c", "trap 'exit 1' TERM; sleep 30 & wait"},
+	}, cronlogger.RetryParams{Retries: 2, Delay: time.Millisecond})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("expected 1 attempt, got %d", len(results))
+	}
+}
@@ -0,0 +1,211 @@
+package handler
+
+import (
+	"cronlogger/auth"
+	"cronlogger/store"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+)
+
+const limitParamName = "limit"
+const maxApiPageSize = 200
+
+// maxIngestSize limits the body of a pushed execution
+const maxIngestSize = 10 << 20
+
+// RunItem is the JSON representation of an execution
+type RunItem = store.RunRecord
+
+// RunList is a page of executions, the nextCursor is used to retrieve the following page
+type RunList struct {
+	Items      []RunItem `json:"items"`
+	NextCursor string    `json:"nextCursor,omitempty"`
+}
+
+// RunInput is an execution pushed by a host
+type RunInput struct {
+	App string `json:"application"`
+	// Status is success, failure, timeout or skipped
+	Status store.RunStatus `json:"status"`
+	Output string          `json:"output"`
+	// Host is the origin of the execution, it defaults to the name of the token
+	Host string `json:"host,omitempty"`
+}
+
+// ConfigReload lists the changes applied by a reload of the configuration
+type ConfigReload struct {
+	Changes []string `json:"changes"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// ApiRuns provides the executions as JSON, the pages are retrieved via opaque cursor tokens
+// GET /cronlogger/api/runs?cursor=&limit=&from=&until=&application=
+func (c *CronLogHandler) ApiRuns() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		limit := defaultPageSize
+		if limitParam := query.Get(limitParamName); limitParam != "" {
+			l, err := strconv.Atoi(limitParam)
+			if err != nil || l <= 0 || l > maxApiPageSize {
+				writeJsonError(w, http.StatusBadRequest, fmt.Sprintf("the limit needs to be between 1 and %d", maxApiPageSize))
+				return
+			}
+			limit = l
+		}
+
+		from, until, err := store.ParseDayRange(query.Get(dateFromParamName), query.Get(dateUntilParamName))
+		if err != nil {
+			writeJsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		result, err := c.store.GetCursorItems(r.Context(), limit, query.Get(cursorParamName), from, until, query.Get(applicationParamName))
+		if err != nil {
+			if errors.Is(err, store.ErrInvalidCursor) {
+				writeJsonError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			if c.aborted(r) {
+				return
+			}
+			c.log(r).Error("could not get items from store", "error", err)
+			writeJsonError(w, http.StatusInternalServerError, fmt.Sprintf("could not get items from store; %v", err))
+			return
+		}
+
+		list := RunList{
+			Items:      make([]RunItem, 0, len(result.Items)),
+			NextCursor: result.NextCursor,
+		}
+		for _, item := range result.Items {
+			list.Items = append(list.Items, store.RecordOf(item))
+		}
+		writeJson(w, http.StatusOK, list)
+	}
+}
+
+// IngestRun stores an execution pushed by a host, the token needs the ingest scope of the application
+// POST /cronlogger/api/runs
+func (c *CronLogHandler) IngestRun() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var input RunInput
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxIngestSize)).Decode(&input); err != nil {
+			writeJsonError(w, http.StatusBadRequest, fmt.Sprintf("invalid execution; %v", err))
+			return
+		}
+		if input.App == "" {
+			writeJsonError(w, http.StatusBadRequest, "the execution needs an application")
+			return
+		}
+		switch input.Status {
+		case store.StatusSuccess, store.StatusFailure, store.StatusTimeout, store.StatusSkipped:
+		default:
+			writeJsonError(w, http.StatusBadRequest, fmt.Sprintf("invalid status '%s', expected success, failure, timeout or skipped", input.Status))
+			return
+		}
+		if c.auth != nil && !c.auth.Ingest(r.Context(), input.App) {
+			c.log(r).Warn("the user may not store executions of the application", "user", triggeringUser(r), "app", input.App)
+			writeJsonError(w, http.StatusForbidden, fmt.Sprintf("you may not store executions of '%s'", input.App))
+			return
+		}
+		if p, ok := auth.FromContext(r.Context()); ok && input.Host == "" {
+			input.Host = p.Name
+		}
+
+		item, err := c.store.Create(r.Context(), store.OpResultEntity{
+			App:    input.App,
+			Status: input.Status,
+			Output: input.Output,
+			Host:   input.Host,
+		})
+		if err != nil {
+			if c.aborted(r) {
+				return
+			}
+			c.log(r).Error("could not store the execution", "app", input.App, "error", err)
+			writeJsonError(w, http.StatusInternalServerError, fmt.Sprintf("could not store the execution; %v", err))
+			return
+		}
+		writeJson(w, http.StatusCreated, store.RecordOf(item))
+	}
+}
+
+// ReloadConfig reads the configuration file again and applies the changes, e.g. after a deployment
+// POST /cronlogger/api/config/reload
+func (c *CronLogHandler) ReloadConfig() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if c.auth != nil && !c.auth.Admin(r.Context()) {
+			c.log(r).Warn("the user may not reload the configuration", "user", triggeringUser(r))
+			writeJsonError(w, http.StatusForbidden, "you may not administrate the server")
+			return
+		}
+		if c.reloader == nil {
+			writeJsonError(w, http.StatusNotFound, "the configuration cannot be reloaded")
+			return
+		}
+		changes, err := c.reloader.Reload()
+		if err != nil {
+			// the reloader logs the error, the previous configuration remains active
+			writeJsonError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		c.log(r).Info("reloaded the configuration", "user", triggeringUser(r), "changes", len(changes))
+		writeJson(w, http.StatusOK, ConfigReload{Changes: changes})
+	}
+}
+
+// Export streams the executions matching the filters as CSV or JSON Lines download
+// GET /cronlogger/api/export/{format}?from=&until=&application=
+func (c *CronLogHandler) Export() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format, err := store.ParseExportFormat(r.PathValue("format"))
+		if err != nil {
+			writeJsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		query := r.URL.Query()
+		from, until, err := store.ParseDayRange(query.Get(dateFromParamName), query.Get(dateUntilParamName))
+		if err != nil {
+			writeJsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		appName := query.Get(applicationParamName)
+
+		fileName := "cronlogger-runs"
+		if appName != "" {
+			fileName += "-" + appName
+		}
+		w.Header().Set("Content-Type", format.ContentType())
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fmt.Sprintf("%s.%s", fileName, format)}))
+
+		// the status is already sent once the first items are written, a failed export is only logged
+		count, err := store.Export(r.Context(), c.store, w, format, from, until, appName)
+		if err != nil {
+			if c.aborted(r) {
+				return
+			}
+			c.log(r).Error("the export failed", "items", count, "error", err)
+			return
+		}
+		c.log(r).Debug("exported the items", "items", count, "format", format)
+	}
+}
+
+func writeJson[T any](w http.ResponseWriter, status int, data T) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write([]byte(Json(data)))
+}
+
+func writeJsonError(w http.ResponseWriter, status int, message string) {
+	writeJson(w, status, apiError{Error: message})
+}
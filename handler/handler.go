@@ -1,35 +1,118 @@
 package handler
 
 import (
+	"context"
 	"cronlogger"
+	"cronlogger/auth"
+	"cronlogger/backup"
 	"cronlogger/handler/html"
+	"cronlogger/health"
 	"cronlogger/store"
+	"cronlogger/tracing"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
+
+	"github.com/a-h/templ"
 )
 
+// JobRunner starts configured jobs on demand
+type JobRunner interface {
+	Trigger(ctx context.Context, name, triggeredBy string) (store.OpResultEntity, error)
+}
+
+// BackupRunner creates and lists the backups of the database
+type BackupRunner interface {
+	Backup(ctx context.Context) (backup.Snapshot, error)
+	Status() (backup.Status, error)
+}
+
+// TokenManager creates and revokes the API tokens of the token store
+type TokenManager interface {
+	Create(ctx context.Context, name string, scopes []string, ttl time.Duration, createdBy string) (string, store.APITokenEntity, error)
+	List(ctx context.Context) ([]store.APITokenEntity, error)
+	Revoke(ctx context.Context, id string) error
+}
+
+// ConfigReloader reads the configuration again and returns the applied changes
+type ConfigReloader interface {
+	Reload() ([]string, error)
+}
+
+// ReadinessChecker checks the dependencies of the server, e.g. the database
+type ReadinessChecker interface {
+	Ready(ctx context.Context) health.Report
+}
+
 // CronLogHandler is used to visualize the content of
 // the cronlogger store via HTML templates
 type CronLogHandler struct {
-	store   store.OpResultStore
-	logger  *slog.Logger
-	version string
-	config  cronlogger.AppConfig
+	store    store.OpResultStore
+	runner   JobRunner
+	backups  BackupRunner
+	tokens   TokenManager
+	metrics  http.Handler
+	health   ReadinessChecker
+	auth     *auth.Service
+	logger   *slog.Logger
+	version  string
+	config   atomic.Pointer[cronlogger.AppConfig]
+	reloader ConfigReloader
+}
+
+// Options are the optional dependencies of the CronLogHandler, a missing dependency
+// disables the matching features
+type Options struct {
+	// Runner starts the jobs, without a runner jobs cannot be started via the UI
+	Runner JobRunner
+	// Backups creates the backups, without backups the admin page only shows the missing configuration
+	Backups BackupRunner
+	// Tokens manages the API tokens, without tokens the API tokens are not managed
+	Tokens TokenManager
+	// Metrics serves the /metrics endpoint, without metrics the endpoint is not available
+	Metrics http.Handler
+	// Health checks the dependencies, without a checker /readyz only reports that the server is running
+	Health ReadinessChecker
+	// Reloader reloads the configuration, without a reloader the configuration cannot be reloaded via the API
+	Reloader ConfigReloader
+	// Auth authenticates the requests, without authentication the login page redirects to the start page
+	Auth *auth.Service
 }
 
 // New returns a new instance of the CronLogHandler
-func New(store store.OpResultStore, logger *slog.Logger, version string, config cronlogger.AppConfig) *CronLogHandler {
-	return &CronLogHandler{
-		store:   store,
-		logger:  logger,
-		version: version,
-		config:  config,
+func New(store store.OpResultStore, logger *slog.Logger, version string, config cronlogger.AppConfig, opts Options) *CronLogHandler {
+	c := &CronLogHandler{
+		store:    store,
+		runner:   opts.Runner,
+		backups:  opts.Backups,
+		tokens:   opts.Tokens,
+		metrics:  opts.Metrics,
+		health:   opts.Health,
+		auth:     opts.Auth,
+		logger:   logger,
+		version:  version,
+		reloader: opts.Reloader,
 	}
+	c.SetConfig(config)
+	return c
+}
+
+// SetConfig replaces the configuration, e.g. the colors of the applications. It is safe to
+// call while requests are served.
+func (c *CronLogHandler) SetConfig(config cronlogger.AppConfig) {
+	c.config.Store(&config)
+}
+
+// appConfig returns the current configuration
+func (c *CronLogHandler) appConfig() cronlogger.AppConfig {
+	return *c.config.Load()
 }
 
 const defaultPageSize = 20
@@ -44,89 +127,85 @@ func (c *CronLogHandler) RedirectStart() http.HandlerFunc {
 // StartPage is the first page and displays items of the cronlogger store
 func (c *CronLogHandler) StartPage() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		c.logger.Info("serving the StartPage")
+		c.log(r).Info("serving the StartPage")
 
-		var skip int64
-		result, err := c.store.GetPagedItems(defaultPageSize, int(skip), nil, nil, "")
+		result, err := c.store.GetCursorItems(r.Context(), defaultPageSize, "", nil, nil, "")
 		if err != nil {
-			c.logger.Error(fmt.Sprintf("could not get items from store; %v", err))
+			if c.aborted(r) {
+				return
+			}
+			c.log(r).Error("could not get items from store", "error", err)
 			w.WriteHeader(http.StatusInternalServerError)
 			html.ErrorPageLayout(html.ErrorApplication("/", r, fmt.Sprintf("could not get items from store; %v", err))).Render(r.Context(), w)
 			return
 		}
 
-		apps, err := c.store.GetAvailApps()
+		apps, err := c.store.GetAvailApps(r.Context())
 		if err != nil {
-			c.logger.Error(fmt.Sprintf("could not get available apps from store; %v", err))
+			if c.aborted(r) {
+				return
+			}
+			c.log(r).Error("could not get available apps from store", "error", err)
 			w.WriteHeader(http.StatusInternalServerError)
 			html.ErrorPageLayout(html.ErrorApplication("/", r, fmt.Sprintf("could not get available apps from store; %v", err))).Render(r.Context(), w)
 			return
 		}
 
-		totalPages, currentPage := getPaginationInfo(result, int64(skip))
-		skip = skip + defaultPageSize
-
-		html.Layout(html.StartPage(result, c.config, apps, defaultPageSize, totalPages, currentPage, skip), c.version).Render(r.Context(), w)
+		c.render(w, r, "StartPage", html.Layout(html.StartPage(result, c.appConfig(), apps), c.version))
 	}
 }
 
-const skipParamName = "skip"
+const cursorParamName = "cursor"
+const offsetParamName = "offset"
 const dateFromParamName = "from"
 const dateUntilParamName = "until"
 const applicationParamName = "application"
-const dateFormat = "2006-01-02"
 
 // TableResult is used via htmx and only provides the table results
 func (c *CronLogHandler) TableResult() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		err := r.ParseForm()
 		if err != nil {
-			c.logger.Error(fmt.Sprintf("could not parse provided formdata; %v", err))
+			c.log(r).Error("could not parse provided formdata", "error", err)
 			w.WriteHeader(http.StatusInternalServerError)
 			html.ErrorPageLayout(html.ErrorApplication("/", r, fmt.Sprintf("could not get items from store; %v", err))).Render(r.Context(), w)
 			return
 		}
 
-		skipParam := r.FormValue(skipParamName)
+		cursorParam := r.FormValue(cursorParamName)
+		offsetParam := r.FormValue(offsetParamName)
 		fromParam := r.FormValue(dateFromParamName)
 		untilParam := r.FormValue(dateUntilParamName)
 		appParam := r.FormValue(applicationParamName)
 
-		var (
-			skip  int64
-			from  *time.Time
-			until *time.Time
-		)
-		if skipParam != "" {
-			s, err := strconv.Atoi(skipParam)
+		var offset int64
+		// the offset is only used to number the rows, the page is defined by the cursor
+		if offsetParam != "" {
+			o, err := strconv.ParseInt(offsetParam, 10, 64)
 			if err != nil {
-				c.logger.Warn(fmt.Sprintf("could not parse skip param: '%s'; %v", skipParam, err))
-				skip = 0
-			}
-			skip = int64(s)
-			if skip < 0 {
-				skip = 0
+				c.log(r).Warn("could not parse offset param", "offset", offsetParam, "error", err)
 			}
+			offset = max(o, 0)
 		}
 
-		if fromParam != "" {
-			from = parseDate(fromParam)
-		}
-		if untilParam != "" {
-			until = parseDate(untilParam)
+		from, until, err := store.ParseDayRange(fromParam, untilParam)
+		if err != nil {
+			c.log(r).Warn("could not parse the date range", "from", fromParam, "until", untilParam, "error", err)
+			fromParam, untilParam = "", ""
 		}
 
-		result, err := c.store.GetPagedItems(defaultPageSize, int(skip), getStartDate(from), getEndDate(until), appParam)
+		result, err := c.store.GetCursorItems(r.Context(), defaultPageSize, cursorParam, from, until, appParam)
 		if err != nil {
-			c.logger.Error(fmt.Sprintf("could not get items from store; %v", err))
+			if c.aborted(r) {
+				return
+			}
+			c.log(r).Error("could not get items from store", "error", err)
 			w.WriteHeader(http.StatusInternalServerError)
 			html.ErrorPageLayout(html.ErrorApplication("/", r, fmt.Sprintf("could not get items from store; %v", err))).Render(r.Context(), w)
 			return
 		}
 
-		skip = skip + defaultPageSize
-		totalPages, currentPage := getPaginationInfo(result, int64(skip))
-		html.TableResult(result, c.config, defaultPageSize, totalPages, currentPage, skip, formatDate(from), formatDate(until), appParam).Render(r.Context(), w)
+		c.render(w, r, "TableResult", html.TableResult(result, c.appConfig(), offset, fromParam, untilParam, appParam))
 	}
 }
 
@@ -160,7 +239,7 @@ func (c *CronLogHandler) ToggleOutputDetail() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		idParam := r.PathValue("id")
 		if idParam == "" {
-			c.logger.Error("no id param supplied")
+			c.log(r).Error("no id param supplied")
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
@@ -181,7 +260,7 @@ func (c *CronLogHandler) OutputDetail() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		idParam := r.PathValue("id")
 		if idParam == "" {
-			c.logger.Error("no id param supplied")
+			c.log(r).Error("no id param supplied")
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
@@ -193,64 +272,255 @@ func (c *CronLogHandler) OutputDetail() http.HandlerFunc {
 			toggle = false
 		}
 
-		item, err := c.store.GetById(idParam)
+		item, err := c.store.GetById(r.Context(), idParam)
 		if err != nil {
-			c.logger.Error("could not get item by id '%s'; %v", idParam, err)
+			if c.aborted(r) {
+				return
+			}
+			c.log(r).Error("could not get item by id", "id", idParam, "error", err)
 			w.WriteHeader(http.StatusNotFound)
 			return
 		}
 
-		html.OutputDetails(item, toggle).Render(r.Context(), w)
+		c.render(w, r, "OutputDetails", html.OutputDetails(item, toggle))
 	}
 }
 
-func getPaginationInfo(result store.PagedOpResults, skip int64) (totalPages, currentPage int64) {
-	totalPages = result.TotalCount / defaultPageSize
-	modPageSize := result.TotalCount % defaultPageSize
-	if modPageSize != 0 {
-		totalPages += 1
+// AppPage shows the latest executions of an application and its job configuration
+func (c *CronLogHandler) AppPage() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		appParam := r.PathValue("name")
+		c.log(r).Info("serving the AppPage", "app", appParam)
+		if !c.allowed(r, auth.RoleRead, appParam) {
+			c.log(r).Warn("the user may not read the application", "user", triggeringUser(r), "app", appParam)
+			w.WriteHeader(http.StatusNotFound)
+			html.ErrorPageLayout(html.ErrorApplication("/", r, fmt.Sprintf("unknown application '%s'", appParam))).Render(r.Context(), w)
+			return
+		}
+
+		result, err := c.store.GetCursorItems(r.Context(), defaultPageSize, "", nil, nil, appParam)
+		if err != nil {
+			if c.aborted(r) {
+				return
+			}
+			c.log(r).Error("could not get items from store", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			html.ErrorPageLayout(html.ErrorApplication("/", r, fmt.Sprintf("could not get items from store; %v", err))).Render(r.Context(), w)
+			return
+		}
+
+		config := c.appConfig()
+		job, hasJob := config.Job(appParam)
+		canRun := hasJob && c.runner != nil && c.allowed(r, auth.RoleTrigger, appParam)
+
+		c.render(w, r, "AppPage", html.Layout(html.AppPage(appParam, job, canRun, result, config), c.version))
 	}
-	currentPage = skip / defaultPageSize
-	if currentPage > 0 {
-		currentPage -= 1
+}
+
+// RunJob starts the job of an application and redirects to the page of the execution
+func (c *CronLogHandler) RunJob() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		appParam := r.PathValue("name")
+		if _, ok := c.appConfig().Job(appParam); !ok || c.runner == nil {
+			c.log(r).Warn("no job available for application", "app", appParam)
+			w.WriteHeader(http.StatusNotFound)
+			html.ErrorPageLayout(html.ErrorApplication("/", r, fmt.Sprintf("no job available for application '%s'", appParam))).Render(r.Context(), w)
+			return
+		}
+		if !c.allowed(r, auth.RoleTrigger, appParam) {
+			c.log(r).Warn("the user may not start the job", "user", triggeringUser(r), "app", appParam)
+			w.WriteHeader(http.StatusForbidden)
+			html.ErrorPageLayout(html.ErrorApplication("/", r, fmt.Sprintf("you may not start the job '%s'", appParam))).Render(r.Context(), w)
+			return
+		}
+
+		item, err := c.runner.Trigger(r.Context(), appParam, triggeringUser(r))
+		if err != nil {
+			if c.aborted(r) {
+				return
+			}
+			c.log(r).Error("could not start job", "app", appParam, "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			html.ErrorPageLayout(html.ErrorApplication("/", r, fmt.Sprintf("could not start job '%s'; %v", appParam, err))).Render(r.Context(), w)
+			return
+		}
+		http.Redirect(w, r, fmt.Sprintf("/cronlogger/Run/%s", item.ID), http.StatusSeeOther)
 	}
-	return
 }
 
-func parseDate(input string) *time.Time {
-	t, err := time.Parse(dateFormat, input)
-	if err != nil {
-		return nil
+// AdminPage shows the state of the backups and the API tokens
+func (c *CronLogHandler) AdminPage() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c.log(r).Info("serving the AdminPage")
+		if !c.admin(w, r) {
+			return
+		}
+		c.renderAdmin(w, r, http.StatusOK, html.TokenList{})
+	}
+}
+
+// renderAdmin shows the admin page, the tokens may contain a created token or an error
+func (c *CronLogHandler) renderAdmin(w http.ResponseWriter, r *http.Request, status int, tokens html.TokenList) {
+	var (
+		backupStatus backup.Status
+		statusErr    string
+	)
+	if c.backups != nil {
+		var err error
+		if backupStatus, err = c.backups.Status(); err != nil {
+			c.log(r).Error("could not get the backup status", "error", err)
+			statusErr = err.Error()
+		}
+	}
+	if c.tokens != nil {
+		tokens.Enabled = true
+		list, err := c.tokens.List(r.Context())
+		if err != nil {
+			c.log(r).Error("could not get the API tokens", "error", err)
+			if tokens.Error == "" {
+				tokens.Error = fmt.Sprintf("could not get the API tokens; %v", err)
+			}
+		}
+		tokens.Tokens = list
 	}
-	return &t
+	w.WriteHeader(status)
+	c.render(w, r, "AdminPage", html.Layout(html.AdminPage(backupStatus, c.backups != nil, statusErr, tokens), c.version))
 }
 
-func getEndDate(date *time.Time) *time.Time {
-	if date == nil {
-		return nil
+// CreateBackup creates a backup of the database on demand
+func (c *CronLogHandler) CreateBackup() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !c.admin(w, r) {
+			return
+		}
+		if c.backups == nil {
+			c.log(r).Warn("backups are not configured")
+			w.WriteHeader(http.StatusNotFound)
+			html.ErrorPageLayout(html.ErrorApplication("/", r, "backups are not configured")).Render(r.Context(), w)
+			return
+		}
+
+		if _, err := c.backups.Backup(r.Context()); err != nil {
+			if c.aborted(r) {
+				return
+			}
+			c.log(r).Error("could not create a backup", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			html.ErrorPageLayout(html.ErrorApplication("/cronlogger/Admin", r, fmt.Sprintf("could not create a backup; %v", err))).Render(r.Context(), w)
+			return
+		}
+		http.Redirect(w, r, "/cronlogger/Admin", http.StatusSeeOther)
 	}
+}
+
+// RunPage shows the details of a single execution
+func (c *CronLogHandler) RunPage() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idParam := r.PathValue("id")
+		item, err := c.store.GetById(r.Context(), idParam)
+		if err != nil {
+			if c.aborted(r) {
+				return
+			}
+			c.log(r).Error("could not get item by id", "id", idParam, "error", err)
+			w.WriteHeader(http.StatusNotFound)
+			html.ErrorPageLayout(html.ErrorApplication("/", r, fmt.Sprintf("could not get item by id '%s'; %v", idParam, err))).Render(r.Context(), w)
+			return
+		}
 
-	d := time.Date(date.Year(), date.Month(), date.Day(), 23, 23, 59, 0, time.UTC)
-	return &d
+		c.render(w, r, "RunPage", html.Layout(html.RunPage(item, c.appConfig()), c.version))
+	}
 }
 
-func getStartDate(date *time.Time) *time.Time {
-	if date == nil {
-		return nil
+// RunDetail provides the details of an execution, it is polled via htmx while the execution is running
+func (c *CronLogHandler) RunDetail() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idParam := r.PathValue("id")
+		item, err := c.store.GetById(r.Context(), idParam)
+		if err != nil {
+			if c.aborted(r) {
+				return
+			}
+			c.log(r).Error("could not get item by id", "id", idParam, "error", err)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		c.render(w, r, "RunDetail", html.RunDetail(item, c.appConfig()))
+	}
+}
+
+// render renders the page within a span, the rendering of a long list is a part of a slow response
+func (c *CronLogHandler) render(w http.ResponseWriter, r *http.Request, name string, page templ.Component) {
+	ctx, span := tracing.Start(r.Context(), "render "+name)
+	err := page.Render(ctx, w)
+	tracing.End(span, err)
+	if err != nil && !c.aborted(r) {
+		c.log(r).Error("could not render the page", "page", name, "error", err)
+	}
+}
+
+// aborted checks if the request was canceled, e.g. the client navigated away
+// before the result was available. There is no one to report the error to.
+func (c *CronLogHandler) aborted(r *http.Request) bool {
+	if errors.Is(r.Context().Err(), context.Canceled) {
+		c.log(r).Info("the request was aborted by the client", "path", r.URL.Path)
+		return true
+	}
+	return false
+}
+
+// scopeApps restricts the store queries of the requests to the applications the user may read
+func (c *CronLogHandler) scopeApps(next http.Handler) http.Handler {
+	if c.auth == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := c.auth.ScopeApps(r.Context())
+		if !c.auth.Admin(ctx) {
+			ctx = html.WithoutAdmin(ctx)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// allowed checks the role of the user on the application, without authentication everything is allowed
+func (c *CronLogHandler) allowed(r *http.Request, role auth.Role, app string) bool {
+	return c.auth == nil || c.auth.Allowed(r.Context(), role, app)
+}
+
+// admin checks if the user may administrate the server, otherwise the request is rejected
+func (c *CronLogHandler) admin(w http.ResponseWriter, r *http.Request) bool {
+	if c.auth == nil || c.auth.Admin(r.Context()) {
+		return true
 	}
+	c.log(r).Warn("the user may not administrate the server", "user", triggeringUser(r))
+	w.WriteHeader(http.StatusForbidden)
+	html.ErrorPageLayout(html.ErrorApplication("/", r, "you may not administrate the server")).Render(r.Context(), w)
+	return false
+}
 
-	d := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 1, 0, time.UTC)
-	return &d
+// Metrics serves the metrics of the applications and the server to administrators
+func (c *CronLogHandler) Metrics() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !c.admin(w, r) {
+			return
+		}
+		c.metrics.ServeHTTP(w, r)
+	}
 }
 
-func formatDate(t *time.Time) string {
-	if t == nil {
-		return ""
+// triggeringUser determines who started an execution. Only the authenticated principal
+// is trusted, the credentials and headers of an unauthenticated request are not verified.
+// Without authentication the remote address is used.
+func triggeringUser(r *http.Request) string {
+	if p, ok := auth.FromContext(r.Context()); ok {
+		return p.Name
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
 	}
-	// again and again the "stupid" golang decision to go with a reference data
-	// instead of the more commonly known date-format patterns YYYY-mm-dd ...
-	// "2006.01.02 15:04:05"
-	return t.Format("2006-01-02")
+	return r.RemoteAddr
 }
 
 // Json serialized the given data
@@ -0,0 +1,426 @@
+package handler_test
+
+import (
+	"context"
+	"cronlogger"
+	"cronlogger/auth"
+	"cronlogger/handler"
+	"cronlogger/health"
+	"cronlogger/store"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+var logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// the principals of the access rules of getHandler
+var (
+	admin    = auth.Principal{Name: "root", Method: auth.MethodBasic}
+	operator = auth.Principal{Name: "alice", Method: auth.MethodBasic}
+	reader   = auth.Principal{Name: "bob", Method: auth.MethodBasic}
+	ingester = auth.Principal{Name: "web1", Method: auth.MethodToken, Scopes: []string{"ingest:backup-*"}}
+)
+
+// fakeRunner records the triggered jobs
+type fakeRunner struct {
+	triggered []string
+}
+
+func (f *fakeRunner) Trigger(ctx context.Context, name, triggeredBy string) (store.OpResultEntity, error) {
+	f.triggered = append(f.triggered, name+"/"+triggeredBy)
+	return store.OpResultEntity{ID: "run-1", App: name, Status: store.StatusRunning}, nil
+}
+
+// fakeTokens knows the token with the ID "known" only
+type fakeTokens struct{}
+
+func (fakeTokens) Create(ctx context.Context, name string, scopes []string, ttl time.Duration, createdBy string) (string, store.APITokenEntity, error) {
+	if len(scopes) == 0 {
+		return "", store.APITokenEntity{}, errors.New("at least one scope is needed")
+	}
+	return "clg_secret", store.APITokenEntity{ID: "created", Name: name, Scopes: strings.Join(scopes, " "), CreatedBy: createdBy}, nil
+}
+
+func (fakeTokens) List(ctx context.Context) ([]store.APITokenEntity, error) {
+	return nil, nil
+}
+
+func (fakeTokens) Revoke(ctx context.Context, id string) error {
+	if id != "known" {
+		return store.ErrTokenNotFound
+	}
+	return nil
+}
+
+type fakeReloader struct {
+	err error
+}
+
+func (f fakeReloader) Reload() ([]string, error) {
+	return []string{"jobs"}, f.err
+}
+
+type fakeHealth struct {
+	report health.Report
+}
+
+func (f fakeHealth) Ready(ctx context.Context) health.Report {
+	return f.report
+}
+
+// testServer serves the routes of the handler for the principal
+type testServer struct {
+	store store.OpResultStore
+	mux   *http.ServeMux
+}
+
+func (s testServer) serve(p *auth.Principal, r *http.Request) *httptest.ResponseRecorder {
+	if p != nil {
+		r = r.WithContext(auth.WithPrincipal(r.Context(), *p))
+	}
+	rec := httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, r)
+	return rec
+}
+
+// getHandler creates the handler with the jobs backup-db and report: alice may trigger
+// backup-*, bob may read all applications and root is the administrator
+func getHandler(t *testing.T, opts handler.Options) testServer {
+	s, db, err := store.CreateSqliteStoreFromDbPath(":memory:", store.Options{})
+	if err != nil {
+		t.Fatalf("cannot create database connection: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	authn, err := auth.New(cronlogger.AuthConfig{
+		Tokens: []cronlogger.APIToken{{Name: "static", Token: "0123456789abcdef0123"}},
+		Access: []cronlogger.AccessRule{
+			{Users: []string{"root"}, Apps: []string{"*"}, Role: "admin"},
+			{Users: []string{"alice"}, Apps: []string{"backup-*"}, Role: "trigger"},
+			{Users: []string{"bob"}, Apps: []string{"*"}, Role: "read"},
+		},
+	}, logger)
+	if err != nil {
+		t.Fatalf("could not create the authentication; %v", err)
+	}
+	opts.Auth = authn
+
+	config := cronlogger.AppConfig{Jobs: []cronlogger.Job{
+		{Name: "backup-db", Command: "true"},
+		{Name: "report", Command: "true"},
+	}}
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux, handler.New(s, logger, "test", config, opts))
+	return testServer{store: s, mux: mux}
+}
+
+// createRuns stores a run of both jobs
+func createRuns(t *testing.T, s store.OpResultStore) {
+	for _, app := range []string{"backup-db", "report"} {
+		if _, err := s.Create(t.Context(), store.OpResultEntity{App: app, Status: store.StatusSuccess, Output: "done"}); err != nil {
+			t.Fatalf("could not create the run; %v", err)
+		}
+	}
+}
+
+func form(path string, values url.Values) *http.Request {
+	r := httptest.NewRequest("POST", path, strings.NewReader(values.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r
+}
+
+func Test_RunJob(t *testing.T) {
+	cases := map[string]struct {
+		principal auth.Principal
+		app       string
+		status    int
+		triggered string
+	}{
+		"unknown job":     {principal: admin, app: "other", status: http.StatusNotFound},
+		"without role":    {principal: operator, app: "report", status: http.StatusForbidden},
+		"read only":       {principal: reader, app: "backup-db", status: http.StatusForbidden},
+		"trigger role":    {principal: operator, app: "backup-db", status: http.StatusSeeOther, triggered: "backup-db/alice"},
+		"admin may start": {principal: admin, app: "report", status: http.StatusSeeOther, triggered: "report/root"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			runner := &fakeRunner{}
+			s := getHandler(t, handler.Options{Runner: runner})
+			rec := s.serve(&tc.principal, httptest.NewRequest("POST", "/cronlogger/App/"+tc.app+"/Run", nil))
+			if rec.Code != tc.status {
+				t.Errorf("expected status %d, got %d", tc.status, rec.Code)
+			}
+			if tc.triggered == "" {
+				if len(runner.triggered) != 0 {
+					t.Errorf("expected no execution, got %v", runner.triggered)
+				}
+				return
+			}
+			if len(runner.triggered) != 1 || runner.triggered[0] != tc.triggered {
+				t.Errorf("expected the execution %s, got %v", tc.triggered, runner.triggered)
+			}
+			if location := rec.Header().Get("Location"); location != "/cronlogger/Run/run-1" {
+				t.Errorf("expected the redirect to the run, got %s", location)
+			}
+		})
+	}
+
+	// without a runner the jobs cannot be started
+	s := getHandler(t, handler.Options{})
+	if rec := s.serve(&admin, httptest.NewRequest("POST", "/cronlogger/App/report/Run", nil)); rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 without a runner, got %d", rec.Code)
+	}
+}
+
+func Test_ApiRuns(t *testing.T) {
+	s := getHandler(t, handler.Options{})
+	createRuns(t, s.store)
+
+	cases := map[string]struct {
+		principal auth.Principal
+		query     string
+		status    int
+		apps      []string
+	}{
+		"all applications": {principal: reader, status: http.StatusOK, apps: []string{"report", "backup-db"}},
+		"scoped":           {principal: operator, status: http.StatusOK, apps: []string{"backup-db"}},
+		"scoped filter":    {principal: operator, query: "application=report", status: http.StatusOK, apps: []string{}},
+		"filter":           {principal: admin, query: "application=report", status: http.StatusOK, apps: []string{"report"}},
+		"bad cursor":       {principal: admin, query: "cursor=invalid", status: http.StatusBadRequest},
+		"bad limit":        {principal: admin, query: "limit=1000", status: http.StatusBadRequest},
+		"bad date":         {principal: admin, query: "from=yesterday", status: http.StatusBadRequest},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			rec := s.serve(&tc.principal, httptest.NewRequest("GET", "/cronlogger/api/runs?"+tc.query, nil))
+			if rec.Code != tc.status {
+				t.Fatalf("expected status %d, got %d: %s", tc.status, rec.Code, rec.Body.String())
+			}
+			if tc.status != http.StatusOK {
+				var apiErr map[string]string
+				if err := json.Unmarshal(rec.Body.Bytes(), &apiErr); err != nil || apiErr["error"] == "" {
+					t.Errorf("expected a JSON error, got %s", rec.Body.String())
+				}
+				return
+			}
+			var list handler.RunList
+			if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+				t.Fatalf("could not decode the runs; %v", err)
+			}
+			apps := []string{}
+			for _, item := range list.Items {
+				apps = append(apps, item.App)
+			}
+			if strings.Join(apps, ",") != strings.Join(tc.apps, ",") {
+				t.Errorf("expected the applications %v, got %v", tc.apps, apps)
+			}
+		})
+	}
+}
+
+func Test_IngestRun(t *testing.T) {
+	cases := map[string]struct {
+		principal auth.Principal
+		body      string
+		status    int
+		host      string
+	}{
+		"invalid body":   {principal: ingester, body: `{"application":`, status: http.StatusBadRequest},
+		"no application": {principal: ingester, body: `{"status":"success"}`, status: http.StatusBadRequest},
+		"invalid status": {principal: ingester, body: `{"application":"backup-db","status":"running"}`, status: http.StatusBadRequest},
+		"outside scope":  {principal: ingester, body: `{"application":"report","status":"success"}`, status: http.StatusForbidden},
+		"read only":      {principal: reader, body: `{"application":"report","status":"success"}`, status: http.StatusForbidden},
+		"token":          {principal: ingester, body: `{"application":"backup-db","status":"failure","output":"failed"}`, status: http.StatusCreated, host: "web1"},
+		"host":           {principal: operator, body: `{"application":"backup-db","status":"success","host":"db1"}`, status: http.StatusCreated, host: "db1"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := getHandler(t, handler.Options{})
+			rec := s.serve(&tc.principal, httptest.NewRequest("POST", "/cronlogger/api/runs", strings.NewReader(tc.body)))
+			if rec.Code != tc.status {
+				t.Fatalf("expected status %d, got %d: %s", tc.status, rec.Code, rec.Body.String())
+			}
+			items, err := s.store.GetAll(t.Context())
+			if err != nil {
+				t.Fatalf("could not get the runs; %v", err)
+			}
+			if tc.status != http.StatusCreated {
+				if len(items) != 0 {
+					t.Errorf("expected no stored run, got %v", items)
+				}
+				return
+			}
+			var run handler.RunItem
+			if err := json.Unmarshal(rec.Body.Bytes(), &run); err != nil {
+				t.Fatalf("could not decode the run; %v", err)
+			}
+			if len(items) != 1 || items[0].ID != run.ID || items[0].Host != tc.host {
+				t.Errorf("expected the run from %s to be stored, got %v", tc.host, items)
+			}
+		})
+	}
+}
+
+func Test_CreateToken(t *testing.T) {
+	cases := map[string]struct {
+		principal auth.Principal
+		values    url.Values
+		status    int
+	}{
+		"not admin":   {principal: operator, values: url.Values{"name": {"ci"}, "ttl": {"24h"}, "scopes": {"read:*"}}, status: http.StatusForbidden},
+		"invalid ttl": {principal: admin, values: url.Values{"name": {"ci"}, "ttl": {"a day"}, "scopes": {"read:*"}}, status: http.StatusBadRequest},
+		"no scopes":   {principal: admin, values: url.Values{"name": {"ci"}, "ttl": {"24h"}}, status: http.StatusBadRequest},
+		"created":     {principal: admin, values: url.Values{"name": {"ci"}, "ttl": {"24h"}, "scopes": {"read:* ingest:backup-*"}}, status: http.StatusOK},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := getHandler(t, handler.Options{Tokens: fakeTokens{}})
+			rec := s.serve(&tc.principal, form("/cronlogger/Admin/Tokens", tc.values))
+			if rec.Code != tc.status {
+				t.Fatalf("expected status %d, got %d", tc.status, rec.Code)
+			}
+			created := strings.Contains(rec.Body.String(), "clg_secret")
+			if created != (tc.status == http.StatusOK) {
+				t.Errorf("expected the token to be shown only once it is created, shown: %v", created)
+			}
+			if created && rec.Header().Get("Cache-Control") != "no-store" {
+				t.Errorf("the created token may be cached")
+			}
+		})
+	}
+
+	s := getHandler(t, handler.Options{})
+	if rec := s.serve(&admin, form("/cronlogger/Admin/Tokens", url.Values{"name": {"ci"}})); rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 without a token manager, got %d", rec.Code)
+	}
+}
+
+func Test_RevokeToken(t *testing.T) {
+	cases := map[string]struct {
+		principal auth.Principal
+		id        string
+		status    int
+	}{
+		"not admin": {principal: reader, id: "known", status: http.StatusForbidden},
+		"unknown":   {principal: admin, id: "other", status: http.StatusNotFound},
+		"revoked":   {principal: admin, id: "known", status: http.StatusSeeOther},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := getHandler(t, handler.Options{Tokens: fakeTokens{}})
+			rec := s.serve(&tc.principal, httptest.NewRequest("POST", "/cronlogger/Admin/Tokens/"+tc.id+"/Revoke", nil))
+			if rec.Code != tc.status {
+				t.Errorf("expected status %d, got %d", tc.status, rec.Code)
+			}
+		})
+	}
+}
+
+func Test_Export(t *testing.T) {
+	s := getHandler(t, handler.Options{})
+	createRuns(t, s.store)
+
+	cases := map[string]struct {
+		principal auth.Principal
+		path      string
+		status    int
+		file      string
+		apps      []string
+	}{
+		"unknown format": {principal: admin, path: "xml", status: http.StatusBadRequest},
+		"bad date":       {principal: admin, path: "csv?until=tomorrow", status: http.StatusBadRequest},
+		"csv":            {principal: admin, path: "csv", status: http.StatusOK, file: "cronlogger-runs.csv", apps: []string{"backup-db", "report"}},
+		"application":    {principal: admin, path: "jsonl?application=report", status: http.StatusOK, file: "cronlogger-runs-report.jsonl", apps: []string{"report"}},
+		"scoped":         {principal: operator, path: "jsonl", status: http.StatusOK, file: "cronlogger-runs.jsonl", apps: []string{"backup-db"}},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			rec := s.serve(&tc.principal, httptest.NewRequest("GET", "/cronlogger/api/export/"+tc.path, nil))
+			if rec.Code != tc.status {
+				t.Fatalf("expected status %d, got %d: %s", tc.status, rec.Code, rec.Body.String())
+			}
+			if tc.status != http.StatusOK {
+				return
+			}
+			if disposition := rec.Header().Get("Content-Disposition"); !strings.Contains(disposition, tc.file) {
+				t.Errorf("expected the file %s, got %s", tc.file, disposition)
+			}
+			body := rec.Body.String()
+			for _, app := range []string{"backup-db", "report"} {
+				expected := strings.Contains(strings.Join(tc.apps, ","), app)
+				if strings.Contains(body, app) != expected {
+					t.Errorf("expected the runs of %s to be exported: %v, got %s", app, expected, body)
+				}
+			}
+		})
+	}
+}
+
+func Test_Readyz(t *testing.T) {
+	failed := health.Report{Status: health.StatusFailed, Checks: []health.Check{
+		{Name: "database", Status: health.StatusFailed, Error: "database is locked"},
+	}}
+	ok := health.Report{Status: health.StatusOK, Checks: []health.Check{{Name: "database", Status: health.StatusOK}}}
+
+	cases := map[string]struct {
+		health handler.ReadinessChecker
+		status int
+	}{
+		"no checks": {status: http.StatusOK},
+		"ready":     {health: fakeHealth{report: ok}, status: http.StatusOK},
+		"failed":    {health: fakeHealth{report: failed}, status: http.StatusServiceUnavailable},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := getHandler(t, handler.Options{Health: tc.health})
+			// the probes are public
+			rec := s.serve(nil, httptest.NewRequest("GET", "/readyz", nil))
+			if rec.Code != tc.status {
+				t.Errorf("expected status %d, got %d", tc.status, rec.Code)
+			}
+			var report health.Report
+			if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+				t.Fatalf("could not decode the report; %v", err)
+			}
+			if report.Ready() != (tc.status == http.StatusOK) || rec.Header().Get("Cache-Control") != "no-store" {
+				t.Errorf("unexpected report %+v", report)
+			}
+		})
+	}
+}
+
+func Test_ReloadConfig(t *testing.T) {
+	cases := map[string]struct {
+		principal auth.Principal
+		reloader  handler.ConfigReloader
+		status    int
+	}{
+		"not admin":   {principal: operator, reloader: fakeReloader{}, status: http.StatusForbidden},
+		"no reloader": {principal: admin, status: http.StatusNotFound},
+		"invalid":     {principal: admin, reloader: fakeReloader{err: errors.New("invalid schedule")}, status: http.StatusUnprocessableEntity},
+		"reloaded":    {principal: admin, reloader: fakeReloader{}, status: http.StatusOK},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := getHandler(t, handler.Options{Reloader: tc.reloader})
+			rec := s.serve(&tc.principal, httptest.NewRequest("POST", "/cronlogger/api/config/reload", nil))
+			if rec.Code != tc.status {
+				t.Fatalf("expected status %d, got %d: %s", tc.status, rec.Code, rec.Body.String())
+			}
+			if tc.status == http.StatusOK {
+				var reload handler.ConfigReload
+				if err := json.Unmarshal(rec.Body.Bytes(), &reload); err != nil || len(reload.Changes) != 1 {
+					t.Errorf("expected the changes, got %s", rec.Body.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"cronlogger/health"
+	"net/http"
+)
+
+// Healthz reports that the process is running, it does not check any dependencies
+func (c *CronLogHandler) Healthz() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		writeJson(w, http.StatusOK, health.Live(c.version))
+	}
+}
+
+// Readyz reports whether the server can serve requests, i.e. the database is reachable
+// and current. The checks are answered with 503 if a single check failed.
+func (c *CronLogHandler) Readyz() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		if c.health == nil {
+			writeJson(w, http.StatusOK, health.Report{Status: health.StatusOK, Checks: []health.Check{}})
+			return
+		}
+		report := c.health.Ready(r.Context())
+		if !report.Ready() {
+			for _, check := range report.Checks {
+				if check.Status != health.StatusOK {
+					c.log(r).Warn("the readiness check failed", "check", check.Name, "error", check.Error)
+				}
+			}
+			writeJson(w, http.StatusServiceUnavailable, report)
+			return
+		}
+		writeJson(w, http.StatusOK, report)
+	}
+}
@@ -0,0 +1,117 @@
+package handler_test
+
+import (
+	"bytes"
+	"cronlogger/handler"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// accessLog serves the request via the access log and returns the response and the log records
+func accessLog(t *testing.T, next http.HandlerFunc, r *http.Request) (*httptest.ResponseRecorder, []map[string]any) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	rec := httptest.NewRecorder()
+	handler.AccessLog(logger, next).ServeHTTP(rec, r)
+
+	var records []map[string]any
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var record map[string]any
+		if err := dec.Decode(&record); err != nil {
+			t.Fatalf("could not decode the log record; %v", err)
+		}
+		records = append(records, record)
+	}
+	return rec, records
+}
+
+func Test_AccessLog_Status(t *testing.T) {
+	next := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		// a second status is ignored by the response and therefore by the log
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, "hello")
+	}
+	rec, records := accessLog(t, next, httptest.NewRequest("POST", "/cronlogger/api/runs", nil))
+	if rec.Code != http.StatusCreated {
+		t.Errorf("expected status 201, got %d", rec.Code)
+	}
+	if len(records) != 1 {
+		t.Fatalf("expected one log record, got %v", records)
+	}
+	record := records[0]
+	if record["msg"] != "request" || record["method"] != "POST" || record["path"] != "/cronlogger/api/runs" {
+		t.Errorf("unexpected log record %v", record)
+	}
+	// the numbers are decoded as float64
+	if record["status"] != float64(http.StatusCreated) || record["bytes"] != float64(5) {
+		t.Errorf("expected status 201 and 5 bytes, got %v/%v", record["status"], record["bytes"])
+	}
+}
+
+func Test_AccessLog_DefaultStatus(t *testing.T) {
+	next := func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}
+	_, records := accessLog(t, next, httptest.NewRequest("GET", "/cronlogger/StartPage", nil))
+	if len(records) != 1 || records[0]["status"] != float64(http.StatusOK) || records[0]["bytes"] != float64(2) {
+		t.Errorf("expected status 200 and 2 bytes, got %v", records)
+	}
+}
+
+func Test_AccessLog_Probes(t *testing.T) {
+	next := func(w http.ResponseWriter, r *http.Request) {}
+	for _, path := range []string{"/healthz", "/readyz", "/assets/app.css"} {
+		if _, records := accessLog(t, next, httptest.NewRequest("GET", path, nil)); len(records) != 0 {
+			t.Errorf("%s: expected no record with level info, got %v", path, records)
+		}
+	}
+}
+
+func Test_AccessLog_RequestID(t *testing.T) {
+	cases := map[string]struct {
+		header string
+		kept   bool
+	}{
+		"valid":     {header: "proxy-1234.abc", kept: true},
+		"max size":  {header: strings.Repeat("a", 64), kept: true},
+		"missing":   {header: ""},
+		"oversized": {header: strings.Repeat("a", 65)},
+		"space":     {header: "proxy 1234"},
+		"control":   {header: "proxy\x01"},
+		"non-ascii": {header: "proxy-ä"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var seen string
+			next := func(w http.ResponseWriter, r *http.Request) {
+				seen = handler.RequestID(r.Context())
+			}
+			r := httptest.NewRequest("GET", "/cronlogger/StartPage", nil)
+			if tc.header != "" {
+				r.Header.Set(handler.RequestIDHeader, tc.header)
+			}
+			rec, records := accessLog(t, next, r)
+
+			id := rec.Header().Get(handler.RequestIDHeader)
+			if tc.kept && id != tc.header {
+				t.Errorf("expected the ID %q, got %q", tc.header, id)
+			}
+			if !tc.kept && (id == "" || id == tc.header) {
+				t.Errorf("expected a new ID, got %q", id)
+			}
+			if seen != id {
+				t.Errorf("the handler got the ID %q instead of %q", seen, id)
+			}
+			if len(records) != 1 || records[0]["request_id"] != id {
+				t.Errorf("expected the ID %q in the log, got %v", id, records)
+			}
+		})
+	}
+}
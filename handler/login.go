@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"cronlogger/auth"
+	"cronlogger/handler/html"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// LoginPage shows the login form of the browsers
+func (c *CronLogHandler) LoginPage() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		next := auth.RedirectTarget(r.URL.Query().Get("next"))
+		if c.auth == nil || !c.auth.Enabled() {
+			http.Redirect(w, r, next, http.StatusSeeOther)
+			return
+		}
+		c.render(w, r, "LoginPage", html.Layout(html.LoginPage(next, "", c.loginOptions()), c.version))
+	}
+}
+
+// Login verifies the credentials of the login form and starts the session
+func (c *CronLogHandler) Login() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		next := auth.RedirectTarget(r.PostFormValue("next"))
+		if c.auth == nil || !c.auth.PasswordLogin() {
+			http.Redirect(w, r, auth.LoginURL(next), http.StatusSeeOther)
+			return
+		}
+
+		user := r.PostFormValue("username")
+		p, err := c.auth.Login(w, r, user, r.PostFormValue("password"))
+		if err != nil {
+			message := "could not log in"
+			if errors.Is(err, auth.ErrInvalidCredentials) {
+				message = "unknown user or wrong password"
+			}
+			c.log(r).Warn("the login failed", "user", user, "error", err)
+			w.WriteHeader(http.StatusUnauthorized)
+			c.render(w, r, "LoginPage", html.Layout(html.LoginPage(next, message, c.loginOptions()), c.version))
+			return
+		}
+		c.log(r).Info("the user logged in", "user", p.Name)
+		http.Redirect(w, r, next, http.StatusSeeOther)
+	}
+}
+
+// OIDCLogin redirects the browser to the OIDC provider
+func (c *CronLogHandler) OIDCLogin() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if c.auth == nil || c.auth.OIDC() == nil {
+			w.WriteHeader(http.StatusNotFound)
+			html.ErrorPageLayout(html.ErrorApplication("/", r, "no OIDC provider is configured")).Render(r.Context(), w)
+			return
+		}
+		if err := c.auth.OIDC().Start(w, r, r.URL.Query().Get("next")); err != nil {
+			c.log(r).Error("could not start the OIDC login", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			html.ErrorPageLayout(html.ErrorApplication("/", r, fmt.Sprintf("could not start the OIDC login; %v", err))).Render(r.Context(), w)
+		}
+	}
+}
+
+// OIDCCallback completes the login when the OIDC provider redirects the browser back
+func (c *CronLogHandler) OIDCCallback() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if c.auth == nil || c.auth.OIDC() == nil {
+			w.WriteHeader(http.StatusNotFound)
+			html.ErrorPageLayout(html.ErrorApplication("/", r, "no OIDC provider is configured")).Render(r.Context(), w)
+			return
+		}
+		p, next, err := c.auth.OIDC().Finish(w, r)
+		if err != nil {
+			message := "the login failed"
+			status := http.StatusUnauthorized
+			if errors.Is(err, auth.ErrNotAllowed) {
+				message = "you are not allowed to use the cronlogger"
+				status = http.StatusForbidden
+			}
+			c.log(r).Warn("the OIDC login failed", "error", err)
+			w.WriteHeader(status)
+			c.render(w, r, "LoginPage", html.Layout(html.LoginPage("/", message, c.loginOptions()), c.version))
+			return
+		}
+		c.log(r).Info("the user logged in via OIDC", "user", p.Name)
+		http.Redirect(w, r, next, http.StatusSeeOther)
+	}
+}
+
+// Logout ends the session of the browser, a user of the OIDC provider is also logged out at the provider
+func (c *CronLogHandler) Logout() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if c.auth != nil {
+			if providerLogout := c.auth.Logout(w, r); providerLogout != "" {
+				http.Redirect(w, r, providerLogout, http.StatusSeeOther)
+				return
+			}
+		}
+		http.Redirect(w, r, auth.LoginPath, http.StatusSeeOther)
+	}
+}
+
+func (c *CronLogHandler) loginOptions() html.LoginOptions {
+	options := html.LoginOptions{Password: c.auth.PasswordLogin()}
+	if c.auth.OIDC() != nil {
+		options.OIDC = c.auth.OIDC().Name()
+	}
+	return options
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"cronlogger/metrics"
 	"embed"
 	"fmt"
 	"io/fs"
@@ -16,11 +17,33 @@ func SetupRoutes(mux *http.ServeMux, handler *CronLogHandler) {
 
 	cronlogRoutes := http.NewServeMux()
 	cronlogRoutes.HandleFunc("/StartPage", handler.StartPage())
+	cronlogRoutes.HandleFunc("GET /Login", handler.LoginPage())
+	cronlogRoutes.HandleFunc("POST /Login", handler.Login())
+	cronlogRoutes.HandleFunc("POST /Logout", handler.Logout())
+	cronlogRoutes.HandleFunc("GET /oidc/login", handler.OIDCLogin())
+	cronlogRoutes.HandleFunc("GET /oidc/callback", handler.OIDCCallback())
 	cronlogRoutes.HandleFunc("POST /StartPage/TableResult", handler.TableResult())
 	cronlogRoutes.HandleFunc("GET /StartPage/TableResult/OutputDetail/{id}/{show}", handler.OutputDetail())
 	cronlogRoutes.HandleFunc("GET /StartPage/TableResult/ToggleOutputDetail/{id}", handler.ToggleOutputDetail())
-
-	mux.Handle("/cronlogger/", http.StripPrefix("/cronlogger", cronlogRoutes))
+	cronlogRoutes.HandleFunc("GET /App/{name}", handler.AppPage())
+	cronlogRoutes.HandleFunc("POST /App/{name}/Run", handler.RunJob())
+	cronlogRoutes.HandleFunc("GET /Run/{id}", handler.RunPage())
+	cronlogRoutes.HandleFunc("GET /Run/{id}/Detail", handler.RunDetail())
+	cronlogRoutes.HandleFunc("GET /Admin", handler.AdminPage())
+	cronlogRoutes.HandleFunc("POST /Admin/Backup", handler.CreateBackup())
+	cronlogRoutes.HandleFunc("POST /Admin/Tokens", handler.CreateToken())
+	cronlogRoutes.HandleFunc("POST /Admin/Tokens/{id}/Revoke", handler.RevokeToken())
+	cronlogRoutes.HandleFunc("GET /api/runs", handler.ApiRuns())
+	cronlogRoutes.HandleFunc("POST /api/runs", handler.IngestRun())
+	cronlogRoutes.HandleFunc("GET /api/export/{format}", handler.Export())
+	cronlogRoutes.HandleFunc("POST /api/config/reload", handler.ReloadConfig())
+
+	mux.Handle("/cronlogger/", http.StripPrefix("/cronlogger", handler.scopeApps(metrics.Route("/cronlogger", cronlogRoutes))))
+	mux.HandleFunc("GET /healthz", handler.Healthz())
+	mux.HandleFunc("GET /readyz", handler.Readyz())
+	if handler.metrics != nil {
+		mux.HandleFunc("GET /metrics", handler.Metrics())
+	}
 
 	serveStaticDir(mux, "assets")
 }
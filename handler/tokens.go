@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"cronlogger/handler/html"
+	"cronlogger/store"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CreateToken creates an API token and shows it once on the admin page
+func (c *CronLogHandler) CreateToken() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !c.admin(w, r) {
+			return
+		}
+		if c.tokens == nil {
+			c.log(r).Warn("API tokens are not available")
+			w.WriteHeader(http.StatusNotFound)
+			html.ErrorPageLayout(html.ErrorApplication("/cronlogger/Admin", r, "API tokens are not available")).Render(r.Context(), w)
+			return
+		}
+
+		name := r.PostFormValue("name")
+		ttl, err := time.ParseDuration(r.PostFormValue("ttl"))
+		if err != nil {
+			c.renderAdmin(w, r, http.StatusBadRequest, html.TokenList{Error: fmt.Sprintf("invalid expiry '%s'", r.PostFormValue("ttl"))})
+			return
+		}
+		secret, token, err := c.tokens.Create(r.Context(), name, strings.Fields(r.PostFormValue("scopes")), ttl, triggeringUser(r))
+		if err != nil {
+			if c.aborted(r) {
+				return
+			}
+			c.log(r).Warn("could not create the API token", "token", name, "error", err)
+			c.renderAdmin(w, r, http.StatusBadRequest, html.TokenList{Error: fmt.Sprintf("could not create the token; %v", err)})
+			return
+		}
+		c.log(r).Info("the user created an API token", "user", triggeringUser(r), "token", token.Name, "scopes", token.Scopes)
+
+		// the response contains the token, it must not be kept by caches
+		w.Header().Set("Cache-Control", "no-store")
+		c.renderAdmin(w, r, http.StatusOK, html.TokenList{Created: secret, CreatedName: token.Name})
+	}
+}
+
+// RevokeToken revokes an API token
+func (c *CronLogHandler) RevokeToken() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !c.admin(w, r) {
+			return
+		}
+		if c.tokens == nil {
+			c.log(r).Warn("API tokens are not available")
+			w.WriteHeader(http.StatusNotFound)
+			html.ErrorPageLayout(html.ErrorApplication("/cronlogger/Admin", r, "API tokens are not available")).Render(r.Context(), w)
+			return
+		}
+
+		idParam := r.PathValue("id")
+		if err := c.tokens.Revoke(r.Context(), idParam); err != nil {
+			if c.aborted(r) {
+				return
+			}
+			status := http.StatusInternalServerError
+			if errors.Is(err, store.ErrTokenNotFound) {
+				status = http.StatusNotFound
+			}
+			c.log(r).Error("could not revoke the API token", "id", idParam, "error", err)
+			w.WriteHeader(status)
+			html.ErrorPageLayout(html.ErrorApplication("/cronlogger/Admin", r, fmt.Sprintf("could not revoke the token; %v", err))).Render(r.Context(), w)
+			return
+		}
+		c.log(r).Info("the user revoked an API token", "user", triggeringUser(r), "id", idParam)
+		http.Redirect(w, r, "/cronlogger/Admin", http.StatusSeeOther)
+	}
+}
@@ -0,0 +1,153 @@
+package health
+
+import (
+	"context"
+	"cronlogger/store"
+	"fmt"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// the readiness is checked on every request of a load balancer or uptime checker, the checks
+// therefore only use cheap queries. Every check has its own timeout, a hanging database must
+// not block the response of the probe.
+
+// DefaultMinFreeMB is the free disk space below which the server is not ready
+const DefaultMinFreeMB = 100
+
+// checkTimeout limits the duration of a single check
+const checkTimeout = 2 * time.Second
+
+const (
+	StatusOK     = "ok"
+	StatusFailed = "failed"
+)
+
+// Check is the outcome of a single check
+type Check struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	// Detail describes the checked state, e.g. the schema version
+	Detail string `json:"detail,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Report is the outcome of all checks, the status is failed if a single check failed
+type Report struct {
+	Status string  `json:"status"`
+	Checks []Check `json:"checks"`
+}
+
+// Ready reports whether all checks succeeded
+func (r Report) Ready() bool {
+	return r.Status == StatusOK
+}
+
+// Checker checks the readiness of the database
+type Checker struct {
+	con store.Connection
+	// dir is the directory of the sqlite file, empty for PostgreSQL and in-memory databases
+	dir       string
+	minFreeMB int
+}
+
+// New creates the checker of the database defined by the dsn, minFreeMB <= 0 uses DefaultMinFreeMB
+func New(con store.Connection, dsn string, minFreeMB int) *Checker {
+	if minFreeMB <= 0 {
+		minFreeMB = DefaultMinFreeMB
+	}
+	c := &Checker{con: con, minFreeMB: minFreeMB}
+	if !store.IsPostgresDsn(dsn) && dsn != ":memory:" {
+		c.dir = filepath.Dir(dsn)
+	}
+	return c
+}
+
+// Ready executes the checks of the database, the migrations and the disk space
+func (c *Checker) Ready(ctx context.Context) Report {
+	checks := []Check{
+		c.run(ctx, "database", c.checkDatabase),
+		c.run(ctx, "migrations", c.checkMigrations),
+	}
+	if c.dir != "" {
+		checks = append(checks, c.run(ctx, "pragmas", c.checkPragmas), c.run(ctx, "disk", c.checkDisk))
+	}
+
+	report := Report{Status: StatusOK, Checks: checks}
+	for _, check := range checks {
+		if check.Status != StatusOK {
+			report.Status = StatusFailed
+		}
+	}
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, name string, check func(ctx context.Context) (string, error)) Check {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	detail, err := check(ctx)
+	if err != nil {
+		return Check{Name: name, Status: StatusFailed, Detail: detail, Error: err.Error()}
+	}
+	return Check{Name: name, Status: StatusOK, Detail: detail}
+}
+
+func (c *Checker) checkDatabase(ctx context.Context) (string, error) {
+	db, err := c.con.R().DB()
+	if err != nil {
+		return "", fmt.Errorf("cannot access the database connection; %v", err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		return "", fmt.Errorf("the database is not reachable; %v", err)
+	}
+	return c.con.R().Dialector.Name(), nil
+}
+
+func (c *Checker) checkPragmas(ctx context.Context) (string, error) {
+	db, err := c.con.R().DB()
+	if err != nil {
+		return "", fmt.Errorf("cannot access the database connection; %v", err)
+	}
+	// the pragmas are verified on the read pool, the probe does not wait for the writer
+	return "", store.VerifyPragmasContext(ctx, db)
+}
+
+func (c *Checker) checkMigrations(ctx context.Context) (string, error) {
+	version, err := store.SchemaVersion(store.Connection{Read: c.con.R().WithContext(ctx)})
+	if err != nil {
+		return "", fmt.Errorf("cannot read the schema version; %v", err)
+	}
+	detail := fmt.Sprintf("schema version %d of %d", version, store.LatestSchemaVersion())
+	if version != store.LatestSchemaVersion() {
+		return detail, fmt.Errorf("the database schema is not current")
+	}
+	return detail, nil
+}
+
+func (c *Checker) checkDisk(ctx context.Context) (string, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(c.dir, &stat); err != nil {
+		return "", fmt.Errorf("cannot read the free disk space of '%s'; %v", c.dir, err)
+	}
+	freeMB := stat.Bavail * uint64(stat.Bsize) / (1024 * 1024)
+	detail := fmt.Sprintf("%d MB free in '%s'", freeMB, c.dir)
+	if freeMB < uint64(c.minFreeMB) {
+		return detail, fmt.Errorf("less than %d MB of free disk space", c.minFreeMB)
+	}
+	return detail, nil
+}
+
+// Alive is the response of the liveness probe
+type Alive struct {
+	Status  string `json:"status"`
+	Version string `json:"version"`
+	Uptime  string `json:"uptime"`
+}
+
+var started = time.Now()
+
+// Live reports that the process is running
+func Live(version string) Alive {
+	return Alive{Status: StatusOK, Version: version, Uptime: time.Since(started).Truncate(time.Second).String()}
+}
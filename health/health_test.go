@@ -0,0 +1,84 @@
+package health_test
+
+import (
+	"cronlogger/health"
+	"cronlogger/store"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func getCon(t *testing.T, migrate bool) (store.Connection, string) {
+	path := filepath.Join(t.TempDir(), "cronlog-store.db")
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatalf("could not create file; %v", err)
+	}
+	con, db, err := store.CreateSqliteConFromDbPath(path)
+	if err != nil {
+		t.Fatalf("cannot create database connection: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if migrate {
+		if _, err := store.Migrate(con); err != nil {
+			t.Fatalf("cannot migrate the database: %v", err)
+		}
+	}
+	return con, path
+}
+
+func checkStatus(report health.Report) map[string]string {
+	status := map[string]string{}
+	for _, check := range report.Checks {
+		status[check.Name] = check.Status
+	}
+	return status
+}
+
+func Test_Ready(t *testing.T) {
+	con, path := getCon(t, true)
+	report := health.New(con, path, 0).Ready(t.Context())
+	if !report.Ready() || len(report.Checks) != 4 {
+		t.Errorf("expected all checks to succeed, got %+v", report)
+	}
+
+	// the disk space falls below the minimum
+	report = health.New(con, path, 1<<40).Ready(t.Context())
+	if status := checkStatus(report); report.Ready() || status["disk"] != health.StatusFailed || status["database"] != health.StatusOK {
+		t.Errorf("expected the disk check to fail, got %+v", report)
+	}
+}
+
+func Test_Ready_Failures(t *testing.T) {
+	con, path := getCon(t, false)
+	report := health.New(con, path, 0).Ready(t.Context())
+	if status := checkStatus(report); report.Ready() || status["migrations"] != health.StatusFailed || status["pragmas"] != health.StatusOK {
+		t.Errorf("expected the migration check to fail, got %+v", report)
+	}
+
+	// a closed database is not reachable
+	db, err := con.R().DB()
+	if err != nil {
+		t.Fatalf("cannot access the database connection; %v", err)
+	}
+	db.Close()
+	report = health.New(con, path, 0).Ready(t.Context())
+	if status := checkStatus(report); report.Ready() || status["database"] != health.StatusFailed {
+		t.Errorf("expected the database check to fail, got %+v", report)
+	}
+}
+
+func Test_Ready_Memory(t *testing.T) {
+	con, db, err := store.CreateConFromDsn(":memory:")
+	if err != nil {
+		t.Fatalf("cannot create database connection: %v", err)
+	}
+	defer db.Close()
+	if _, err := store.Migrate(con); err != nil {
+		t.Fatalf("cannot migrate the database: %v", err)
+	}
+	// an in-memory database has neither a directory nor the pragmas of a file
+	report := health.New(con, ":memory:", 0).Ready(t.Context())
+	if !report.Ready() || len(report.Checks) != 2 {
+		t.Errorf("expected the database and migration checks, got %+v", report)
+	}
+}
@@ -0,0 +1,322 @@
+package metrics
+
+import (
+	"context"
+	"cronlogger"
+	"cronlogger/store"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/robfig/cron/v3"
+)
+
+// the metrics are exposed in the Prometheus text format. The metrics of the applications
+// are computed from the store, the store therefore remains the only state. The counts need
+// to read all executions, the computed stats are reused for StatsTTL. An event of the store
+// discards them earlier, the runs of the logger binary are written by another process and
+// appear once the stats expired.
+// The durations of the runs are the exception, the store does not keep the end of a run.
+// They are observed from the events of the store once a run started by the scheduler
+// has finished. The runs of the logger binary are stored once they are finished, their
+// durations are therefore not available.
+
+// DefaultGrace is the delay after a scheduled run until an application is overdue
+const DefaultGrace = time.Hour
+
+// StatsTTL is the time the stats of the applications are reused by the following scrapes
+const StatsTTL = 30 * time.Second
+
+const namespace = "cronlogger"
+
+// the status values of the last_status metric
+var statuses = []store.RunStatus{store.StatusSuccess, store.StatusFailure, store.StatusTimeout, store.StatusSkipped, store.StatusRunning}
+
+type schedule struct {
+	cron  cron.Schedule
+	grace time.Duration
+}
+
+// Metrics collects the metrics of the applications and the HTTP server
+type Metrics struct {
+	store     store.OpResultStore
+	logger    *slog.Logger
+	registry  *prometheus.Registry
+	schedules atomic.Pointer[map[string]schedule]
+	started   time.Time
+
+	statsMu sync.Mutex
+	stats   []store.AppStats
+	// statsAt is the time the stats were computed, zero if they are discarded
+	statsAt time.Time
+
+	durations       *prometheus.HistogramVec
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+
+	lastRun     *prometheus.Desc
+	lastSuccess *prometheus.Desc
+	lastStatus  *prometheus.Desc
+	runs        *prometheus.Desc
+	overdue     *prometheus.Desc
+}
+
+// New creates the metrics, the schedules of the jobs and applications define when an application is overdue
+func New(s store.OpResultStore, logger *slog.Logger, config cronlogger.AppConfig) (*Metrics, error) {
+	m := &Metrics{
+		store:    s,
+		logger:   logger,
+		registry: prometheus.NewRegistry(),
+		started:  time.Now(),
+
+		durations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "run_duration_seconds",
+			Help:      "The duration of the scheduled and manual runs of the server, the runs of the logger binary are not included.",
+			Buckets:   []float64{1, 5, 15, 30, 60, 300, 900, 1800, 3600, 7200, 14400, 43200, 86400},
+		}, []string{"application"}),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "The number of HTTP requests by route, method and status code.",
+		}, []string{"route", "method", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "The duration of the HTTP requests by route and method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+
+		lastRun: prometheus.NewDesc(namespace+"_last_run_timestamp_seconds",
+			"The creation of the latest run of the application: the start for the runs of the server, the end for the runs of the logger binary.", []string{"application"}, nil),
+		lastSuccess: prometheus.NewDesc(namespace+"_last_success_timestamp_seconds",
+			"The creation of the latest successful run of the application (start for the server, end for the logger binary), missing without success.", []string{"application"}, nil),
+		lastStatus: prometheus.NewDesc(namespace+"_last_status",
+			"The status of the latest run of the application, 1 for the current status.", []string{"application", "status"}, nil),
+		runs: prometheus.NewDesc(namespace+"_runs_total",
+			"The number of runs of the application by status.", []string{"application", "status"}, nil),
+		overdue: prometheus.NewDesc(namespace+"_overdue",
+			"1 if a scheduled run of the application is missing for longer than the grace period.", []string{"application"}, nil),
+	}
+
+	if err := m.Update(config); err != nil {
+		return nil, err
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.durations,
+		m.requests,
+		m.requestDuration,
+		appCollector{m},
+	)
+	return m, nil
+}
+
+// Update replaces the schedules of the jobs and applications, e.g. after a reload of the configuration
+func (m *Metrics) Update(config cronlogger.AppConfig) error {
+	schedules := map[string]schedule{}
+	for _, job := range config.Jobs {
+		if job.Schedule == "" {
+			continue
+		}
+		if err := addSchedule(schedules, job.Name, job.Schedule, config); err != nil {
+			return err
+		}
+	}
+	for _, app := range config.Applications {
+		if app.Schedule == "" {
+			continue
+		}
+		if _, ok := schedules[app.Name]; ok {
+			return fmt.Errorf("the application '%s' is a scheduled job, the schedule is defined by the job", app.Name)
+		}
+		if err := addSchedule(schedules, app.Name, app.Schedule, config); err != nil {
+			return err
+		}
+	}
+	m.schedules.Store(&schedules)
+	return nil
+}
+
+func addSchedule(schedules map[string]schedule, name, expr string, config cronlogger.AppConfig) error {
+	sched, err := cron.ParseStandard(expr)
+	if err != nil {
+		return fmt.Errorf("invalid schedule '%s' of the application '%s'; %v", expr, name, err)
+	}
+	grace := DefaultGrace
+	if app, ok := config.Application(name); ok && app.Grace > 0 {
+		grace = app.Grace
+	}
+	schedules[name] = schedule{cron: sched, grace: grace}
+	return nil
+}
+
+// Handler serves the metrics in the Prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{
+		ErrorLog:      slog.NewLogLogger(m.logger.Handler(), slog.LevelError),
+		ErrorHandling: promhttp.ContinueOnError,
+	})
+}
+
+// HandleEvent observes the durations of the finished runs and discards the stats of the
+// applications, it is a subscriber of the event bus
+func (m *Metrics) HandleEvent(e store.Event) {
+	m.statsMu.Lock()
+	m.statsAt = time.Time{}
+	m.statsMu.Unlock()
+
+	// only the runs of the scheduler are updated once they have finished
+	if e.Type != store.RunUpdated || e.Run.State() == store.StatusRunning {
+		return
+	}
+	m.durations.WithLabelValues(e.Run.App).Observe(e.Time.Sub(e.Run.Created).Seconds())
+}
+
+type routeKey struct{}
+
+// Middleware records the HTTP metrics of the requests. The route is the pattern of the mux
+// which matches the request, the path itself would create a time series per run. A nested
+// mux registered with Route refines the route of the request.
+func (m *Metrics) Middleware(routes *http.ServeMux, next http.Handler) http.Handler {
+	label := promhttp.WithLabelFromCtx("route", func(ctx context.Context) string {
+		if route := RouteOf(ctx); route != "" {
+			return route
+		}
+		return "none"
+	})
+	h := promhttp.InstrumentHandlerDuration(m.requestDuration, promhttp.InstrumentHandlerCounter(m.requests, next, label), label)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeOf("", routes, r)
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), routeKey{}, &route)))
+	})
+}
+
+// Route records the pattern of a nested mux as route of the HTTP metrics. The prefix is
+// added to the pattern if the mux is used with http.StripPrefix.
+func Route(prefix string, mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route, ok := r.Context().Value(routeKey{}).(*string); ok {
+			if pattern := routeOf(prefix, mux, r); pattern != "" {
+				*route = pattern
+			}
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// RouteOf returns the route of the request recorded by the middleware, empty if it is not known yet
+func RouteOf(ctx context.Context) string {
+	if route, ok := ctx.Value(routeKey{}).(*string); ok {
+		return *route
+	}
+	return ""
+}
+
+func routeOf(prefix string, mux *http.ServeMux, r *http.Request) string {
+	_, pattern := mux.Handler(r)
+	if pattern == "" {
+		return ""
+	}
+	// the pattern might start with the method, e.g. "GET /App/{name}"
+	if _, path, found := strings.Cut(pattern, " "); found {
+		pattern = path
+	}
+	return prefix + pattern
+}
+
+// appCollector computes the metrics of the applications from the store
+type appCollector struct {
+	m *Metrics
+}
+
+func (c appCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.m.lastRun
+	ch <- c.m.lastSuccess
+	ch <- c.m.lastStatus
+	ch <- c.m.runs
+	ch <- c.m.overdue
+}
+
+// appStats returns the stats of the applications, they are computed again once they expired
+func (m *Metrics) appStats() ([]store.AppStats, error) {
+	// concurrent scrapes wait for the stats of the first one
+	m.statsMu.Lock()
+	defer m.statsMu.Unlock()
+	if !m.statsAt.IsZero() && time.Since(m.statsAt) < StatsTTL {
+		return m.stats, nil
+	}
+	stats, err := m.store.GetAppStats(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	m.stats, m.statsAt = stats, time.Now()
+	return stats, nil
+}
+
+func (c appCollector) Collect(ch chan<- prometheus.Metric) {
+	m := c.m
+	stats, err := m.appStats()
+	if err != nil {
+		m.logger.Error("could not compute the metrics of the applications", "error", err)
+		ch <- prometheus.NewInvalidMetric(m.lastRun, err)
+		return
+	}
+
+	now := time.Now()
+	schedules := *m.schedules.Load()
+	seen := make(map[string]bool, len(stats))
+	for _, app := range stats {
+		seen[app.App] = true
+		ch <- prometheus.MustNewConstMetric(m.lastRun, prometheus.GaugeValue, timestamp(app.LastRun.Created), app.App)
+		if app.LastSuccess != nil {
+			ch <- prometheus.MustNewConstMetric(m.lastSuccess, prometheus.GaugeValue, timestamp(*app.LastSuccess), app.App)
+		}
+		state := app.LastRun.State()
+		for _, status := range statuses {
+			ch <- prometheus.MustNewConstMetric(m.lastStatus, prometheus.GaugeValue, flag(state == status), app.App, string(status))
+		}
+		for _, status := range statuses {
+			ch <- prometheus.MustNewConstMetric(m.runs, prometheus.CounterValue, float64(app.Counts[status]), app.App, string(status))
+		}
+		if sched, ok := schedules[app.App]; ok {
+			ch <- prometheus.MustNewConstMetric(m.overdue, prometheus.GaugeValue, flag(sched.overdue(app.LastRun.Created, now)), app.App)
+		}
+	}
+
+	// a scheduled application without runs is overdue once the first run since the start is missing
+	for name, sched := range schedules {
+		if !seen[name] {
+			ch <- prometheus.MustNewConstMetric(m.overdue, prometheus.GaugeValue, flag(sched.overdue(m.started, now)), name)
+		}
+	}
+}
+
+// overdue determines if the next scheduled run after the last run is missing
+func (s schedule) overdue(last, now time.Time) bool {
+	next := s.cron.Next(last)
+	if next.IsZero() {
+		return false
+	}
+	return now.After(next.Add(s.grace))
+}
+
+func timestamp(t time.Time) float64 {
+	return float64(t.UnixNano()) / 1e9
+}
+
+func flag(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,143 @@
+package metrics_test
+
+import (
+	"cronlogger"
+	"cronlogger/metrics"
+	"cronlogger/store"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func getStore(t *testing.T) store.OpResultStore {
+	s, db, err := store.CreateSqliteStoreFromDbPath(":memory:", store.Options{})
+	if err != nil {
+		t.Fatalf("cannot create database connection: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return s
+}
+
+func scrape(t *testing.T, h http.Handler) string {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("could not scrape the metrics, got %d %s", rec.Code, rec.Body.String())
+	}
+	return rec.Body.String()
+}
+
+func expectMetrics(t *testing.T, body string, lines ...string) {
+	for _, line := range lines {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("expected the metric '%s'", line)
+		}
+	}
+}
+
+func Test_New_InvalidSchedules(t *testing.T) {
+	invalid := map[string]cronlogger.AppConfig{
+		"application": {Applications: []cronlogger.Application{{Name: "test", Schedule: "* * *"}}},
+		"job":         {Jobs: []cronlogger.Job{{Name: "test", Schedule: "* * *", Command: "true"}}},
+		"both": {
+			Applications: []cronlogger.Application{{Name: "test", Schedule: "@daily"}},
+			Jobs:         []cronlogger.Job{{Name: "test", Schedule: "@daily", Command: "true"}},
+		},
+	}
+	for name, config := range invalid {
+		if _, err := metrics.New(getStore(t), logger, config); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func Test_App_Metrics(t *testing.T) {
+	s := getStore(t)
+	created := time.Now().Add(-3 * time.Hour).Truncate(time.Second)
+	if _, err := s.Import(t.Context(), []store.OpResultEntity{{ID: "1", App: "nightly", Status: store.StatusSuccess, Created: created}}); err != nil {
+		t.Fatalf("could not import the run; %v", err)
+	}
+	for _, status := range []store.RunStatus{store.StatusSuccess, store.StatusFailure} {
+		if _, err := s.Create(t.Context(), store.OpResultEntity{App: "acme-tls", Status: status}); err != nil {
+			t.Fatalf("could not create the run; %v", err)
+		}
+	}
+
+	m, err := metrics.New(s, logger, cronlogger.AppConfig{
+		Applications: []cronlogger.Application{
+			{Name: "nightly", Schedule: "@hourly", Grace: time.Minute},
+			{Name: "acme-tls", Schedule: "@daily"},
+			{Name: "missing", Schedule: "@hourly"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("could not create the metrics; %v", err)
+	}
+	body := scrape(t, m.Handler())
+	expectMetrics(t, body,
+		`cronlogger_last_run_timestamp_seconds{application="nightly"} `+formatSeconds(created),
+		`cronlogger_last_success_timestamp_seconds{application="nightly"} `+formatSeconds(created),
+		`cronlogger_last_status{application="acme-tls",status="failure"} 1`,
+		`cronlogger_last_status{application="acme-tls",status="success"} 0`,
+		`cronlogger_runs_total{application="acme-tls",status="failure"} 1`,
+		`cronlogger_runs_total{application="acme-tls",status="success"} 1`,
+		`cronlogger_runs_total{application="acme-tls",status="timeout"} 0`,
+		`cronlogger_overdue{application="nightly"} 1`,
+		`cronlogger_overdue{application="acme-tls"} 0`,
+		`cronlogger_overdue{application="missing"} 0`,
+	)
+
+	// the stats are reused until an event of the store discards them
+	if _, err := s.Create(t.Context(), store.OpResultEntity{App: "acme-tls", Status: store.StatusTimeout}); err != nil {
+		t.Fatalf("could not create the run; %v", err)
+	}
+	expectMetrics(t, scrape(t, m.Handler()), `cronlogger_runs_total{application="acme-tls",status="timeout"} 0`)
+	m.HandleEvent(store.Event{Type: store.RunCreated, Run: store.OpResultEntity{App: "acme-tls", Status: store.StatusTimeout}})
+	expectMetrics(t, scrape(t, m.Handler()), `cronlogger_runs_total{application="acme-tls",status="timeout"} 1`)
+
+	// the duration of a finished run is observed from the event of the update
+	m.HandleEvent(store.Event{Type: store.RunUpdated, Run: store.OpResultEntity{App: "nightly", Status: store.StatusSuccess, Created: created}, Time: created.Add(90 * time.Second)})
+	m.HandleEvent(store.Event{Type: store.RunUpdated, Run: store.OpResultEntity{App: "nightly", Status: store.StatusRunning, Created: created}, Time: created})
+	m.HandleEvent(store.Event{Type: store.RunCreated, Run: store.OpResultEntity{App: "nightly", Status: store.StatusSuccess, Created: created}, Time: created})
+	expectMetrics(t, scrape(t, m.Handler()),
+		`cronlogger_run_duration_seconds_bucket{application="nightly",le="60"} 0`,
+		`cronlogger_run_duration_seconds_bucket{application="nightly",le="300"} 1`,
+		`cronlogger_run_duration_seconds_count{application="nightly"} 1`,
+	)
+}
+
+func Test_HTTP_Metrics(t *testing.T) {
+	m, err := metrics.New(getStore(t), logger, cronlogger.AppConfig{})
+	if err != nil {
+		t.Fatalf("could not create the metrics; %v", err)
+	}
+	inner := http.NewServeMux()
+	inner.HandleFunc("GET /App/{name}", func(w http.ResponseWriter, r *http.Request) {})
+	mux := http.NewServeMux()
+	mux.Handle("/cronlogger/", http.StripPrefix("/cronlogger", metrics.Route("/cronlogger", inner)))
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {})
+	h := m.Middleware(mux, mux)
+
+	for _, path := range []string{"/cronlogger/App/acme-tls", "/cronlogger/App/rclone", "/cronlogger/Other", "/metrics", "/other"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	expectMetrics(t, scrape(t, m.Handler()),
+		`cronlogger_http_requests_total{code="200",method="get",route="/cronlogger/App/{name}"} 2`,
+		`cronlogger_http_requests_total{code="404",method="get",route="/cronlogger/"} 1`,
+		`cronlogger_http_requests_total{code="200",method="get",route="/metrics"} 1`,
+		`cronlogger_http_requests_total{code="404",method="get",route="none"} 1`,
+		`cronlogger_http_request_duration_seconds_count{method="get",route="/cronlogger/App/{name}"} 2`,
+	)
+}
+
+// formatSeconds formats a timestamp like the text format of Prometheus
+func formatSeconds(t time.Time) string {
+	return strconv.FormatFloat(float64(t.Unix()), 'g', -1, 64)
+}
@@ -0,0 +1,167 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"cronlogger"
+	"cronlogger/store"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// the finished runs are posted as JSON to the webhooks of the matching routes. The server
+// receives the runs via the event bus, the logger binary notifies after storing its run.
+// A failed request is logged, it is neither retried nor does it affect the run.
+
+// DefaultTimeout limits the duration of a webhook request
+const DefaultTimeout = 10 * time.Second
+
+// DefaultStatuses are sent if a route does not define statuses
+var DefaultStatuses = []string{string(store.StatusFailure), string(store.StatusTimeout)}
+
+// maxOutput limits the output of the run in the message, the end of the output is kept
+const maxOutput = 2000
+
+// Message is the JSON body of a webhook request
+type Message struct {
+	// Text summarizes the run, it is shown by the incoming webhooks of Slack or Mattermost
+	Text        string    `json:"text"`
+	Application string    `json:"application"`
+	ID          string    `json:"id"`
+	Status      string    `json:"status"`
+	Created     time.Time `json:"created"`
+	Duration    string    `json:"duration,omitempty"`
+	Attempts    int       `json:"attempts"`
+	Host        string    `json:"host,omitempty"`
+	Output      string    `json:"output"`
+}
+
+// Notifier sends the finished runs to the webhooks of the routes
+type Notifier struct {
+	config atomic.Pointer[cronlogger.NotificationConfig]
+	client *http.Client
+	logger *slog.Logger
+}
+
+// New creates the notifier of the routes
+func New(logger *slog.Logger, config cronlogger.NotificationConfig) *Notifier {
+	n := &Notifier{
+		client: &http.Client{},
+		logger: logger,
+	}
+	n.Update(config)
+	return n
+}
+
+// Update replaces the routes, e.g. after a reload of the configuration
+func (n *Notifier) Update(config cronlogger.NotificationConfig) {
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
+	}
+	n.config.Store(&config)
+}
+
+// Enabled reports whether routes are configured
+func (n *Notifier) Enabled() bool {
+	return len(n.config.Load().Routes) > 0
+}
+
+// HandleEvent sends the finished runs, it is a subscriber of the event bus
+func (n *Notifier) HandleEvent(e store.Event) {
+	if e.Type == store.RunDeleted || e.Run.State() == store.StatusRunning || !n.Enabled() {
+		return
+	}
+	msg := NewMessage(e.Run)
+	// the runs of the scheduler are created before the execution and updated once they have finished,
+	// the logger binary creates its runs at the end of the execution
+	if e.Type == store.RunUpdated {
+		msg.Duration = e.Time.Sub(e.Run.Created).Truncate(time.Second).String()
+	}
+	if err := n.Send(context.Background(), msg); err != nil {
+		n.logger.Error("could not send the notification", "application", e.Run.App, "run", e.Run.ID, "error", err)
+	}
+}
+
+// NewMessage creates the message of the run
+func NewMessage(run store.OpResultEntity) Message {
+	output := run.Output
+	if len(output) > maxOutput {
+		output = "..." + output[len(output)-maxOutput:]
+	}
+	return Message{
+		Text:        fmt.Sprintf("The run of '%s' finished with status '%s'", run.App, run.State()),
+		Application: run.App,
+		ID:          run.ID,
+		Status:      string(run.State()),
+		Created:     run.Created,
+		Attempts:    max(1, run.AttemptCount),
+		Host:        run.Host,
+		Output:      output,
+	}
+}
+
+// Send posts the message to the webhooks of the matching routes
+func (n *Notifier) Send(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("could not create the message; %v", err)
+	}
+	config := n.config.Load()
+	var errs []error
+	for _, route := range config.Routes {
+		if !Matches(route, msg.Application, msg.Status) {
+			continue
+		}
+		if err := n.post(ctx, route, body, config.Timeout); err != nil {
+			errs = append(errs, fmt.Errorf("route '%s': %v", route.Name, err))
+			continue
+		}
+		n.logger.Debug("sent the notification", "route", route.Name, "application", msg.Application, "run", msg.ID)
+	}
+	return errors.Join(errs...)
+}
+
+func (n *Notifier) post(ctx context.Context, route cronlogger.NotificationRoute, body []byte, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, route.Webhook, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("could not create the request; %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range route.Headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not post to the webhook; %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("the webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Matches reports whether the route sends the runs of the application with the status
+func Matches(route cronlogger.NotificationRoute, app, status string) bool {
+	statuses := route.Statuses
+	if len(statuses) == 0 {
+		statuses = DefaultStatuses
+	}
+	if !slices.Contains(statuses, status) {
+		return false
+	}
+	if len(route.Apps) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(route.Apps, func(pattern string) bool {
+		return store.MatchApp(pattern, app)
+	})
+}
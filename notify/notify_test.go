@@ -0,0 +1,107 @@
+package notify_test
+
+import (
+	"cronlogger"
+	"cronlogger/notify"
+	"cronlogger/store"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// webhook records the messages posted to it
+type webhook struct {
+	*httptest.Server
+	mu       sync.Mutex
+	messages []notify.Message
+	headers  []http.Header
+}
+
+func newWebhook(t *testing.T, status int) *webhook {
+	h := &webhook{}
+	h.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg notify.Message
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Errorf("could not decode the message; %v", err)
+		}
+		h.mu.Lock()
+		h.messages = append(h.messages, msg)
+		h.headers = append(h.headers, r.Header)
+		h.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(h.Close)
+	return h
+}
+
+func Test_Matches(t *testing.T) {
+	route := cronlogger.NotificationRoute{Apps: []string{"rclone-*"}}
+	for _, tc := range []struct {
+		app, status string
+		expected    bool
+	}{
+		{"rclone-gdrive", "failure", true},
+		{"rclone-gdrive", "timeout", true},
+		{"rclone-gdrive", "success", false},
+		{"acme-tls", "failure", false},
+	} {
+		if notify.Matches(route, tc.app, tc.status) != tc.expected {
+			t.Errorf("%s/%s: expected %v", tc.app, tc.status, tc.expected)
+		}
+	}
+	if !notify.Matches(cronlogger.NotificationRoute{Statuses: []string{"success"}}, "acme-tls", "success") {
+		t.Errorf("expected a route without apps to match all applications")
+	}
+}
+
+func Test_Notify(t *testing.T) {
+	failures := newWebhook(t, http.StatusOK)
+	all := newWebhook(t, http.StatusOK)
+	broken := newWebhook(t, http.StatusInternalServerError)
+
+	n := notify.New(logger, cronlogger.NotificationConfig{Routes: []cronlogger.NotificationRoute{
+		{Name: "failures", Apps: []string{"rclone-*"}, Webhook: failures.URL, Headers: map[string]string{"X-Api-Key": "secret"}},
+		{Name: "all", Statuses: []string{"success", "failure"}, Webhook: all.URL},
+	}})
+	created := time.Now().Add(-90 * time.Second)
+	run := store.OpResultEntity{ID: "1", App: "rclone-gdrive", Status: store.StatusFailure, Created: created, Output: strings.Repeat("x", 3000)}
+
+	// the runs of the scheduler are sent once they are finished
+	n.HandleEvent(store.Event{Type: store.RunCreated, Run: store.OpResultEntity{ID: "1", App: "rclone-gdrive", Status: store.StatusRunning, Created: created}, Time: created})
+	n.HandleEvent(store.Event{Type: store.RunUpdated, Run: run, Time: created.Add(90 * time.Second)})
+	n.HandleEvent(store.Event{Type: store.RunDeleted, Run: run, Time: time.Now()})
+
+	if len(failures.messages) != 1 || len(all.messages) != 1 {
+		t.Fatalf("expected a message per route, got %d and %d", len(failures.messages), len(all.messages))
+	}
+	msg := failures.messages[0]
+	if msg.Application != "rclone-gdrive" || msg.Status != "failure" || msg.Duration != "1m30s" || msg.Attempts != 1 {
+		t.Errorf("unexpected message %+v", msg)
+	}
+	if len(msg.Output) != 2003 || !strings.HasPrefix(msg.Output, "...") {
+		t.Errorf("expected the end of the output, got %d characters", len(msg.Output))
+	}
+	if failures.headers[0].Get("X-Api-Key") != "secret" || failures.headers[0].Get("Content-Type") != "application/json" {
+		t.Errorf("expected the headers of the route, got %v", failures.headers[0])
+	}
+
+	// a successful run is only sent to the routes of successful runs
+	n.HandleEvent(store.Event{Type: store.RunCreated, Run: store.OpResultEntity{ID: "2", App: "acme-tls", Success: true}, Time: time.Now()})
+	if len(failures.messages) != 1 || len(all.messages) != 2 || all.messages[1].Status != "success" {
+		t.Errorf("expected the successful run only for the route of all runs")
+	}
+
+	// the errors of the webhooks are returned
+	n = notify.New(logger, cronlogger.NotificationConfig{Routes: []cronlogger.NotificationRoute{{Name: "broken", Webhook: broken.URL}}})
+	if err := n.Send(t.Context(), notify.NewMessage(run)); err == nil || !strings.Contains(err.Error(), "route 'broken'") {
+		t.Errorf("expected the error of the webhook, got %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package cronlogger
+
+import (
+	"context"
+	"cronlogger/store"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RunParams combines everything needed to execute a command for an application
+type RunParams struct {
+	// Exec defines the command, its environment and the timeout
+	Exec ExecParams
+	// Retry defines how often a failed command is executed again
+	Retry RetryParams
+	// Lock defines how overlapping runs of the application are handled
+	Lock LockPolicy
+	// LockPath is the path of the lock file, only needed if a lock policy is set
+	LockPath string
+}
+
+// Run executes the command of an application and maps the outcome to a store entry.
+// This is the common execution path of the logger and the scheduler, the returned
+// entry is not persisted.
+func Run(ctx context.Context, appName string, params RunParams) store.OpResultEntity {
+	if params.Lock == LockNone {
+		return runCommand(ctx, appName, params.Exec, params.Retry)
+	}
+
+	lock, err := AcquireLock(ctx, params.LockPath, params.Lock, params.Exec.GracePeriod)
+	if errors.Is(err, ErrLocked) {
+		return store.OpResultEntity{
+			App:    appName,
+			Status: store.StatusSkipped,
+			Output: "[cronlogger] skipped because previous run still active\n",
+		}
+	}
+	if err != nil {
+		return store.OpResultEntity{
+			App:    appName,
+			Status: store.StatusFailure,
+			Output: err.Error(),
+		}
+	}
+	defer lock.Release()
+
+	onStart := params.Exec.OnStart
+	params.Exec.OnStart = func(pid int) {
+		// the pid is only used to kill a hanging run, failing to write it does not stop the execution
+		lock.SetPid(pid)
+		if onStart != nil {
+			onStart(pid)
+		}
+	}
+	return runCommand(ctx, appName, params.Exec, params.Retry)
+}
+
+// runCommand runs the supplied command and maps the outcome to a store entry.
+// If retries are configured each execution is stored as an attempt of the run.
+func runCommand(ctx context.Context, appName string, params ExecParams, retry RetryParams) store.OpResultEntity {
+	item := store.OpResultEntity{
+		App: appName,
+	}
+
+	results, err := ExecuteWithRetries(ctx, params, retry)
+	if retry.Retries > 0 {
+		for i, result := range results {
+			status, output := mapResult(result, params.Timeout)
+			item.Attempts = append(item.Attempts, store.OpAttemptEntity{
+				Attempt:  i + 1,
+				Status:   status,
+				ExitCode: result.ExitCode,
+				Output:   output,
+				Created:  result.Started,
+			})
+		}
+	}
+
+	switch {
+	case err != nil:
+		item.Status = store.StatusFailure
+		item.Output = err.Error()
+	default:
+		item.Status, item.Output = mapResult(results[len(results)-1], params.Timeout)
+	}
+	return item
+}
+
+func mapResult(result ExecResult, timeout time.Duration) (store.RunStatus, string) {
+	switch {
+	case result.TimedOut:
+		return store.StatusTimeout, fmt.Sprintf("%s\n[cronlogger] command timed out after %v and was terminated\n", result.Output, timeout)
+	case result.ExitCode == 0:
+		return store.StatusSuccess, result.Output
+	default:
+		return store.StatusFailure, result.Output
+	}
+}
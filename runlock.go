@@ -0,0 +1,178 @@
+package cronlogger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// LockPolicy defines how to handle an execution if the previous run of the same
+// application is still active
+type LockPolicy string
+
+const (
+	// LockNone disables the locking, executions may overlap
+	LockNone LockPolicy = ""
+	// LockSkip does not execute the command if a previous run is still active
+	LockSkip LockPolicy = "skip"
+	// LockWait waits until the previous run has finished
+	LockWait LockPolicy = "wait"
+	// LockKillPrevious terminates the previous run and executes the command afterwards
+	LockKillPrevious LockPolicy = "kill-previous"
+)
+
+// ParseLockPolicy validates the given value and returns the matching policy
+func ParseLockPolicy(value string) (LockPolicy, error) {
+	switch p := LockPolicy(strings.ToLower(value)); p {
+	case LockNone, LockSkip, LockWait, LockKillPrevious:
+		return p, nil
+	}
+	return LockNone, fmt.Errorf("unknown lock policy '%s' (skip|wait|kill-previous)", value)
+}
+
+// ErrLocked is returned if the lock is held by a previous run and the policy is skip
+var ErrLocked = errors.New("previous run still active")
+
+const lockPollInterval = 100 * time.Millisecond
+
+var invalidLockChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// LockPath returns the path of the lock file for the given application
+func LockPath(dir, appName string) string {
+	return filepath.Join(dir, fmt.Sprintf("cronlogger-%s.lock", invalidLockChars.ReplaceAllString(appName, "_")))
+}
+
+// RunLock is an exclusive lock for an application based on flock.
+// The lock is released by the kernel if the process holding it terminates,
+// so stale lock files do not block further executions.
+type RunLock struct {
+	file *os.File
+}
+
+// AcquireLock obtains the lock for the given lock file according to the policy.
+// For LockKillPrevious the process group of the previous run receives a SIGTERM,
+// if the lock is not released within the grace period a SIGKILL follows.
+func AcquireLock(ctx context.Context, path string, policy LockPolicy, grace time.Duration) (*RunLock, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open lock file '%s'; %v", path, err)
+	}
+
+	ok, err := tryLock(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if ok {
+		return &RunLock{file: file}, nil
+	}
+
+	switch policy {
+	case LockSkip:
+		file.Close()
+		return nil, ErrLocked
+	case LockWait:
+		err = waitLock(ctx, file)
+	case LockKillPrevious:
+		err = killPrevious(ctx, file, grace)
+	default:
+		err = fmt.Errorf("unknown lock policy '%s'", policy)
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &RunLock{file: file}, nil
+}
+
+// SetPid records the pid of the running command in the lock file, it is
+// used by the kill-previous policy to terminate the command
+func (l *RunLock) SetPid(pid int) error {
+	if err := l.file.Truncate(0); err != nil {
+		return fmt.Errorf("cannot write lock file; %v", err)
+	}
+	if _, err := l.file.WriteAt([]byte(strconv.Itoa(pid)), 0); err != nil {
+		return fmt.Errorf("cannot write lock file; %v", err)
+	}
+	return nil
+}
+
+// Release gives up the lock
+func (l *RunLock) Release() error {
+	l.file.Truncate(0)
+	syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN)
+	return l.file.Close()
+}
+
+func tryLock(file *os.File) (bool, error) {
+	err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return false, nil
+	}
+	return false, fmt.Errorf("cannot lock file '%s'; %v", file.Name(), err)
+}
+
+// waitLock polls the lock until it is available or the context is done
+func waitLock(ctx context.Context, file *os.File) error {
+	ticker := time.NewTicker(lockPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("could not acquire lock '%s'; %v", file.Name(), ctx.Err())
+		case <-ticker.C:
+			ok, err := tryLock(file)
+			if err != nil {
+				return err
+			}
+			if ok {
+				return nil
+			}
+		}
+	}
+}
+
+func killPrevious(ctx context.Context, file *os.File, grace time.Duration) error {
+	pid := readPid(file)
+	if pid <= 0 {
+		// the previous run has not started its command yet
+		return waitLock(ctx, file)
+	}
+
+	// the command runs in its own process group, the pid is also the group id
+	syscall.Kill(-pid, syscall.SIGTERM)
+	graceCtx, cancel := context.WithTimeout(ctx, grace)
+	defer cancel()
+	if err := waitLock(graceCtx, file); err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("could not acquire lock '%s'; %v", file.Name(), ctx.Err())
+	}
+
+	// the previous run may have started another command in the meantime
+	if pid = readPid(file); pid > 0 {
+		syscall.Kill(-pid, syscall.SIGKILL)
+	}
+	return waitLock(ctx, file)
+}
+
+func readPid(file *os.File) int {
+	buf := make([]byte, 32)
+	n, _ := file.ReadAt(buf, 0)
+	pid, err := strconv.Atoi(strings.TrimSpace(string(buf[:n])))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
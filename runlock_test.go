@@ -0,0 +1,120 @@
+package cronlogger_test
+
+import (
+	"context"
+	"cronlogger"
+	"errors"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func Test_ParseLockPolicy(t *testing.T) {
+	for _, value := range []string{"", "skip", "wait", "kill-previous", "SKIP"} {
+		if _, err := cronlogger.ParseLockPolicy(value); err != nil {
+			t.Errorf("expected policy '%s' to be valid; %v", value, err)
+		}
+	}
+	if _, err := cronlogger.ParseLockPolicy("other"); err == nil {
+		t.Errorf("expected an error for an unknown policy")
+	}
+}
+
+func Test_LockPath(t *testing.T) {
+	path := cronlogger.LockPath("/var/cronlog", "rclone gdrive/../x")
+	if path != "/var/cronlog/cronlogger-rclone_gdrive_.._x.lock" {
+		t.Errorf("unexpected lock path %s", path)
+	}
+}
+
+func Test_AcquireLock_Skip(t *testing.T) {
+	path := cronlogger.LockPath(t.TempDir(), "test")
+
+	lock, err := cronlogger.AcquireLock(context.Background(), path, cronlogger.LockSkip, time.Second)
+	if err != nil {
+		t.Fatalf("could not acquire lock; %v", err)
+	}
+
+	_, err = cronlogger.AcquireLock(context.Background(), path, cronlogger.LockSkip, time.Second)
+	if !errors.Is(err, cronlogger.ErrLocked) {
+		t.Errorf("expected ErrLocked, got %v", err)
+	}
+
+	lock.Release()
+	lock, err = cronlogger.AcquireLock(context.Background(), path, cronlogger.LockSkip, time.Second)
+	if err != nil {
+		t.Fatalf("could not acquire lock after release; %v", err)
+	}
+	lock.Release()
+}
+
+func Test_AcquireLock_Wait(t *testing.T) {
+	path := cronlogger.LockPath(t.TempDir(), "test")
+
+	lock, err := cronlogger.AcquireLock(context.Background(), path, cronlogger.LockWait, time.Second)
+	if err != nil {
+		t.Fatalf("could not acquire lock; %v", err)
+	}
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		lock.Release()
+	}()
+
+	start := time.Now()
+	second, err := cronlogger.AcquireLock(context.Background(), path, cronlogger.LockWait, time.Second)
+	if err != nil {
+		t.Fatalf("could not acquire lock; %v", err)
+	}
+	defer second.Release()
+	if time.Since(start) < 200*time.Millisecond {
+		t.Errorf("the lock was acquired before the previous run released it")
+	}
+
+	// a canceled context stops waiting
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	_, err = cronlogger.AcquireLock(ctx, path, cronlogger.LockWait, time.Second)
+	if err == nil {
+		t.Errorf("expected an error for a canceled wait")
+	}
+}
+
+func Test_AcquireLock_KillPrevious(t *testing.T) {
+	path := cronlogger.LockPath(t.TempDir(), "test")
+
+	lock, err := cronlogger.AcquireLock(context.Background(), path, cronlogger.LockKillPrevious, time.Second)
+	if err != nil {
+		t.Fatalf("could not acquire lock; %v", err)
+	}
+
+	// the previous run: a command in its own process group which releases the lock once it ends
+	cmd := exec.Command("sh", "-c", "trap '' TERM; sleep 30")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("could not start command; %v", err)
+	}
+	lock.SetPid(cmd.Process.Pid)
+	state := make(chan string, 1)
+	go func() {
+		cmd.Wait()
+		lock.Release()
+		state <- cmd.ProcessState.String()
+	}()
+	// give the shell time to install the trap
+	time.Sleep(200 * time.Millisecond)
+
+	start := time.Now()
+	second, err := cronlogger.AcquireLock(context.Background(), path, cronlogger.LockKillPrevious, 300*time.Millisecond)
+	if err != nil {
+		t.Fatalf("could not acquire lock; %v", err)
+	}
+	defer second.Release()
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("the previous run was not killed in time")
+	}
+	if s := <-state; !strings.Contains(s, "killed") {
+		t.Errorf("expected the previous run to be killed, got %s", s)
+	}
+}
@@ -0,0 +1,196 @@
+package scheduler
+
+import (
+	"context"
+	"cronlogger"
+	"cronlogger/store"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/robfig/cron/v3"
+)
+
+// the scheduler replaces the crontab: the jobs are defined in the configuration
+// and executed in-process. The results are stored the same way the logger does.
+
+const defaultMaxConcurrency = 2
+
+// Scheduler executes the configured jobs according to their cron expressions
+type Scheduler struct {
+	store  store.OpResultStore
+	logger *slog.Logger
+	cron   *cron.Cron
+	jobs   map[string]cronlogger.RunParams
+	slots  chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	// manual executions are not tracked by cron
+	manual  sync.WaitGroup
+	mu      sync.Mutex
+	stopped bool
+}
+
+// ErrUnknownJob is returned if a job should be triggered which is not configured
+var ErrUnknownJob = errors.New("unknown job")
+
+// New validates the jobs of the configuration and creates a scheduler for them.
+// The lock files of the jobs are created in the lockDir.
+func New(store store.OpResultStore, logger *slog.Logger, config cronlogger.AppConfig, lockDir string) (*Scheduler, error) {
+	maxConcurrency := config.Scheduler.MaxConcurrency
+	if maxConcurrency <= 0 {
+		maxConcurrency = defaultMaxConcurrency
+	}
+	if config.Scheduler.LockDir != "" {
+		lockDir = config.Scheduler.LockDir
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Scheduler{
+		store:  store,
+		logger: logger,
+		cron:   cron.New(),
+		jobs:   make(map[string]cronlogger.RunParams),
+		slots:  make(chan struct{}, maxConcurrency),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	for _, job := range config.Jobs {
+		if job.Name == "" {
+			return nil, fmt.Errorf("a job needs a name")
+		}
+		if job.Command == "" {
+			return nil, fmt.Errorf("the job '%s' has no command", job.Name)
+		}
+		if _, ok := s.jobs[job.Name]; ok {
+			return nil, fmt.Errorf("the job '%s' is defined more than once", job.Name)
+		}
+		params, err := job.RunParams(lockDir)
+		if err != nil {
+			return nil, fmt.Errorf("invalid job '%s'; %v", job.Name, err)
+		}
+		// jobs without a schedule can only be triggered manually
+		name := job.Name
+		if job.Schedule != "" {
+			if _, err := s.cron.AddFunc(job.Schedule, func() { s.scheduled(name) }); err != nil {
+				return nil, fmt.Errorf("invalid schedule '%s' of job '%s'; %v", job.Schedule, job.Name, err)
+			}
+		}
+		s.jobs[name] = params
+	}
+	return s, nil
+}
+
+// Start begins to execute the jobs according to their schedule
+func (s *Scheduler) Start() {
+	s.logger.Info("starting the scheduler", "jobs", len(s.jobs))
+	s.cron.Start()
+}
+
+// Stop prevents further executions and waits for the running jobs.
+// If the context is done before the jobs have finished, the running jobs are terminated.
+func (s *Scheduler) Stop(ctx context.Context) {
+	s.mu.Lock()
+	s.stopped = true
+	s.mu.Unlock()
+
+	// the returned context is done once all running scheduled jobs have finished
+	cronStopped := s.cron.Stop()
+	stopped, done := context.WithCancel(context.Background())
+	go func() {
+		<-cronStopped.Done()
+		s.manual.Wait()
+		done()
+	}()
+
+	select {
+	case <-stopped.Done():
+	case <-ctx.Done():
+		s.logger.Warn("running jobs did not finish in time, terminating them")
+		s.cancel()
+		<-stopped.Done()
+	}
+	s.cancel()
+}
+
+// Trigger starts the job immediately, independent of its schedule. The returned item
+// has the status running, the result of the execution is stored once it has finished.
+func (s *Scheduler) Trigger(ctx context.Context, name, triggeredBy string) (store.OpResultEntity, error) {
+	if _, ok := s.jobs[name]; !ok {
+		return store.OpResultEntity{}, fmt.Errorf("%w '%s'", ErrUnknownJob, name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return store.OpResultEntity{}, fmt.Errorf("the scheduler is stopped")
+	}
+
+	item, err := s.store.Create(ctx, store.OpResultEntity{
+		App:         name,
+		Status:      store.StatusRunning,
+		Trigger:     store.TriggerManual,
+		TriggeredBy: triggeredBy,
+	})
+	if err != nil {
+		return store.OpResultEntity{}, fmt.Errorf("could not store the manual execution; %v", err)
+	}
+
+	s.logger.Info("job triggered manually", "app", name, "user", triggeredBy, "run", item.ID)
+	s.manual.Add(1)
+	go func() {
+		defer s.manual.Done()
+		s.complete(name, item)
+	}()
+	return item, nil
+}
+
+// scheduled executes the job according to its schedule. Like a manual execution the run
+// is stored with the status running before the execution and updated with the result.
+func (s *Scheduler) scheduled(name string) {
+	item, err := s.store.Create(context.Background(), store.OpResultEntity{
+		App:     name,
+		Status:  store.StatusRunning,
+		Trigger: store.TriggerSchedule,
+	})
+	if err != nil {
+		s.logger.Error("could not store the scheduled execution", "app", name, "error", err)
+		return
+	}
+	s.complete(name, item)
+}
+
+// complete executes the job of the stored run and updates the run with the result
+func (s *Scheduler) complete(name string, item store.OpResultEntity) {
+	result, ok := s.execute(name)
+	if !ok {
+		result = store.OpResultEntity{
+			Status: store.StatusSkipped,
+			Output: "[cronlogger] the scheduler was stopped before the job was started\n",
+		}
+	}
+	result.ID = item.ID
+	result.Created = item.Created
+	// the result is stored even if the scheduler is stopped meanwhile, a manual
+	// execution outlives the request which triggered it
+	if _, err := s.store.Update(context.Background(), result); err != nil {
+		s.logger.Error("could not store the result of the job", "app", name, "run", item.ID, "error", err)
+	}
+}
+
+// execute runs the job once a slot is available, false is returned if the
+// scheduler was stopped before the job could be started
+func (s *Scheduler) execute(name string) (store.OpResultEntity, bool) {
+	select {
+	case s.slots <- struct{}{}:
+		defer func() { <-s.slots }()
+	case <-s.ctx.Done():
+		return store.OpResultEntity{}, false
+	}
+
+	s.logger.Info("executing the job", "app", name)
+	item := cronlogger.Run(s.ctx, name, s.jobs[name])
+	s.logger.Info("the job finished", "app", name, "status", item.State())
+	return item, true
+}
@@ -0,0 +1,194 @@
+package scheduler_test
+
+import (
+	"context"
+	"cronlogger"
+	"cronlogger/scheduler"
+	"cronlogger/store"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+var logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func getStore(t *testing.T) store.OpResultStore {
+	s, db, err := store.CreateSqliteStoreFromDbPath(":memory:", store.Options{})
+	if err != nil {
+		t.Fatalf("cannot create database connection: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return s
+}
+
+func Test_New_InvalidJobs(t *testing.T) {
+	s := getStore(t)
+
+	invalid := map[string][]cronlogger.Job{
+		"no name":          {{Schedule: "@daily", Command: "true"}},
+		"no command":       {{Name: "test", Schedule: "@daily"}},
+		"invalid schedule": {{Name: "test", Schedule: "* * *", Command: "true"}},
+		"invalid lock":     {{Name: "test", Schedule: "@daily", Command: "true", Lock: "other"}},
+		"duplicate name": {
+			{Name: "test", Schedule: "@daily", Command: "true"},
+			{Name: "test", Schedule: "@hourly", Command: "true"},
+		},
+	}
+	for name, jobs := range invalid {
+		_, err := scheduler.New(s, logger, cronlogger.AppConfig{Jobs: jobs}, t.TempDir())
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	_, err := scheduler.New(s, logger, cronlogger.AppConfig{Jobs: []cronlogger.Job{
+		{Name: "test", Schedule: "0 3 * * *", Command: "true", Lock: "skip"},
+	}}, t.TempDir())
+	if err != nil {
+		t.Errorf("expected a valid job; %v", err)
+	}
+}
+
+func Test_Scheduler_ExecutesJobs(t *testing.T) {
+	s := getStore(t)
+	workDir := t.TempDir()
+
+	sched, err := scheduler.New(s, logger, cronlogger.AppConfig{Jobs: []cronlogger.Job{
+		{
+			Name:     "test",
+			Schedule: "@every 1s",
+			Command:  "pwd; echo $CRONLOGGER_TEST",
+			WorkDir:  workDir,
+			Env:      []string{"CRONLOGGER_TEST=from-env"},
+		},
+		{
+			Name:     "test-fail",
+			Schedule: "@every 1s",
+			Command:  "exit 1",
+		},
+	}}, t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create scheduler; %v", err)
+	}
+	sched.Start()
+	time.Sleep(1500 * time.Millisecond)
+	sched.Stop(context.Background())
+
+	items, err := s.GetAll(t.Context())
+	if err != nil {
+		t.Fatalf("could not get all items; %v", err)
+	}
+	if len(items) < 2 {
+		t.Fatalf("expected the jobs to be executed, got %d items", len(items))
+	}
+	for _, item := range items {
+		if item.Trigger != store.TriggerSchedule {
+			t.Errorf("expected a scheduled trigger, got %s", item.Trigger)
+		}
+		switch item.App {
+		case "test":
+			if item.State() != store.StatusSuccess {
+				t.Errorf("expected status success, got %s", item.State())
+			}
+			if item.Output != workDir+"\nfrom-env\n" {
+				t.Errorf("unexpected output %q", item.Output)
+			}
+		case "test-fail":
+			if item.State() != store.StatusFailure {
+				t.Errorf("expected status failure, got %s", item.State())
+			}
+		default:
+			t.Errorf("unexpected application %s", item.App)
+		}
+	}
+}
+
+func Test_Scheduler_StopTerminatesJobs(t *testing.T) {
+	s := getStore(t)
+
+	sched, err := scheduler.New(s, logger, cronlogger.AppConfig{Jobs: []cronlogger.Job{
+		{Name: "test", Schedule: "@every 1s", Command: "sleep 30"},
+	}}, t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create scheduler; %v", err)
+	}
+	sched.Start()
+	time.Sleep(1200 * time.Millisecond)
+
+	// the scheduled run is stored before the execution
+	running, err := s.GetAll(t.Context())
+	if err != nil {
+		t.Fatalf("could not get all items; %v", err)
+	}
+	if len(running) == 0 || running[0].State() != store.StatusRunning {
+		t.Errorf("expected the running job to be stored, got %v", running)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	sched.Stop(ctx)
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("the running job was not terminated")
+	}
+
+	items, err := s.GetAll(t.Context())
+	if err != nil {
+		t.Fatalf("could not get all items; %v", err)
+	}
+	// depending on the start within the second, the schedule fires once or twice
+	if len(items) == 0 {
+		t.Errorf("expected the terminated job to be stored")
+	}
+	for _, item := range items {
+		if item.State() != store.StatusFailure {
+			t.Errorf("expected the terminated job to be stored as failure, got %v", item)
+		}
+	}
+}
+
+func Test_Scheduler_Trigger(t *testing.T) {
+	s := getStore(t)
+
+	// a job without a schedule can only be triggered manually
+	sched, err := scheduler.New(s, logger, cronlogger.AppConfig{Jobs: []cronlogger.Job{
+		{Name: "test", Command: "sleep 0.3; echo manual"},
+	}}, t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create scheduler; %v", err)
+	}
+	sched.Start()
+
+	_, err = sched.Trigger(t.Context(), "other", "user")
+	if !errors.Is(err, scheduler.ErrUnknownJob) {
+		t.Errorf("expected ErrUnknownJob, got %v", err)
+	}
+
+	item, err := sched.Trigger(t.Context(), "test", "user")
+	if err != nil {
+		t.Fatalf("could not trigger the job; %v", err)
+	}
+	if item.State() != store.StatusRunning {
+		t.Errorf("expected a running item, got %s", item.State())
+	}
+
+	// stop waits for the manual execution
+	sched.Stop(context.Background())
+
+	item, err = s.GetById(t.Context(), item.ID)
+	if err != nil {
+		t.Fatalf("could not get item by ID; %v", err)
+	}
+	if item.State() != store.StatusSuccess || item.Output != "manual\n" {
+		t.Errorf("unexpected result of the manual execution %s/%q", item.State(), item.Output)
+	}
+	if item.Trigger != store.TriggerManual || item.TriggeredBy != "user" {
+		t.Errorf("expected a manual trigger by user, got %s/%s", item.Trigger, item.TriggeredBy)
+	}
+
+	if _, err = sched.Trigger(t.Context(), "test", "user"); err == nil {
+		t.Errorf("expected an error for a stopped scheduler")
+	}
+}
@@ -0,0 +1,56 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// SnapshotSqlite writes a consistent copy of the sqlite database to the given path with VACUUM INTO.
+// The snapshot is taken by a read connection, writers are not blocked while it is written.
+// PostgreSQL databases are backed up with the tools of the server, e.g. pg_dump.
+func SnapshotSqlite(ctx context.Context, con Connection, path string) error {
+	if isPostgres(con.R()) {
+		return fmt.Errorf("snapshots are only available for sqlite databases, use pg_dump for PostgreSQL")
+	}
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("the snapshot file '%s' already exists", path)
+	}
+	if err := con.R().WithContext(ctx).Exec("VACUUM INTO ?", path).Error; err != nil {
+		os.Remove(path)
+		return fmt.Errorf("could not create the snapshot '%s'; %v", path, err)
+	}
+	return nil
+}
+
+// VerifySqliteFile checks the integrity of a sqlite database file, e.g. of a snapshot
+func VerifySqliteFile(ctx context.Context, path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("the database file is not available; %v", err)
+	}
+	db := MustCreateSqliteConn(path, SqliteParam{Key: "mode", Val: "ro"})
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, "PRAGMA integrity_check")
+	if err != nil {
+		return fmt.Errorf("could not check the integrity of '%s'; %v", path, err)
+	}
+	defer rows.Close()
+	var problems []string
+	for rows.Next() {
+		var result string
+		if err := rows.Scan(&result); err != nil {
+			return fmt.Errorf("could not check the integrity of '%s'; %v", path, err)
+		}
+		if result != "ok" {
+			problems = append(problems, result)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("could not check the integrity of '%s'; %v", path, err)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("the integrity check of '%s' failed: %v", path, problems)
+	}
+	return nil
+}
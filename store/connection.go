@@ -1,9 +1,11 @@
 package store
 
 import (
+	"context"
 	"fmt"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
 // A Connection struct abstracts the access to the underlying database connections
@@ -43,12 +45,13 @@ func (c Connection) W() *gorm.DB {
 	return c.Write
 }
 
-// Begin starts a transaction and executes the provided handle function in a transaction context
-func (c Connection) Begin(handle func(c Connection) error) error {
+// Begin starts a transaction and executes the provided handle function in a transaction context.
+// The transaction is rolled back if the context is done before it is committed.
+func (c Connection) Begin(ctx context.Context, handle func(c Connection) error) error {
 	if c.Tx != nil {
 		return fmt.Errorf("a transaction is already available, will not start a new one")
 	}
-	return c.Write.Transaction(func(tx *gorm.DB) error {
+	return c.Write.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		return handle(Connection{
 			Read:  c.Read,
 			Write: c.Write,
@@ -56,3 +59,11 @@ func (c Connection) Begin(handle func(c Connection) error) error {
 		})
 	})
 }
+
+// defaultGormConfig is the gorm configuration shared by all database backends
+func defaultGormConfig() *gorm.Config {
+	return &gorm.Config{
+		Logger:                 logger.Default.LogMode(logger.Silent),
+		SkipDefaultTransaction: true,
+	}
+}
@@ -0,0 +1,58 @@
+package store
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// a cursor marks the position of the last item of a page. The next page starts
+// with the items created before this item (keyset pagination). Compared to an
+// offset the cursor stays stable if new items are created while paging.
+
+// ErrInvalidCursor is returned if a cursor token cannot be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// PageCursor is the position of an item in the list ordered by created, id
+type PageCursor struct {
+	Created time.Time
+	ID      string
+}
+
+// CursorOpResults is a page of items and the cursor to retrieve the next page
+type CursorOpResults struct {
+	Items []OpResultEntity
+	// NextCursor is empty if there are no more items
+	NextCursor string
+}
+
+// Encode returns the opaque token of the cursor
+func (c PageCursor) Encode() string {
+	// the timestamp is kept in the exact format stored in the database,
+	// otherwise the comparison of the created column would not match
+	raw := c.Created.Format(time.RFC3339Nano) + "|" + c.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor parses an opaque cursor token
+func DecodeCursor(token string) (PageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return PageCursor{}, fmt.Errorf("%w; %v", ErrInvalidCursor, err)
+	}
+	created, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return PageCursor{}, ErrInvalidCursor
+	}
+	t, err := time.Parse(time.RFC3339Nano, created)
+	if err != nil {
+		return PageCursor{}, fmt.Errorf("%w; %v", ErrInvalidCursor, err)
+	}
+	return PageCursor{Created: t, ID: id}, nil
+}
+
+func cursorOf(item OpResultEntity) PageCursor {
+	return PageCursor{Created: item.Created, ID: item.ID}
+}
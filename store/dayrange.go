@@ -0,0 +1,40 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// DateFormat is the format of the dates of a date-range filter
+const DateFormat = "2006-01-02"
+
+// ParseDayRange parses the dates of a date-range filter, e.g. the parameters of the API or the
+// flags of the export. The range starts at midnight of the from day and ends exclusively at
+// midnight of the day after the until day, the days are taken in the local time of the server.
+// An empty date does not restrict the range and is returned as nil.
+func ParseDayRange(from, until string) (*time.Time, *time.Time, error) {
+	start, err := parseDay(from)
+	if err != nil {
+		return nil, nil, err
+	}
+	end, err := parseDay(until)
+	if err != nil {
+		return nil, nil, err
+	}
+	if end != nil {
+		next := end.AddDate(0, 0, 1)
+		end = &next
+	}
+	return start, end, nil
+}
+
+func parseDay(input string) (*time.Time, error) {
+	if input == "" {
+		return nil, nil
+	}
+	t, err := time.ParseInLocation(DateFormat, input, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date '%s', expected format %s", input, DateFormat)
+	}
+	return &t, nil
+}
@@ -0,0 +1,189 @@
+package store
+
+import (
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// the store publishes an event once a change of a run is committed. Every subscriber
+// has its own bounded queue and goroutine, a slow subscriber therefore neither blocks
+// the store nor the other subscribers. If the queue of a subscriber is full, the event
+// is dropped for this subscriber and counted. The first drop is logged, further drops
+// at most once per dropWarnInterval.
+
+// EventType defines the change of a run
+type EventType string
+
+const (
+	RunCreated EventType = "run-created"
+	RunUpdated EventType = "run-updated"
+	RunDeleted EventType = "run-deleted"
+)
+
+// DefaultEventQueueSize is the number of events buffered for a subscriber
+const DefaultEventQueueSize = 100
+
+const dropWarnInterval = time.Minute
+
+// Event describes a committed change of a run
+type Event struct {
+	Type EventType
+	// Run is the state of the run after the change, the state before the deletion for RunDeleted
+	Run  OpResultEntity
+	Time time.Time
+}
+
+// Subscriber receives the events of the store
+type Subscriber interface {
+	HandleEvent(e Event)
+}
+
+// SubscriberFunc is an adapter to use a func as Subscriber
+type SubscriberFunc func(e Event)
+
+// HandleEvent calls f(e)
+func (f SubscriberFunc) HandleEvent(e Event) {
+	f(e)
+}
+
+// EventBus delivers the events of the store to the subscribers
+type EventBus struct {
+	logger *slog.Logger
+	mu     sync.RWMutex
+	subs   map[*subscription]struct{}
+	closed bool
+}
+
+type subscription struct {
+	name    string
+	queue   chan Event
+	done    chan struct{}
+	dropped atomic.Int64
+	// warned is the time of the last warning about dropped events in unix nanoseconds
+	warned atomic.Int64
+}
+
+// NewEventBus creates an event bus, the logger reports dropped events and failing subscribers
+func NewEventBus(logger *slog.Logger) *EventBus {
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	return &EventBus{
+		logger: logger,
+		subs:   make(map[*subscription]struct{}),
+	}
+}
+
+// Subscribe registers the subscriber with a queue of the given size (DefaultEventQueueSize if <= 0).
+// The returned func removes the subscriber, the events already queued are delivered before.
+func (b *EventBus) Subscribe(name string, queueSize int, subscriber Subscriber) (unsubscribe func()) {
+	if queueSize <= 0 {
+		queueSize = DefaultEventQueueSize
+	}
+	sub := &subscription{
+		name:  name,
+		queue: make(chan Event, queueSize),
+		done:  make(chan struct{}),
+	}
+	go b.deliver(sub, subscriber)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(sub.queue)
+		return func() {}
+	}
+	b.subs[sub] = struct{}{}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			if _, ok := b.subs[sub]; ok {
+				delete(b.subs, sub)
+				close(sub.queue)
+			}
+			b.mu.Unlock()
+			<-sub.done
+		})
+	}
+}
+
+// Publish queues the event for every subscriber without blocking
+func (b *EventBus) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return
+	}
+	for sub := range b.subs {
+		select {
+		case sub.queue <- e:
+		default:
+			b.warnDropped(sub, sub.dropped.Add(1))
+		}
+	}
+}
+
+// warnDropped logs the number of dropped events, a full queue must not flood the log
+func (b *EventBus) warnDropped(sub *subscription, dropped int64) {
+	now := time.Now().UnixNano()
+	last := sub.warned.Load()
+	if last != 0 && now-last < int64(dropWarnInterval) || !sub.warned.CompareAndSwap(last, now) {
+		return
+	}
+	b.logger.Warn("the event queue of the subscriber is full, dropped events", "subscriber", sub.name, "dropped", dropped)
+}
+
+// Dropped returns the number of events dropped per subscriber
+func (b *EventBus) Dropped() map[string]int64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	dropped := make(map[string]int64, len(b.subs))
+	for sub := range b.subs {
+		dropped[sub.name] += sub.dropped.Load()
+	}
+	return dropped
+}
+
+// Close stops accepting events and waits until the queued events are delivered
+func (b *EventBus) Close() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.closed = true
+	subs := b.subs
+	b.subs = make(map[*subscription]struct{})
+	for sub := range subs {
+		close(sub.queue)
+	}
+	b.mu.Unlock()
+
+	for sub := range subs {
+		<-sub.done
+	}
+}
+
+func (b *EventBus) deliver(sub *subscription, subscriber Subscriber) {
+	defer close(sub.done)
+	for e := range sub.queue {
+		b.handle(sub, subscriber, e)
+	}
+}
+
+// handle calls the subscriber, a panic of the subscriber must not stop the delivery
+func (b *EventBus) handle(sub *subscription, subscriber Subscriber, e Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			b.logger.Error("the subscriber failed to handle the event", "subscriber", sub.name, "type", e.Type, "run", e.Run.ID, "panic", r)
+		}
+	}()
+	subscriber.HandleEvent(e)
+}
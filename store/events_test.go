@@ -0,0 +1,160 @@
+package store_test
+
+import (
+	"bytes"
+	"cronlogger/store"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// eventRecorder collects the received events
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []store.Event
+}
+
+func (r *eventRecorder) HandleEvent(e store.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *eventRecorder) received() []store.Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]store.Event(nil), r.events...)
+}
+
+func Test_Store_Events(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend, func(t *testing.T) {
+			bus := store.NewEventBus(nil)
+			recorder := &eventRecorder{}
+			bus.Subscribe("recorder", 0, recorder)
+			s := getStore(t, backend, store.Options{Events: bus})
+
+			item, err := s.Create(t.Context(), store.OpResultEntity{App: "events", Status: store.StatusRunning, Trigger: store.TriggerManual, TriggeredBy: "alice"})
+			if err != nil {
+				t.Fatalf("could not create item; %v", err)
+			}
+			// the update only supplies the result
+			if _, err := s.Update(t.Context(), store.OpResultEntity{ID: item.ID, Status: store.StatusSuccess}); err != nil {
+				t.Fatalf("could not update item; %v", err)
+			}
+			if err := s.Delete(t.Context(), item.ID); err != nil {
+				t.Fatalf("could not delete item; %v", err)
+			}
+			if _, err := s.GetById(t.Context(), item.ID); err == nil {
+				t.Errorf("the deleted item is still available")
+			}
+
+			// failed changes are not published
+			if _, err := s.Update(t.Context(), store.OpResultEntity{ID: "unknown"}); err == nil {
+				t.Errorf("expected an error updating an unknown item")
+			}
+			if err := s.Delete(t.Context(), "unknown"); err == nil {
+				t.Errorf("expected an error deleting an unknown item")
+			}
+
+			// close delivers the queued events
+			bus.Close()
+			events := recorder.received()
+			expected := []store.EventType{store.RunCreated, store.RunUpdated, store.RunDeleted}
+			if len(events) != len(expected) {
+				t.Fatalf("expected %d events, got %d", len(expected), len(events))
+			}
+			for i, e := range events {
+				if e.Type != expected[i] {
+					t.Errorf("expected event %s, got %s", expected[i], e.Type)
+				}
+				if e.Run.ID != item.ID || e.Time.IsZero() {
+					t.Errorf("the event does not describe the run: %+v", e)
+				}
+			}
+			if events[1].Run.Status != store.StatusSuccess || events[2].Run.App != "events" {
+				t.Errorf("the events do not contain the state of the run: %+v", events)
+			}
+			if updated := events[1].Run; updated.App != "events" || updated.Trigger != store.TriggerManual || updated.TriggeredBy != "alice" || updated.Created.IsZero() {
+				t.Errorf("the update event does not contain the complete run: %+v", updated)
+			}
+		})
+	}
+}
+
+func Test_Events_SlowSubscriber(t *testing.T) {
+	var log bytes.Buffer
+	bus := store.NewEventBus(slog.New(slog.NewTextHandler(&log, nil)))
+	defer bus.Close()
+
+	started, block := make(chan struct{}, 10), make(chan struct{})
+	slow := store.SubscriberFunc(func(e store.Event) {
+		started <- struct{}{}
+		<-block
+	})
+	fast := &eventRecorder{}
+	bus.Subscribe("slow", 2, slow)
+	bus.Subscribe("fast", 20, fast)
+	s := getStore(t, "sqlite", store.Options{Events: bus})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 10 {
+			if i == 1 {
+				// the slow subscriber is busy with the first event
+				<-started
+			}
+			if _, err := s.Create(t.Context(), store.OpResultEntity{App: fmt.Sprintf("app_%d", i)}); err != nil {
+				t.Errorf("could not create item; %v", err)
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the slow subscriber blocks the store")
+	}
+
+	// the slow subscriber holds one event and queues two, the others are dropped
+	dropped := bus.Dropped()
+	if dropped["slow"] != 7 {
+		t.Errorf("expected 7 dropped events of the slow subscriber, got %d", dropped["slow"])
+	}
+	if dropped["fast"] != 0 {
+		t.Errorf("expected no dropped events of the fast subscriber, got %d", dropped["fast"])
+	}
+	// only the first drop is logged
+	if n := strings.Count(log.String(), "is full"); n != 1 {
+		t.Errorf("expected one warning about the dropped events, got %d", n)
+	}
+	close(block)
+	bus.Close()
+	if n := len(fast.received()); n != 10 {
+		t.Errorf("expected 10 events for the fast subscriber, got %d", n)
+	}
+}
+
+func Test_Events_Unsubscribe(t *testing.T) {
+	bus := store.NewEventBus(nil)
+	defer bus.Close()
+
+	recorder := &eventRecorder{}
+	unsubscribe := bus.Subscribe("recorder", 10, recorder)
+	failing := store.SubscriberFunc(func(e store.Event) { panic("subscriber failed") })
+	bus.Subscribe("failing", 10, failing)
+
+	bus.Publish(store.Event{Type: store.RunCreated, Run: store.OpResultEntity{ID: "1"}})
+	unsubscribe()
+	bus.Publish(store.Event{Type: store.RunCreated, Run: store.OpResultEntity{ID: "2"}})
+
+	events := recorder.received()
+	if len(events) != 1 || events[0].Run.ID != "1" {
+		t.Errorf("expected only the event before unsubscribe, got %+v", events)
+	}
+	// unsubscribe may be called more than once
+	unsubscribe()
+}
@@ -0,0 +1,177 @@
+package store
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// the runs are exported as CSV or JSON Lines (NDJSON), the items are written
+// while they are retrieved from the store and never held in memory at once.
+
+// ExportFormat defines the file format of an export
+type ExportFormat string
+
+const (
+	ExportCSV   ExportFormat = "csv"
+	ExportJSONL ExportFormat = "jsonl"
+)
+
+// ParseExportFormat validates the given format, ndjson is accepted as an alias of jsonl
+func ParseExportFormat(format string) (ExportFormat, error) {
+	switch format {
+	case "csv":
+		return ExportCSV, nil
+	case "jsonl", "ndjson":
+		return ExportJSONL, nil
+	}
+	return "", fmt.Errorf("unknown export format '%s', expected csv or jsonl", format)
+}
+
+// ContentType returns the media type of the format
+func (f ExportFormat) ContentType() string {
+	if f == ExportCSV {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/x-ndjson"
+}
+
+// RunRecord is the exported representation of an execution
+type RunRecord struct {
+	ID           string     `json:"id"`
+	App          string     `json:"application"`
+	Status       RunStatus  `json:"status"`
+	Created      time.Time  `json:"created"`
+	AttemptCount int        `json:"attemptCount"`
+	Trigger      RunTrigger `json:"trigger,omitempty"`
+	TriggeredBy  string     `json:"triggeredBy,omitempty"`
+	Host         string     `json:"host,omitempty"`
+	Output       string     `json:"output"`
+	// Attempts are the executions of a retried run, they are only exported as JSON Lines
+	Attempts []AttemptRecord `json:"attempts,omitempty"`
+}
+
+// AttemptRecord is the exported representation of an attempt of a retried execution
+type AttemptRecord struct {
+	ID       string    `json:"id"`
+	Attempt  int       `json:"attempt"`
+	Status   RunStatus `json:"status"`
+	ExitCode int       `json:"exitCode"`
+	Created  time.Time `json:"created"`
+	Output   string    `json:"output"`
+}
+
+// RecordOf converts a stored item into its exported representation
+func RecordOf(item OpResultEntity) RunRecord {
+	var attempts []AttemptRecord
+	for _, a := range item.Attempts {
+		attempts = append(attempts, AttemptRecord{ID: a.ID, Attempt: a.Attempt, Status: a.Status, ExitCode: a.ExitCode, Created: a.Created, Output: a.Output})
+	}
+	return RunRecord{
+		ID:           item.ID,
+		App:          item.App,
+		Status:       item.State(),
+		Created:      item.Created,
+		AttemptCount: item.AttemptCount,
+		Trigger:      item.Trigger,
+		TriggeredBy:  item.TriggeredBy,
+		Host:         item.Host,
+		Output:       item.Output,
+		Attempts:     attempts,
+	}
+}
+
+// Entity converts an exported record back into an item
+func (r RunRecord) Entity() OpResultEntity {
+	var attempts []OpAttemptEntity
+	for _, a := range r.Attempts {
+		attempts = append(attempts, OpAttemptEntity{ID: a.ID, RunID: r.ID, Attempt: a.Attempt, Status: a.Status, ExitCode: a.ExitCode, Created: a.Created, Output: a.Output})
+	}
+	return OpResultEntity{
+		ID:           r.ID,
+		App:          r.App,
+		Status:       r.Status,
+		Created:      r.Created,
+		AttemptCount: r.AttemptCount,
+		Trigger:      r.Trigger,
+		TriggeredBy:  r.TriggeredBy,
+		Host:         r.Host,
+		Output:       r.Output,
+		Attempts:     attempts,
+	}
+}
+
+// withAttempts adds the attempts of a retried item, ForEachItem only reads the items
+func withAttempts(ctx context.Context, s OpResultStore, item OpResultEntity) (OpResultEntity, error) {
+	if item.AttemptCount <= 1 {
+		return item, nil
+	}
+	full, err := s.GetById(ctx, item.ID)
+	if err != nil {
+		return item, err
+	}
+	item.Attempts = full.Attempts
+	return item, nil
+}
+
+var csvHeader = []string{"id", "application", "status", "created", "attempt_count", "trigger", "triggered_by", "output", "host"}
+
+// Export writes the items matching the filters in the given format and returns the number of exported items
+func Export(ctx context.Context, s OpResultStore, w io.Writer, format ExportFormat, from, until *time.Time, appName string) (int, error) {
+	var (
+		count int
+		err   error
+	)
+	switch format {
+	case ExportCSV:
+		out := csv.NewWriter(w)
+		if err := out.Write(csvHeader); err != nil {
+			return 0, fmt.Errorf("could not write the export; %v", err)
+		}
+		err = s.ForEachItem(ctx, from, until, appName, func(item OpResultEntity) error {
+			r := RecordOf(item)
+			err := out.Write([]string{
+				r.ID,
+				r.App,
+				string(r.Status),
+				r.Created.Format(time.RFC3339),
+				strconv.Itoa(r.AttemptCount),
+				string(r.Trigger),
+				r.TriggeredBy,
+				r.Output,
+				r.Host,
+			})
+			if err == nil {
+				count++
+			}
+			return err
+		})
+		out.Flush()
+		if err == nil {
+			err = out.Error()
+		}
+	case ExportJSONL:
+		enc := json.NewEncoder(w)
+		err = s.ForEachItem(ctx, from, until, appName, func(item OpResultEntity) error {
+			item, err := withAttempts(ctx, s, item)
+			if err != nil {
+				return err
+			}
+			if err := enc.Encode(RecordOf(item)); err != nil {
+				return err
+			}
+			count++
+			return nil
+		})
+	default:
+		return 0, fmt.Errorf("unknown export format '%s'", format)
+	}
+	if err != nil {
+		return count, fmt.Errorf("could not export the items; %v", err)
+	}
+	return count, nil
+}
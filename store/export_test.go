@@ -0,0 +1,161 @@
+package store_test
+
+import (
+	"bufio"
+	"bytes"
+	"cronlogger/store"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func Test_ForEachItem(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s store.OpResultStore) {
+		// more items than a single batch of the iteration
+		const total = 1234
+		for i := range total {
+			app := "even"
+			if i%2 == 1 {
+				app = "odd"
+			}
+			if _, err := s.Create(t.Context(), store.OpResultEntity{App: app, Output: fmt.Sprintf("%d", i)}); err != nil {
+				t.Fatalf("could not create item; %v", err)
+			}
+		}
+
+		var (
+			count int
+			last  *store.OpResultEntity
+			ids   = make(map[string]bool)
+		)
+		err := s.ForEachItem(t.Context(), nil, nil, "", func(item store.OpResultEntity) error {
+			if last != nil && item.Created.After(last.Created) {
+				t.Errorf("the items are not ordered by the creation date")
+			}
+			if ids[item.ID] {
+				t.Errorf("the item %s is returned twice", item.ID)
+			}
+			ids[item.ID] = true
+			last = &item
+			count++
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("could not iterate the items; %v", err)
+		}
+		if count != total {
+			t.Errorf("expected %d items, got %d", total, count)
+		}
+
+		count = 0
+		err = s.ForEachItem(t.Context(), nil, nil, "odd", func(item store.OpResultEntity) error {
+			if item.App != "odd" {
+				t.Errorf("the application filter is not applied: %s", item.App)
+			}
+			count++
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("could not iterate the items; %v", err)
+		}
+		if count != total/2 {
+			t.Errorf("expected %d items, got %d", total/2, count)
+		}
+
+		// an error of the handler stops the iteration
+		count = 0
+		stop := fmt.Errorf("stop")
+		err = s.ForEachItem(t.Context(), nil, nil, "", func(item store.OpResultEntity) error {
+			count++
+			return stop
+		})
+		if err != stop || count != 1 {
+			t.Errorf("expected the iteration to stop after 1 item, got %d items and error %v", count, err)
+		}
+	})
+}
+
+func Test_Export(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s store.OpResultStore) {
+		_, err := s.Create(t.Context(), store.OpResultEntity{App: "acme-tls", Status: store.StatusSuccess, Output: "renewed\n\"ok\", done"})
+		if err != nil {
+			t.Fatalf("could not create item; %v", err)
+		}
+		_, err = s.Create(t.Context(), store.OpResultEntity{App: "acme-tls", Status: store.StatusFailure, Trigger: store.TriggerManual, TriggeredBy: "admin"})
+		if err != nil {
+			t.Fatalf("could not create item; %v", err)
+		}
+		if _, err = s.Create(t.Context(), store.OpResultEntity{App: "backup", Status: store.StatusSuccess}); err != nil {
+			t.Fatalf("could not create item; %v", err)
+		}
+
+		var buf bytes.Buffer
+		count, err := store.Export(t.Context(), s, &buf, store.ExportCSV, nil, nil, "acme-tls")
+		if err != nil {
+			t.Fatalf("could not export; %v", err)
+		}
+		if count != 2 {
+			t.Errorf("expected 2 exported items, got %d", count)
+		}
+		records, err := csv.NewReader(&buf).ReadAll()
+		if err != nil {
+			t.Fatalf("the export is no valid CSV; %v", err)
+		}
+		if len(records) != 3 || records[0][0] != "id" {
+			t.Fatalf("expected a header and 2 records, got %v", records)
+		}
+		if records[1][2] != "failure" || records[1][5] != "manual" || records[1][6] != "admin" {
+			t.Errorf("unexpected record %v", records[1])
+		}
+		if records[2][7] != "renewed\n\"ok\", done" {
+			t.Errorf("the output is not preserved: %q", records[2][7])
+		}
+
+		buf.Reset()
+		from := time.Now().Add(-time.Hour)
+		count, err = store.Export(t.Context(), s, &buf, store.ExportJSONL, &from, nil, "")
+		if err != nil {
+			t.Fatalf("could not export; %v", err)
+		}
+		if count != 3 {
+			t.Errorf("expected 3 exported items, got %d", count)
+		}
+		lines := 0
+		scanner := bufio.NewScanner(&buf)
+		for scanner.Scan() {
+			var record store.RunRecord
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				t.Fatalf("the line is no valid JSON; %v", err)
+			}
+			if record.ID == "" || record.App == "" || record.Status == "" {
+				t.Errorf("the record is incomplete: %+v", record)
+			}
+			lines++
+		}
+		if lines != 3 {
+			t.Errorf("expected 3 lines, got %d", lines)
+		}
+
+		// the date filter is applied
+		buf.Reset()
+		until := time.Now().Add(-time.Hour)
+		count, err = store.Export(t.Context(), s, &buf, store.ExportJSONL, nil, &until, "")
+		if err != nil || count != 0 || buf.Len() != 0 {
+			t.Errorf("expected an empty export, got %d items and error %v", count, err)
+		}
+	})
+}
+
+func Test_ParseExportFormat(t *testing.T) {
+	for input, expected := range map[string]store.ExportFormat{"csv": store.ExportCSV, "jsonl": store.ExportJSONL, "ndjson": store.ExportJSONL} {
+		format, err := store.ParseExportFormat(input)
+		if err != nil || format != expected {
+			t.Errorf("expected %s for %s, got %s (%v)", expected, input, format, err)
+		}
+	}
+	if _, err := store.ParseExportFormat("xml"); err == nil {
+		t.Errorf("expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,166 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// the runs of another database or of a JSON Lines export are merged into a store.
+// The IDs and creation dates are preserved, runs which are already available are
+// skipped, so an import can be repeated.
+
+// importBatchSize is the number of items stored in one transaction
+const importBatchSize = 500
+
+// ImportSource calls handle for every item to import
+type ImportSource func(handle func(item OpResultEntity) error) error
+
+// ImportResult summarizes an import
+type ImportResult struct {
+	Read     int
+	Imported int
+	Skipped  int
+}
+
+// ImportItems stores the items of the source in the store. The host is set for items
+// without an origin host, it records where the items were collected.
+func ImportItems(ctx context.Context, s OpResultStore, source ImportSource, host string) (ImportResult, error) {
+	var (
+		result ImportResult
+		batch  = make([]OpResultEntity, 0, importBatchSize)
+	)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		imported, err := s.Import(ctx, batch)
+		if err != nil {
+			return err
+		}
+		result.Imported += imported
+		result.Skipped += len(batch) - imported
+		batch = batch[:0]
+		return nil
+	}
+
+	err := source(func(item OpResultEntity) error {
+		result.Read++
+		if item.Host == "" {
+			item.Host = host
+		}
+		batch = append(batch, item)
+		if len(batch) == importBatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return result, fmt.Errorf("the import failed after %d items; %v", result.Read, err)
+	}
+	return result, nil
+}
+
+// JSONLSource reads the items of a JSON Lines export, see Export
+func JSONLSource(r io.Reader) ImportSource {
+	return func(handle func(item OpResultEntity) error) error {
+		scanner := bufio.NewScanner(r)
+		// the output of an execution might exceed the default line length
+		scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		line := 0
+		for scanner.Scan() {
+			line++
+			if len(scanner.Bytes()) == 0 {
+				continue
+			}
+			var record RunRecord
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				return fmt.Errorf("invalid record in line %d; %v", line, err)
+			}
+			if err := handle(record.Entity()); err != nil {
+				return err
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("could not read the export; %v", err)
+		}
+		return nil
+	}
+}
+
+// StoreSource reads the items of another store including the attempts of retried items
+func StoreSource(ctx context.Context, s OpResultStore) ImportSource {
+	return func(handle func(item OpResultEntity) error) error {
+		return s.ForEachItem(ctx, nil, nil, "", func(item OpResultEntity) error {
+			item, err := withAttempts(ctx, s, item)
+			if err != nil {
+				return err
+			}
+			return handle(item)
+		})
+	}
+}
+
+// OpenStoreSource opens the database of the DSN as source of an import without changing it.
+// A sqlite file is opened read-only and copied to a temporary file with VACUUM INTO, the schema
+// of the copy is migrated. A PostgreSQL database is read directly, its schema has to be up to date.
+// The returned closer closes the database and removes the copy.
+func OpenStoreSource(ctx context.Context, dsn string) (ImportSource, io.Closer, error) {
+	opts := Options{QueryTimeout: DefaultQueryTimeout}
+	if IsPostgresDsn(dsn) {
+		con, db, err := CreatePostgresConFromDsn(dsn)
+		if err != nil {
+			return nil, nil, err
+		}
+		version, err := SchemaVersion(con)
+		if err == nil && version != LatestSchemaVersion() {
+			err = fmt.Errorf("the schema version of the source is %d instead of %d, migrate the source first", version, LatestSchemaVersion())
+		}
+		if err != nil {
+			db.Close()
+			return nil, nil, err
+		}
+		return StoreSource(ctx, CreateStore(con, opts)), db, nil
+	}
+
+	if _, err := os.Stat(dsn); err != nil {
+		return nil, nil, fmt.Errorf("the source database is not available; %v", err)
+	}
+	dir, err := os.MkdirTemp("", "cronlogger-import-")
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not create the directory of the copy; %v", err)
+	}
+	path := filepath.Join(dir, "source.db")
+	src := MustCreateSqliteConn(dsn, SqliteParam{Key: "mode", Val: "ro"})
+	_, err = src.ExecContext(ctx, "VACUUM INTO ?", path)
+	src.Close()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, fmt.Errorf("could not copy the source database; %v", err)
+	}
+
+	s, db, err := CreateSqliteStoreFromDbPath(path, opts)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, err
+	}
+	return StoreSource(ctx, s), tempStore{db, dir}, nil
+}
+
+// tempStore closes the database of a temporary copy and removes the copy
+type tempStore struct {
+	db  io.Closer
+	dir string
+}
+
+func (t tempStore) Close() error {
+	return errors.Join(t.db.Close(), os.RemoveAll(t.dir))
+}
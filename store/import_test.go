@@ -0,0 +1,239 @@
+package store_test
+
+import (
+	"bytes"
+	"cronlogger/store"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sameTime(a, b time.Time) bool {
+	// PostgreSQL stores microseconds
+	return a.Truncate(time.Microsecond).Equal(b.Truncate(time.Microsecond))
+}
+
+func Test_Import(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s store.OpResultStore) {
+		created := time.Now().Add(-48 * time.Hour).Truncate(time.Microsecond)
+		items := []store.OpResultEntity{
+			{ID: "import-1", App: "acme-tls", Status: store.StatusSuccess, Output: "ok", Created: created, Host: "web1"},
+			{ID: "import-2", App: "acme-tls", Status: store.StatusFailure, Created: created.Add(time.Minute), AttemptCount: 2, Attempts: []store.OpAttemptEntity{
+				{ID: "attempt-1", Attempt: 1, Status: store.StatusFailure, ExitCode: 1, Output: "first"},
+				{ID: "attempt-2", Attempt: 2, Status: store.StatusFailure, ExitCode: 2, Output: "second"},
+			}},
+		}
+		imported, err := s.Import(t.Context(), items)
+		if err != nil {
+			t.Fatalf("could not import items; %v", err)
+		}
+		if imported != 2 {
+			t.Errorf("expected 2 imported items, got %d", imported)
+		}
+
+		item, err := s.GetById(t.Context(), "import-1")
+		if err != nil {
+			t.Fatalf("could not get the imported item; %v", err)
+		}
+		if !sameTime(item.Created, created) || item.Host != "web1" || !item.Success || item.Output != "ok" {
+			t.Errorf("the imported item was changed: %+v", item)
+		}
+		item, err = s.GetById(t.Context(), "import-2")
+		if err != nil {
+			t.Fatalf("could not get the imported item; %v", err)
+		}
+		if len(item.Attempts) != 2 || item.Attempts[1].ID != "attempt-2" || item.Attempts[1].Output != "second" {
+			t.Errorf("the attempts were not imported: %+v", item.Attempts)
+		}
+
+		// the available items are skipped
+		items[0].Output = "changed"
+		items = append(items, store.OpResultEntity{ID: "import-3", App: "backup", Created: created})
+		imported, err = s.Import(t.Context(), items)
+		if err != nil {
+			t.Fatalf("could not import items; %v", err)
+		}
+		if imported != 1 {
+			t.Errorf("expected 1 imported item, got %d", imported)
+		}
+		item, _ = s.GetById(t.Context(), "import-1")
+		if item.Output != "ok" {
+			t.Errorf("an available item was overwritten: %+v", item)
+		}
+
+		if _, err := s.Import(t.Context(), []store.OpResultEntity{{App: "no-id", Created: created}}); err == nil {
+			t.Errorf("expected an error for an item without id")
+		}
+	})
+}
+
+func Test_ImportItems_JSONL(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, target store.OpResultStore) {
+		source := getStore(t, "sqlite", store.Options{})
+		for _, app := range []string{"acme-tls", "backup", "acme-tls"} {
+			if _, err := source.Create(t.Context(), store.OpResultEntity{App: app, Status: store.StatusSuccess, Output: "line\n"}); err != nil {
+				t.Fatalf("could not create item; %v", err)
+			}
+		}
+		// the attempts of a retried run are part of the export
+		retried, err := source.Create(t.Context(), store.OpResultEntity{App: "rclone", Status: store.StatusSuccess, Attempts: []store.OpAttemptEntity{
+			{Attempt: 1, Status: store.StatusFailure, ExitCode: 3, Output: "connection reset", Created: time.Now().Add(-time.Minute)},
+			{Attempt: 2, Status: store.StatusSuccess, Output: "done"},
+		}})
+		if err != nil {
+			t.Fatalf("could not create item; %v", err)
+		}
+		var export bytes.Buffer
+		if _, err := store.Export(t.Context(), source, &export, store.ExportJSONL, nil, nil, ""); err != nil {
+			t.Fatalf("could not export; %v", err)
+		}
+		data := export.String()
+
+		result, err := store.ImportItems(t.Context(), target, store.JSONLSource(strings.NewReader(data)), "web1")
+		if err != nil {
+			t.Fatalf("could not import; %v", err)
+		}
+		if result != (store.ImportResult{Read: 4, Imported: 4}) {
+			t.Errorf("unexpected result %+v", result)
+		}
+		item, err := target.GetById(t.Context(), retried.ID)
+		if err != nil {
+			t.Fatalf("could not get the imported item; %v", err)
+		}
+		if len(item.Attempts) != 2 {
+			t.Fatalf("expected the attempts of the retried run, got %+v", item.Attempts)
+		}
+		for i, attempt := range item.Attempts {
+			expected := retried.Attempts[i]
+			if attempt.ID != expected.ID || attempt.Status != expected.Status || attempt.ExitCode != expected.ExitCode || attempt.Output != expected.Output || !sameTime(attempt.Created, expected.Created) {
+				t.Errorf("the imported attempt differs: %+v, expected %+v", attempt, expected)
+			}
+		}
+
+		expected, _ := source.GetAll(t.Context())
+		items, err := target.GetAll(t.Context())
+		if err != nil {
+			t.Fatalf("could not get all items; %v", err)
+		}
+		if len(items) != len(expected) {
+			t.Fatalf("expected %d items, got %d", len(expected), len(items))
+		}
+		for i, item := range items {
+			if item.ID != expected[i].ID || !sameTime(item.Created, expected[i].Created) || item.App != expected[i].App || item.Output != expected[i].Output {
+				t.Errorf("the imported item differs: %+v, expected %+v", item, expected[i])
+			}
+			if item.Host != "web1" {
+				t.Errorf("the origin host is not set: %+v", item)
+			}
+		}
+
+		// a repeated import skips the duplicates
+		result, err = store.ImportItems(t.Context(), target, store.JSONLSource(strings.NewReader(data)), "web1")
+		if err != nil {
+			t.Fatalf("could not import; %v", err)
+		}
+		if result != (store.ImportResult{Read: 4, Skipped: 4}) {
+			t.Errorf("unexpected result %+v", result)
+		}
+
+		_, err = store.ImportItems(t.Context(), target, store.JSONLSource(strings.NewReader("{invalid")), "web1")
+		if err == nil {
+			t.Errorf("expected an error for an invalid record")
+		}
+	})
+}
+
+func Test_ImportItems_Store(t *testing.T) {
+	source := getStore(t, "sqlite", store.Options{})
+	target := getStore(t, "sqlite", store.Options{})
+	retried, err := source.Create(t.Context(), store.OpResultEntity{App: "rclone", Status: store.StatusSuccess, Attempts: []store.OpAttemptEntity{
+		{Attempt: 1, Status: store.StatusTimeout, ExitCode: -1},
+		{Attempt: 2, Status: store.StatusSuccess},
+	}})
+	if err != nil {
+		t.Fatalf("could not create item; %v", err)
+	}
+	if _, err := source.Create(t.Context(), store.OpResultEntity{App: "rclone", Status: store.StatusSuccess, Host: "nas"}); err != nil {
+		t.Fatalf("could not create item; %v", err)
+	}
+
+	result, err := store.ImportItems(t.Context(), target, store.StoreSource(t.Context(), source), "web2")
+	if err != nil {
+		t.Fatalf("could not import; %v", err)
+	}
+	if result != (store.ImportResult{Read: 2, Imported: 2}) {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	item, err := target.GetById(t.Context(), retried.ID)
+	if err != nil {
+		t.Fatalf("could not get the imported item; %v", err)
+	}
+	if len(item.Attempts) != 2 || item.Attempts[0].ID != retried.Attempts[0].ID || item.Host != "web2" {
+		t.Errorf("the item was not imported with its attempts: %+v", item)
+	}
+	hosts := map[string]bool{}
+	items, _ := target.GetAll(t.Context())
+	for _, item := range items {
+		hosts[item.Host] = true
+	}
+	if !hosts["nas"] || !hosts["web2"] {
+		t.Errorf("the available origin host was not preserved: %v", hosts)
+	}
+}
+
+func Test_OpenStoreSource(t *testing.T) {
+	// a source with the baseline schema is read without changing it
+	path := createDbFile(t)
+	con, db, err := store.CreateSqliteConFromDbPath(path)
+	if err != nil {
+		t.Fatalf("cannot create database connection: %v", err)
+	}
+	if err := con.Write.Exec(baselineSchema).Error; err != nil {
+		t.Fatalf("cannot create baseline schema: %v", err)
+	}
+	err = con.Write.Exec("INSERT INTO OPRESULTS (id, application, success, output, created) VALUES (?, ?, ?, ?, ?)",
+		"id-failure", "baseline", false, "output", time.Now().Add(-time.Hour)).Error
+	if err != nil {
+		t.Fatalf("cannot insert baseline data: %v", err)
+	}
+	db.Close()
+	// the changes of a writer would be in the file or in its WAL
+	read := func() []byte {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("cannot read the source: %v", err)
+		}
+		wal, _ := os.ReadFile(path + "-wal")
+		return append(content, wal...)
+	}
+	before := read()
+
+	source, closer, err := store.OpenStoreSource(t.Context(), path)
+	if err != nil {
+		t.Fatalf("could not open the source; %v", err)
+	}
+	target := getStore(t, "sqlite", store.Options{})
+	result, err := store.ImportItems(t.Context(), target, source, "web3")
+	closer.Close()
+	if err != nil {
+		t.Fatalf("could not import; %v", err)
+	}
+	if result != (store.ImportResult{Read: 1, Imported: 1}) {
+		t.Errorf("unexpected result %+v", result)
+	}
+	item, err := target.GetById(t.Context(), "id-failure")
+	if err != nil || item.Status != store.StatusFailure || item.Host != "web3" {
+		t.Errorf("the item of the migrated copy was not imported: %+v; %v", item, err)
+	}
+
+	if !bytes.Equal(before, read()) {
+		t.Errorf("the source database was changed")
+	}
+
+	if _, _, err := store.OpenStoreSource(t.Context(), filepath.Join(t.TempDir(), "missing.db")); err == nil {
+		t.Errorf("expected an error for a missing source")
+	}
+}
@@ -0,0 +1,375 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// the schema of the database is changed by numbered migrations. Every migration
+// is executed in a transaction and recorded in the SCHEMA_VERSION table, so each
+// migration is applied exactly once. New migrations are appended to the list,
+// existing migrations must never be changed.
+//
+// The migrations work on frozen copies of the entities (e.g. opResultV1) instead
+// of the current entities, otherwise a later change of an entity would change
+// the outcome of an earlier migration.
+//
+// The frozen entities use sqlite column types (e.g. nvarchar) which PostgreSQL does
+// not know, the tables and columns are therefore created by explicit statements
+// for PostgreSQL. Raw statements quote the table names, PostgreSQL would fold
+// unquoted names to lower case.
+
+// Migration is a numbered change of the database schema or data
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(tx *gorm.DB) error
+}
+
+// SchemaVersionEntity records an applied migration
+type SchemaVersionEntity struct {
+	Version     int       `gorm:"primary_key;COLUMN:version;autoIncrement:false"`
+	Description string    `gorm:"COLUMN:description;TYPE:nvarchar(255);"`
+	Applied     time.Time `gorm:"COLUMN:applied;NOT NULL"`
+}
+
+// TableName specifies the name of the Table used
+func (SchemaVersionEntity) TableName() string {
+	return "SCHEMA_VERSION"
+}
+
+// MigrationStatus describes a migration and whether it was applied
+type MigrationStatus struct {
+	Version     int
+	Description string
+	// Applied is nil if the migration is still pending
+	Applied *time.Time
+}
+
+// Migrations returns the list of all migrations ordered by version
+func Migrations() []Migration {
+	return migrations
+}
+
+// LatestSchemaVersion is the version of the last available migration
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// MigrationState returns all migrations with the information if they were applied
+func MigrationState(con Connection) ([]MigrationStatus, error) {
+	applied, err := appliedMigrations(con.R())
+	if err != nil {
+		return nil, err
+	}
+
+	state := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		status := MigrationStatus{
+			Version:     m.Version,
+			Description: m.Description,
+		}
+		if v, ok := applied[m.Version]; ok {
+			status.Applied = &v.Applied
+		}
+		state = append(state, status)
+	}
+	return state, nil
+}
+
+// SchemaVersion returns the highest applied migration, 0 if no migration was applied
+func SchemaVersion(con Connection) (int, error) {
+	applied, err := appliedMigrations(con.R())
+	if err != nil {
+		return 0, err
+	}
+	var version int
+	for v := range applied {
+		version = max(version, v)
+	}
+	return version, nil
+}
+
+// Migrate applies all pending migrations and returns the number of applied migrations
+func Migrate(con Connection) (int, error) {
+	if err := createSchemaVersionTable(con.W()); err != nil {
+		return 0, fmt.Errorf("cannot create the schema version table; %v", err)
+	}
+
+	var count int
+	for _, m := range migrations {
+		var done bool
+		err := con.Begin(context.Background(), func(c Connection) error {
+			// check within the transaction, another process might have applied the migration meanwhile
+			if isPostgres(c.W()) {
+				// serialize concurrent migrations, sqlite already locks the database for writing
+				if err := c.W().Exec("SELECT pg_advisory_xact_lock(?)", migrationLockID).Error; err != nil {
+					return err
+				}
+			}
+			var exists int64
+			if err := c.W().Model(&SchemaVersionEntity{}).Where("version = ?", m.Version).Count(&exists).Error; err != nil {
+				return err
+			}
+			if exists > 0 {
+				return nil
+			}
+			if err := m.Up(c.W()); err != nil {
+				return err
+			}
+			done = true
+			return c.W().Create(&SchemaVersionEntity{
+				Version:     m.Version,
+				Description: m.Description,
+				Applied:     time.Now(),
+			}).Error
+		})
+		if err != nil {
+			return count, fmt.Errorf("migration %d '%s' failed; %v", m.Version, m.Description, err)
+		}
+		if done {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// migrationLockID identifies the advisory lock of the migrations in PostgreSQL
+const migrationLockID = 0x63726f6e // "cron"
+
+func createSchemaVersionTable(db *gorm.DB) error {
+	if db.Migrator().HasTable(&SchemaVersionEntity{}) {
+		return nil
+	}
+	if isPostgres(db) {
+		return db.Exec(`CREATE TABLE IF NOT EXISTS "SCHEMA_VERSION" (
+			version bigint PRIMARY KEY,
+			description varchar(255),
+			applied timestamptz NOT NULL)`).Error
+	}
+	return db.Migrator().CreateTable(&SchemaVersionEntity{})
+}
+
+func appliedMigrations(db *gorm.DB) (map[int]SchemaVersionEntity, error) {
+	applied := make(map[int]SchemaVersionEntity)
+	if !db.Migrator().HasTable(&SchemaVersionEntity{}) {
+		return applied, nil
+	}
+
+	var versions []SchemaVersionEntity
+	if err := db.Order("version ASC").Find(&versions).Error; err != nil {
+		return nil, fmt.Errorf("cannot read the schema version; %v", err)
+	}
+	for _, v := range versions {
+		applied[v.Version] = v
+	}
+	return applied, nil
+}
+
+// addColumns adds the given fields of the model if the columns do not exist yet.
+// Databases created by earlier versions without migrations might already have them.
+func addColumns(tx *gorm.DB, model any, fields ...string) error {
+	for _, field := range fields {
+		if tx.Migrator().HasColumn(model, field) {
+			continue
+		}
+		if err := tx.Migrator().AddColumn(model, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// execAll executes the given statements
+func execAll(tx *gorm.DB, stmts ...string) error {
+	for _, stmt := range stmts {
+		if err := tx.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// Migrations
+// --------------------------------------------------------------------------
+
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "create the OPRESULTS table",
+		Up: func(tx *gorm.DB) error {
+			if isPostgres(tx) {
+				return execAll(tx, `CREATE TABLE IF NOT EXISTS "OPRESULTS" (
+					id varchar(36) PRIMARY KEY,
+					application varchar(255),
+					success boolean NOT NULL DEFAULT false,
+					output text,
+					created timestamptz NOT NULL)`)
+			}
+			// databases created before the migrations were introduced already have the table
+			if tx.Migrator().HasTable(&opResultV1{}) {
+				return nil
+			}
+			return tx.Migrator().CreateTable(&opResultV1{})
+		},
+	},
+	{
+		Version:     2,
+		Description: "add the status of an execution",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumns(tx, &opResultV2{}, "Status"); err != nil {
+				return err
+			}
+			// derive the status of existing executions from the success flag
+			return tx.Exec(`UPDATE "OPRESULTS" SET status = CASE WHEN success THEN 'success' ELSE 'failure' END
+				WHERE status IS NULL OR status = ''`).Error
+		},
+	},
+	{
+		Version:     3,
+		Description: "add the attempts of retried executions",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumns(tx, &opResultV3{}, "AttemptCount"); err != nil {
+				return err
+			}
+			if isPostgres(tx) {
+				return execAll(tx, `CREATE TABLE IF NOT EXISTS "OPATTEMPTS" (
+					id varchar(36) PRIMARY KEY,
+					run_id varchar(36) NOT NULL,
+					attempt bigint NOT NULL,
+					status varchar(32),
+					exit_code bigint NOT NULL,
+					output text,
+					created timestamptz NOT NULL)`,
+					`CREATE INDEX IF NOT EXISTS "idx_OPATTEMPTS_run_id" ON "OPATTEMPTS" (run_id)`)
+			}
+			if tx.Migrator().HasTable(&opAttemptV3{}) {
+				return nil
+			}
+			return tx.Migrator().CreateTable(&opAttemptV3{})
+		},
+	},
+	{
+		Version:     4,
+		Description: "add the trigger of an execution",
+		Up: func(tx *gorm.DB) error {
+			if isPostgres(tx) {
+				return execAll(tx, `ALTER TABLE "OPRESULTS"
+					ADD COLUMN IF NOT EXISTS run_trigger varchar(32),
+					ADD COLUMN IF NOT EXISTS triggered_by varchar(255)`)
+			}
+			return addColumns(tx, &opResultV4{}, "Trigger", "TriggeredBy")
+		},
+	},
+	{
+		Version:     5,
+		Description: "add indexes for the paged queries and the available applications",
+		Up: func(tx *gorm.DB) error {
+			return execAll(tx,
+				`CREATE INDEX IF NOT EXISTS "idx_OPRESULTS_created" ON "OPRESULTS" (created, id)`,
+				`CREATE INDEX IF NOT EXISTS "idx_OPRESULTS_application_created" ON "OPRESULTS" (application, created, id)`)
+		},
+	},
+	{
+		Version:     6,
+		Description: "add the origin host of an execution",
+		Up: func(tx *gorm.DB) error {
+			if isPostgres(tx) {
+				return execAll(tx, `ALTER TABLE "OPRESULTS" ADD COLUMN IF NOT EXISTS origin_host varchar(255)`)
+			}
+			return addColumns(tx, &opResultV6{}, "Host")
+		},
+	},
+	{
+		Version:     7,
+		Description: "add the API tokens",
+		Up: func(tx *gorm.DB) error {
+			if isPostgres(tx) {
+				return execAll(tx, `CREATE TABLE IF NOT EXISTS "APITOKENS" (
+					id varchar(36) PRIMARY KEY,
+					name varchar(255) NOT NULL,
+					token_hash varchar(64) NOT NULL,
+					scopes text,
+					created timestamptz NOT NULL,
+					created_by varchar(255),
+					expires timestamptz,
+					last_used timestamptz)`,
+					`CREATE UNIQUE INDEX IF NOT EXISTS "idx_APITOKENS_token_hash" ON "APITOKENS" (token_hash)`)
+			}
+			if tx.Migrator().HasTable(&apiTokenV7{}) {
+				return nil
+			}
+			return tx.Migrator().CreateTable(&apiTokenV7{})
+		},
+	},
+}
+
+// frozen entities used by the migrations
+
+type opResultV1 struct {
+	ID      string    `gorm:"primary_key;TYPE:varchar(36);COLUMN:id"`
+	App     string    `gorm:"COLUMN:application;TYPE:nvarchar(255);"`
+	Success bool      `gorm:"COLUMN:success;TYPE:bool;DEFAULT:FALSE;NOT NULL"`
+	Output  string    `gorm:"COLUMN:output;TYPE:nvarchar(255);"`
+	Created time.Time `gorm:"COLUMN:created;NOT NULL"`
+}
+
+func (opResultV1) TableName() string { return "OPRESULTS" }
+
+type opResultV2 struct {
+	opResultV1
+	Status string `gorm:"COLUMN:status;TYPE:varchar(32);"`
+}
+
+func (opResultV2) TableName() string { return "OPRESULTS" }
+
+type opResultV3 struct {
+	opResultV2
+	AttemptCount int `gorm:"COLUMN:attempt_count;DEFAULT:1;NOT NULL"`
+}
+
+func (opResultV3) TableName() string { return "OPRESULTS" }
+
+type opAttemptV3 struct {
+	ID       string    `gorm:"primary_key;TYPE:varchar(36);COLUMN:id"`
+	RunID    string    `gorm:"COLUMN:run_id;TYPE:varchar(36);NOT NULL;index"`
+	Attempt  int       `gorm:"COLUMN:attempt;NOT NULL"`
+	Status   string    `gorm:"COLUMN:status;TYPE:varchar(32);"`
+	ExitCode int       `gorm:"COLUMN:exit_code;NOT NULL"`
+	Output   string    `gorm:"COLUMN:output;TYPE:nvarchar(255);"`
+	Created  time.Time `gorm:"COLUMN:created;NOT NULL"`
+}
+
+func (opAttemptV3) TableName() string { return "OPATTEMPTS" }
+
+type opResultV4 struct {
+	opResultV3
+	Trigger     string `gorm:"COLUMN:run_trigger;TYPE:varchar(32);"`
+	TriggeredBy string `gorm:"COLUMN:triggered_by;TYPE:nvarchar(255);"`
+}
+
+func (opResultV4) TableName() string { return "OPRESULTS" }
+
+type opResultV6 struct {
+	opResultV4
+	Host string `gorm:"COLUMN:origin_host;TYPE:nvarchar(255);"`
+}
+
+func (opResultV6) TableName() string { return "OPRESULTS" }
+
+type apiTokenV7 struct {
+	ID        string     `gorm:"primary_key;TYPE:varchar(36);COLUMN:id"`
+	Name      string     `gorm:"COLUMN:name;TYPE:nvarchar(255);NOT NULL"`
+	Hash      string     `gorm:"COLUMN:token_hash;TYPE:varchar(64);NOT NULL;uniqueIndex"`
+	Scopes    string     `gorm:"COLUMN:scopes;TYPE:nvarchar(1024);"`
+	Created   time.Time  `gorm:"COLUMN:created;NOT NULL"`
+	CreatedBy string     `gorm:"COLUMN:created_by;TYPE:nvarchar(255);"`
+	Expires   *time.Time `gorm:"COLUMN:expires"`
+	LastUsed  *time.Time `gorm:"COLUMN:last_used"`
+}
+
+func (apiTokenV7) TableName() string { return "APITOKENS" }
@@ -0,0 +1,180 @@
+package store_test
+
+import (
+	"cronlogger/store"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// the schema created by the AutoMigrate of the versions before the migrations were introduced
+const baselineSchema = "CREATE TABLE `OPRESULTS` (`id` varchar(36),`application` nvarchar(255),`success` numeric NOT NULL DEFAULT false,`output` nvarchar(255),`created` datetime NOT NULL,PRIMARY KEY (`id`))"
+
+func createDbFile(t testing.TB) string {
+	path := filepath.Join(t.TempDir(), "cronlog-store.db")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatalf("cannot create database file: %v", err)
+	}
+	return path
+}
+
+// getCon opens the connection to an empty database of the given backend
+func getCon(t *testing.T, backend string) store.Connection {
+	var (
+		con store.Connection
+		db  io.Closer
+		err error
+	)
+	switch backend {
+	case "postgres":
+		con, db, err = store.CreatePostgresConFromDsn(createPostgresDb(t))
+	default:
+		con, db, err = store.CreateSqliteConFromDbPath(createDbFile(t))
+	}
+	if err != nil {
+		t.Fatalf("cannot create database connection: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return con
+}
+
+func Test_Migrations_Ordered(t *testing.T) {
+	last := 0
+	for _, m := range store.Migrations() {
+		if m.Version != last+1 {
+			t.Errorf("expected migration version %d, got %d", last+1, m.Version)
+		}
+		if m.Description == "" || m.Up == nil {
+			t.Errorf("migration %d is incomplete", m.Version)
+		}
+		last = m.Version
+	}
+	if store.LatestSchemaVersion() != last {
+		t.Errorf("expected latest version %d, got %d", last, store.LatestSchemaVersion())
+	}
+}
+
+func Test_Migrate_NewDatabase(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend, func(t *testing.T) {
+			con := getCon(t, backend)
+
+			version, err := store.SchemaVersion(con)
+			if err != nil {
+				t.Fatalf("cannot get schema version: %v", err)
+			}
+			if version != 0 {
+				t.Errorf("expected version 0 for a new database, got %d", version)
+			}
+
+			state, err := store.MigrationState(con)
+			if err != nil {
+				t.Fatalf("cannot get migration state: %v", err)
+			}
+			for _, m := range state {
+				if m.Applied != nil {
+					t.Errorf("migration %d should be pending", m.Version)
+				}
+			}
+
+			applied, err := store.Migrate(con)
+			if err != nil {
+				t.Fatalf("cannot migrate: %v", err)
+			}
+			if applied != len(store.Migrations()) {
+				t.Errorf("expected %d applied migrations, got %d", len(store.Migrations()), applied)
+			}
+
+			// a second run does not apply anything
+			applied, err = store.Migrate(con)
+			if err != nil {
+				t.Fatalf("cannot migrate: %v", err)
+			}
+			if applied != 0 {
+				t.Errorf("expected no applied migrations, got %d", applied)
+			}
+
+			state, err = store.MigrationState(con)
+			if err != nil {
+				t.Fatalf("cannot get migration state: %v", err)
+			}
+			for _, m := range state {
+				if m.Applied == nil {
+					t.Errorf("migration %d should be applied", m.Version)
+				}
+			}
+
+			version, err = store.SchemaVersion(con)
+			if err != nil {
+				t.Fatalf("cannot get schema version: %v", err)
+			}
+			if version != store.LatestSchemaVersion() {
+				t.Errorf("expected version %d, got %d", store.LatestSchemaVersion(), version)
+			}
+		})
+	}
+}
+
+func Test_Migrate_BaselineDatabase(t *testing.T) {
+	path := createDbFile(t)
+
+	// create a database with the baseline schema and some executions
+	con, db, err := store.CreateSqliteConFromDbPath(path)
+	if err != nil {
+		t.Fatalf("cannot create database connection: %v", err)
+	}
+	if err := con.Write.Exec(baselineSchema).Error; err != nil {
+		t.Fatalf("cannot create baseline schema: %v", err)
+	}
+	created := time.Now().Add(-time.Hour)
+	for id, success := range map[string]bool{"id-success": true, "id-failure": false} {
+		err := con.Write.Exec("INSERT INTO OPRESULTS (id, application, success, output, created) VALUES (?, ?, ?, ?, ?)",
+			id, "baseline", success, "output", created).Error
+		if err != nil {
+			t.Fatalf("cannot insert baseline data: %v", err)
+		}
+	}
+	db.Close()
+
+	// open the store, the pending migrations are applied
+	s, db, err := store.CreateSqliteStoreFromDbPath(path, store.Options{})
+	if err != nil {
+		t.Fatalf("cannot create store: %v", err)
+	}
+	defer db.Close()
+
+	item, err := s.GetById(t.Context(), "id-success")
+	if err != nil {
+		t.Fatalf("could not get item by ID; %v", err)
+	}
+	if item.Status != store.StatusSuccess || item.AttemptCount != 1 || item.Output != "output" {
+		t.Errorf("the existing item was not migrated: %+v", item)
+	}
+	item, err = s.GetById(t.Context(), "id-failure")
+	if err != nil {
+		t.Fatalf("could not get item by ID; %v", err)
+	}
+	if item.Status != store.StatusFailure {
+		t.Errorf("expected status %s, got %s", store.StatusFailure, item.Status)
+	}
+
+	// the new schema is usable
+	_, err = s.Create(t.Context(), store.OpResultEntity{
+		App:      "baseline",
+		Status:   store.StatusTimeout,
+		Trigger:  store.TriggerSchedule,
+		Attempts: []store.OpAttemptEntity{{Attempt: 1, Status: store.StatusTimeout, ExitCode: -1}},
+	})
+	if err != nil {
+		t.Errorf("could not create an item; %v", err)
+	}
+	res, err := s.GetPagedItems(t.Context(), 10, 0, nil, nil, "baseline")
+	if err != nil {
+		t.Fatalf("could not get paged items; %v", err)
+	}
+	if res.TotalCount != 3 {
+		t.Errorf("expected 3 total items, got %d", res.TotalCount)
+	}
+}
@@ -0,0 +1,97 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// the queries of the store can be restricted to some applications, e.g. to the applications
+// a user may read. The restriction is part of the context and applies to all queries which
+// read executions, the executions of other applications are neither listed nor found by id.
+
+type appScopeKey struct{}
+
+// AllApps is the pattern which matches all applications
+const AllApps = "*"
+
+// WithApps restricts the queries of the context to the applications matching the patterns.
+// A pattern is either the name of an application or contains the wildcards * and ?,
+// without patterns no application is visible.
+func WithApps(ctx context.Context, patterns []string) context.Context {
+	return context.WithValue(ctx, appScopeKey{}, slices.Clone(patterns))
+}
+
+// AppsOf returns the patterns of the restriction, false is returned if the context is not restricted
+func AppsOf(ctx context.Context) ([]string, bool) {
+	patterns, ok := ctx.Value(appScopeKey{}).([]string)
+	if !ok || slices.Contains(patterns, AllApps) {
+		return nil, false
+	}
+	return patterns, true
+}
+
+// scopeApps creates the where clause restricting the query to the applications of the context
+func scopeApps(ctx context.Context, db *gorm.DB) (string, []any) {
+	patterns, ok := AppsOf(ctx)
+	if !ok {
+		return "", nil
+	}
+	if len(patterns) == 0 {
+		return "1 = 0", nil
+	}
+
+	// LIKE ignores the case of sqlite, GLOB is used there instead
+	postgres := isPostgres(db)
+	conditions := make([]string, 0, len(patterns))
+	params := make([]any, 0, len(patterns))
+	for _, pattern := range patterns {
+		switch {
+		case !strings.ContainsAny(pattern, "*?"):
+			conditions = append(conditions, "application = ?")
+			params = append(params, pattern)
+		case postgres:
+			conditions = append(conditions, `application LIKE ? ESCAPE '\'`)
+			params = append(params, likePattern(pattern))
+		default:
+			conditions = append(conditions, "application GLOB ?")
+			params = append(params, globPattern(pattern))
+		}
+	}
+	return "(" + strings.Join(conditions, " OR ") + ")", params
+}
+
+func likePattern(pattern string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`, "*", "%", "?", "_").Replace(pattern)
+}
+
+// globPattern escapes the character classes of sqlite, only * and ? remain wildcards
+func globPattern(pattern string) string {
+	return strings.ReplaceAll(pattern, "[", "[[]")
+}
+
+// MatchApp reports whether the application matches the pattern of WithApps
+func MatchApp(pattern, app string) bool {
+	return matchRunes([]rune(pattern), []rune(app))
+}
+
+func matchRunes(pattern, app []rune) bool {
+	if len(pattern) == 0 {
+		return len(app) == 0
+	}
+	switch pattern[0] {
+	case '*':
+		for i := 0; i <= len(app); i++ {
+			if matchRunes(pattern[1:], app[i:]) {
+				return true
+			}
+		}
+		return false
+	case '?':
+		return len(app) > 0 && matchRunes(pattern[1:], app[1:])
+	default:
+		return len(app) > 0 && app[0] == pattern[0] && matchRunes(pattern[1:], app[1:])
+	}
+}
@@ -0,0 +1,107 @@
+package store_test
+
+import (
+	"cronlogger/store"
+	"slices"
+	"testing"
+	"time"
+)
+
+func Test_App_Scope(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s store.OpResultStore) {
+		ids := map[string]string{}
+		for _, app := range []string{"acme-tls", "rclone-photos", "rclone-docs", "Rclone-music", "rclone_x", "backup[1]"} {
+			item, err := s.Create(t.Context(), store.OpResultEntity{App: app, Success: true, Output: app})
+			if err != nil {
+				t.Fatalf("could not create item; %v", err)
+			}
+			ids[app] = item.ID
+		}
+
+		for _, tc := range []struct {
+			patterns []string
+			want     []string
+		}{
+			{[]string{"rclone-*"}, []string{"rclone-docs", "rclone-photos"}},
+			{[]string{"rclone?x"}, []string{"rclone_x"}},
+			{[]string{"acme-tls", "backup[1]"}, []string{"acme-tls", "backup[1]"}},
+			{[]string{"rclone%"}, []string{}},
+			{[]string{}, []string{}},
+			{[]string{"*"}, []string{"Rclone-music", "acme-tls", "backup[1]", "rclone-docs", "rclone-photos", "rclone_x"}},
+		} {
+			ctx := store.WithApps(t.Context(), tc.patterns)
+			apps, err := s.GetAvailApps(ctx)
+			if err != nil {
+				t.Fatalf("could not get the apps; %v", err)
+			}
+			// the order of the apps depends on the collation of the database
+			slices.Sort(apps)
+			if !slices.Equal(apps, tc.want) {
+				t.Errorf("%v: expected the apps %v, got %v", tc.patterns, tc.want, apps)
+			}
+
+			page, err := s.GetPagedItems(ctx, 10, 0, nil, nil, "")
+			if err != nil {
+				t.Fatalf("could not get the paged items; %v", err)
+			}
+			if page.TotalCount != int64(len(tc.want)) {
+				t.Errorf("%v: expected %d paged items, got %d", tc.patterns, len(tc.want), page.TotalCount)
+			}
+
+			var exported []string
+			until := time.Now().Add(time.Hour)
+			err = s.ForEachItem(ctx, nil, &until, "", func(item store.OpResultEntity) error {
+				exported = append(exported, item.App)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("could not iterate the items; %v", err)
+			}
+			slices.Sort(exported)
+			if !slices.Equal(exported, tc.want) {
+				t.Errorf("%v: expected the exported apps %v, got %v", tc.patterns, tc.want, exported)
+			}
+		}
+
+		// the executions of other applications are not found
+		ctx := store.WithApps(t.Context(), []string{"rclone-*"})
+		if _, err := s.GetById(ctx, ids["acme-tls"]); err == nil {
+			t.Errorf("expected the execution of another application not to be found")
+		}
+		if item, err := s.GetById(ctx, ids["rclone-docs"]); err != nil || item.App != "rclone-docs" {
+			t.Errorf("expected the execution to be found; %v", err)
+		}
+		// an application filter does not extend the restriction
+		page, err := s.GetCursorItems(ctx, 10, "", nil, nil, "acme-tls")
+		if err != nil || len(page.Items) != 0 {
+			t.Errorf("expected no items of another application, got %d; %v", len(page.Items), err)
+		}
+		all, err := s.GetAll(ctx)
+		if err != nil || len(all) != 2 {
+			t.Errorf("expected 2 items, got %d; %v", len(all), err)
+		}
+	})
+}
+
+func Test_MatchApp(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, app string
+		want         bool
+	}{
+		{"*", "acme-tls", true},
+		{"acme-tls", "acme-tls", true},
+		{"acme-tls", "acme-tls2", false},
+		{"rclone-*", "rclone-photos", true},
+		{"rclone-*", "Rclone-photos", false},
+		{"*-tls", "acme-tls", true},
+		{"r?lone", "rclone", true},
+		{"r?lone", "rlone", false},
+		{"?", "ä", true},
+		{"backup[1]", "backup[1]", true},
+		{"backup[1]", "backup1", false},
+	} {
+		if got := store.MatchApp(tc.pattern, tc.app); got != tc.want {
+			t.Errorf("MatchApp(%q, %q) = %v, expected %v", tc.pattern, tc.app, got, tc.want)
+		}
+	}
+}
@@ -1,18 +1,21 @@
 package store
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"net/url"
 	"os"
 	"runtime"
 	"strings"
 
+	"github.com/google/uuid"
 	"github.com/ncruces/go-sqlite3"
 	_ "github.com/ncruces/go-sqlite3/embed"
 	"github.com/ncruces/go-sqlite3/ext/unicode"
 	"github.com/ncruces/go-sqlite3/gormlite"
+	_ "github.com/ncruces/go-sqlite3/vfs/memdb"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
 )
 
 // SqliteParam is used to provide additional parameters for the sqlite connection
@@ -21,11 +24,12 @@ type SqliteParam struct {
 	Val string
 }
 
-// MustCreateSqliteConn initializes a new connection to a sqlite database.
+// MustCreateSqliteConn initializes a new connection pool to a sqlite database.
+// The params are added to the connection URL, e.g. mode=ro or _txlock=immediate.
 // The func validates the existence of the sqlite db file-path.
 // If the file does not exist, the function will panic.
 // If the connection cannot be established, the function will panic
-func MustCreateSqliteConn(connURL string) *sql.DB {
+func MustCreateSqliteConn(connURL string, params ...SqliteParam) *sql.DB {
 	if connURL == "" {
 		panic("empty connection URL supplied")
 	}
@@ -34,7 +38,7 @@ func MustCreateSqliteConn(connURL string) *sql.DB {
 	// sqlite has "special" connection strings which are not files on the local filesystem
 	// therefor the check if the file exists is not possible.
 	// e.g. file::memory:
-	if !strings.Contains(baseDSN, ":memory:") {
+	if !strings.Contains(baseDSN, ":memory:") && !hasParam(params, "vfs", "memdb") {
 		_, err := os.Stat(connURL)
 		if os.IsNotExist(err) {
 			panic(fmt.Sprintf("the defined sqlite3 database file is not available '%s': %v", baseDSN, err))
@@ -44,51 +48,77 @@ func MustCreateSqliteConn(connURL string) *sql.DB {
 	// register the unicode extension
 	sqlite3.AutoExtension(unicode.Register)
 	// use the configured sqlite3 driver available
-	db, err := sql.Open("sqlite3", baseDSN)
+	db, err := sql.Open("sqlite3", sqliteDsn(baseDSN, params))
 	if err != nil {
 		panic(fmt.Sprintf("unable to create a sqlite3 connection '%s': %v", baseDSN, err))
 	}
 	return db
 }
 
+// MustCreateSqlitePools creates the two connection pools used by CreateGormSqliteCon:
+// a read-only pool and a pool for the single writer which starts its transactions
+// with BEGIN IMMEDIATE. A write transaction therefore takes the write lock up-front
+// instead of failing with SQLITE_BUSY when a read is upgraded to a write.
+// The pragmas are applied to every new connection of the pools.
+// An in-memory database (":memory:") is shared by the pools via the memdb VFS.
+func MustCreateSqlitePools(connURL string) (read, write *sql.DB) {
+	baseDSN := stripParamsDsn(connURL)
+	writeParams := []SqliteParam{{Key: "_txlock", Val: "immediate"}}
+	readParams := []SqliteParam{{Key: "mode", Val: "ro"}}
+	if baseDSN == ":memory:" {
+		// every connection to :memory: would open a separate database
+		baseDSN = "/" + uuid.New().String() + ".db"
+		writeParams = append(writeParams, SqliteParam{Key: "vfs", Val: "memdb"})
+		readParams = append(readParams, SqliteParam{Key: "vfs", Val: "memdb"})
+	} else {
+		// the journal mode is a property of the database file, it is set by the writer
+		writeParams = append(writeParams, pragmaParam("journal_mode", "wal"))
+	}
+	for _, pragma := range connectionPragmas {
+		readParams = append(readParams, pragmaParam(pragma.Key, pragma.Val))
+		if pragma.Key == "busy_timeout" {
+			pragma.Val = writeBusyTimeout
+		}
+		writeParams = append(writeParams, pragmaParam(pragma.Key, pragma.Val))
+	}
+
+	write = MustCreateSqliteConn(baseDSN, writeParams...)
+	read = MustCreateSqliteConn(baseDSN, readParams...)
+	return read, write
+}
+
 // CreateGormSqliteCon uses best practices for sqlite and creates two connections
 // One optimized for reading and the other optimized for writing
 // found the information here: https://kerkour.com/sqlite-for-servers
-func CreateGormSqliteCon(db *sql.DB) (con Connection, err error) {
-	var (
-		read  *gorm.DB
-		write *gorm.DB
-	)
-	if db == nil {
+// The read pool should be opened read-only and the write pool with _txlock=immediate,
+// see MustCreateSqlitePools.
+func CreateGormSqliteCon(readDB, writeDB *sql.DB) (con Connection, err error) {
+	if readDB == nil || writeDB == nil {
 		return Connection{}, fmt.Errorf("invalid db connection supplied")
 	}
 
-	gormConf := &gorm.Config{
-		Logger:                 logger.Default.LogMode(logger.Silent),
-		SkipDefaultTransaction: true,
+	// the writer is opened first, it creates the database and switches the journal mode
+	writeDB.SetMaxOpenConns(1) // only use one active connection for writing
+	// keep the connection, an in-memory database is discarded with its last connection
+	writeDB.SetMaxIdleConns(1)
+	writeDB.SetConnMaxIdleTime(0)
+	if err := VerifyPragmas(writeDB); err != nil {
+		return Connection{}, fmt.Errorf("cannot initialize write database connection: %w", err)
 	}
+	// the read connections are opened on demand. A connection opened before the migrations
+	// of the writer would keep the outdated schema for its first query.
+	readDB.SetMaxOpenConns(max(4, runtime.NumCPU())) // read in parallel with open connection per core
 
-	read, err = gorm.Open(gormlite.OpenDB(db), gormConf)
+	readConf := defaultGormConfig()
+	readConf.DisableAutomaticPing = true
+	read, err := gorm.Open(gormlite.OpenDB(readDB), readConf)
 	if err != nil {
 		return Connection{}, fmt.Errorf("cannot create read database connection: %w", err)
 	}
-	readDB, err := read.DB()
-	if err != nil {
-		return Connection{}, fmt.Errorf("cannot access underlying read database connection: %w", err)
-	}
-	SetDefaultPragmas(readDB)
-	readDB.SetMaxOpenConns(max(4, runtime.NumCPU())) // read in parallel with open connection per core
-
-	write, err = gorm.Open(gormlite.OpenDB(db), gormConf)
+	write, err := gorm.Open(gormlite.OpenDB(writeDB), defaultGormConfig())
 	if err != nil {
 		return Connection{}, fmt.Errorf("cannot create write database connection: %w", err)
 	}
-	writeDB, err := read.DB()
-	if err != nil {
-		return Connection{}, fmt.Errorf("cannot access underlying write database connection: %w", err)
-	}
-	SetDefaultPragmas(writeDB)
-	writeDB.SetMaxOpenConns(1) // only use one active connection for writing
 
 	return Connection{
 		Read:  read,
@@ -107,41 +137,75 @@ func stripParamsDsn(connStr string) string {
 	return basePath
 }
 
-// SetDefaultPragmas defines some sqlite pragmas for good performance and litestream compatibility
+// connectionPragmas are applied to every connection of the pools for good performance and litestream compatibility
 // https://highperformancesqlite.com/articles/sqlite-recommended-pragmas
 // https://litestream.io/tips/
-func SetDefaultPragmas(db *sql.DB) error {
-	var (
-		stmt string
-		val  string
-	)
-	defaultPragmas := map[string]string{
-		"journal_mode": "wal",   // https://www.sqlite.org/pragma.html#pragma_journal_mode
-		"busy_timeout": "5000",  // https://www.sqlite.org/pragma.html#pragma_busy_timeout
-		"synchronous":  "1",     // NORMAL --> https://www.sqlite.org/pragma.html#pragma_synchronous
-		"cache_size":   "10000", // 10000 pages = 40MB --> https://www.sqlite.org/pragma.html#pragma_cache_size
-		"foreign_keys": "1",     // 1(bool) --> https://www.sqlite.org/pragma.html#pragma_foreign_keys
-	}
-
-	// set the pragmas
-	for k := range defaultPragmas {
-		stmt = fmt.Sprintf("pragma %s = %s", k, defaultPragmas[k])
-		if _, err := db.Exec(stmt); err != nil {
-			return err
-		}
-	}
+var connectionPragmas = []SqliteParam{
+	{Key: "busy_timeout", Val: "5000"}, // https://www.sqlite.org/pragma.html#pragma_busy_timeout
+	{Key: "synchronous", Val: "1"},     // NORMAL --> https://www.sqlite.org/pragma.html#pragma_synchronous
+	{Key: "cache_size", Val: "10000"},  // 10000 pages = 40MB --> https://www.sqlite.org/pragma.html#pragma_cache_size
+	{Key: "foreign_keys", Val: "1"},    // 1(bool) --> https://www.sqlite.org/pragma.html#pragma_foreign_keys
+}
+
+// writeBusyTimeout replaces the busy_timeout of the write pool. The writers of the server and of
+// the logger processes queue for the write lock of the file, the busy handler of sqlite does not
+// serve them in order. A writer therefore waits longer than a reader before it gives up.
+const writeBusyTimeout = "30000"
+
+// pragmaParam creates the DSN parameter of the driver which executes the pragma on every new connection
+func pragmaParam(name, val string) SqliteParam {
+	return SqliteParam{Key: "_pragma", Val: fmt.Sprintf("%s(%s)", name, val)}
+}
 
-	// validate the pragmas
-	for k := range defaultPragmas {
-		row := db.QueryRow(fmt.Sprintf("pragma %s", k))
-		err := row.Scan(&val)
-		if err != nil {
+// VerifyPragmas validates that the pragmas of the DSN are active on a connection of the pool
+func VerifyPragmas(db *sql.DB) error {
+	return VerifyPragmasContext(context.Background(), db)
+}
+
+// VerifyPragmasContext is VerifyPragmas with a context which cancels the queries, e.g. of a health check
+func VerifyPragmasContext(ctx context.Context, db *sql.DB) error {
+	var val string
+	for _, pragma := range connectionPragmas {
+		if err := db.QueryRowContext(ctx, fmt.Sprintf("pragma %s", pragma.Key)).Scan(&val); err != nil {
 			return err
 		}
-		if val != defaultPragmas[k] {
-			return fmt.Errorf("could not set pragma %s to %s", k, defaultPragmas[k])
+		// the write pool waits longer for the lock
+		if pragma.Key == "busy_timeout" && (val == pragma.Val || val == writeBusyTimeout) {
+			continue
+		}
+		if val != pragma.Val {
+			return fmt.Errorf("the pragma %s is %s instead of %s", pragma.Key, val, pragma.Val)
 		}
 	}
 
+	// in-memory databases (memdb) do not support the WAL and use the memory journal
+	if err := db.QueryRowContext(ctx, "pragma journal_mode").Scan(&val); err != nil {
+		return err
+	}
+	if val != "wal" && val != "memory" {
+		return fmt.Errorf("the pragma journal_mode is %s instead of wal", val)
+	}
 	return nil
 }
+
+// sqliteDsn creates the URI filename of the database with the given params
+// https://www.sqlite.org/uri.html
+func sqliteDsn(path string, params []SqliteParam) string {
+	if len(params) == 0 {
+		return path
+	}
+	query := make(url.Values)
+	for _, p := range params {
+		query.Add(p.Key, p.Val)
+	}
+	return "file:" + (&url.URL{Path: path}).EscapedPath() + "?" + query.Encode()
+}
+
+func hasParam(params []SqliteParam, key, val string) bool {
+	for _, p := range params {
+		if p.Key == key && p.Val == val {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,163 @@
+package store_test
+
+import (
+	"cronlogger/store"
+	"database/sql"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func Test_Sqlite_ConnectionPools(t *testing.T) {
+	con, db, err := store.CreateSqliteConFromDbPath(createDbFile(t))
+	if err != nil {
+		t.Fatalf("cannot create database connection: %v", err)
+	}
+	defer db.Close()
+	if _, err := store.Migrate(con); err != nil {
+		t.Fatalf("cannot migrate: %v", err)
+	}
+
+	readDB, _ := con.Read.DB()
+	writeDB, _ := con.Write.DB()
+	if readDB == writeDB {
+		t.Fatalf("the read and the write connection use the same pool")
+	}
+	if n := writeDB.Stats().MaxOpenConnections; n != 1 {
+		t.Errorf("expected a single write connection, got %d", n)
+	}
+	if n := readDB.Stats().MaxOpenConnections; n != max(4, runtime.NumCPU()) {
+		t.Errorf("expected %d read connections, got %d", max(4, runtime.NumCPU()), n)
+	}
+
+	// the read pool is read-only
+	err = con.Read.Exec(`INSERT INTO "OPRESULTS" (id, application, success, output, created) VALUES ('id', 'app', 1, '', '2025-01-01')`).Error
+	if err == nil || !strings.Contains(err.Error(), "readonly") {
+		t.Errorf("expected a readonly error, got %v", err)
+	}
+
+	// the pragmas are applied to every connection of the pools
+	for _, pool := range []*sql.DB{readDB, writeDB} {
+		if err := store.VerifyPragmas(pool); err != nil {
+			t.Errorf("the pragmas are not applied: %v", err)
+		}
+	}
+	// the writer waits longer for the write lock held by another process
+	var timeout string
+	if err := writeDB.QueryRow("pragma busy_timeout").Scan(&timeout); err != nil || timeout != "30000" {
+		t.Errorf("expected the busy timeout of the writer, got %s; %v", timeout, err)
+	}
+}
+
+func Test_Sqlite_ConcurrentReadWrite(t *testing.T) {
+	path := createDbFile(t)
+	s, db, err := store.CreateSqliteStoreFromDbPath(path, store.Options{})
+	if err != nil {
+		t.Fatalf("cannot create store: %v", err)
+	}
+	defer db.Close()
+	// a second store on the same file acts like the logger process writing at the same time
+	other, otherDb, err := store.CreateSqliteStoreFromDbPath(path, store.Options{})
+	if err != nil {
+		t.Fatalf("cannot create store: %v", err)
+	}
+	defer otherDb.Close()
+
+	const (
+		writers        = 4
+		itemsPerWriter = 100
+		readers        = 8
+	)
+
+	var (
+		wg       sync.WaitGroup
+		readerWg sync.WaitGroup
+		done     = make(chan struct{})
+		reads    atomic.Int64
+		errs     = make(chan error, writers+readers)
+	)
+
+	for r := range readers {
+		readerWg.Add(1)
+		go func() {
+			defer readerWg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				var err error
+				switch r % 3 {
+				case 0:
+					_, err = s.GetCursorItems(t.Context(), 20, "", nil, nil, "")
+				case 1:
+					_, err = s.GetPagedItems(t.Context(), 20, 10, nil, nil, fmt.Sprintf("app_%d", r%writers))
+				case 2:
+					_, err = s.GetAvailApps(t.Context())
+				}
+				if err != nil {
+					errs <- fmt.Errorf("reader %d: %v", r, err)
+					return
+				}
+				reads.Add(1)
+			}
+		}()
+	}
+
+	for w := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			writer := s
+			if w%2 == 1 {
+				writer = other
+			}
+			for i := range itemsPerWriter {
+				item, err := writer.Create(t.Context(), store.OpResultEntity{
+					App:    fmt.Sprintf("app_%d", w),
+					Status: store.StatusRunning,
+					Output: fmt.Sprintf("%d", i),
+				})
+				if err == nil {
+					item.Status = store.StatusSuccess
+					_, err = writer.Update(t.Context(), item)
+				}
+				if err != nil {
+					errs <- fmt.Errorf("writer %d: %v", w, err)
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(done)
+	readerWg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("concurrent access failed: %v", err)
+	}
+	if reads.Load() == 0 {
+		t.Errorf("no reads were executed during the writes")
+	}
+
+	res, err := s.GetPagedItems(t.Context(), 1, 0, nil, nil, "")
+	if err != nil {
+		t.Fatalf("could not get paged items; %v", err)
+	}
+	if res.TotalCount != writers*itemsPerWriter {
+		t.Errorf("expected %d items, got %d", writers*itemsPerWriter, res.TotalCount)
+	}
+	apps, err := s.GetAvailApps(t.Context())
+	if err != nil {
+		t.Fatalf("could not get the available apps; %v", err)
+	}
+	if len(apps) != writers {
+		t.Errorf("expected %d apps, got %d", writers, len(apps))
+	}
+}
@@ -0,0 +1,89 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AppStats summarizes the executions of an application, e.g. for the metrics
+type AppStats struct {
+	App string
+	// LastRun is the latest execution
+	LastRun OpResultEntity
+	// LastSuccess is the date of the latest successful execution, nil without success
+	LastSuccess *time.Time
+	// Counts are the number of executions by status
+	Counts map[RunStatus]int64
+}
+
+type statusCount struct {
+	App     string `gorm:"column:application"`
+	Status  RunStatus
+	Success bool
+	Count   int64
+}
+
+// GetAppStats summarizes the executions of every application. The latest executions are
+// retrieved via the index of the applications, the counts need to read all executions.
+func (s *dbStore) GetAppStats(ctx context.Context) ([]AppStats, error) {
+	apps, err := s.GetAvailApps(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve the applications; %v", err)
+	}
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	scope, scopeParams := scopeApps(ctx, s.con.R())
+
+	// entries created before the status was introduced only have the success flag
+	var counts []statusCount
+	query := s.con.R().WithContext(ctx).Model(&OpResultEntity{}).
+		Select("application, status, success, COUNT(*) AS count").
+		Group("application, status, success")
+	if scope != "" {
+		query = query.Where(scope, scopeParams...)
+	}
+	if err := query.Scan(&counts).Error; err != nil {
+		return nil, fmt.Errorf("could not count the executions; %v", err)
+	}
+
+	stats := make([]AppStats, 0, len(apps))
+	index := make(map[string]int, len(apps))
+	for _, app := range apps {
+		last, err := gorm.G[OpResultEntity](s.con.R()).Where("application = ?", app).Order("created DESC, id DESC").First(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("could not retrieve the latest execution of '%s'; %v", app, err)
+		}
+		app := AppStats{App: app, LastRun: last, Counts: map[RunStatus]int64{}}
+		if last.State() == StatusSuccess {
+			app.LastSuccess = &last.Created
+		} else {
+			success, err := gorm.G[OpResultEntity](s.con.R()).
+				Where("application = ? AND (status = ? OR ((status IS NULL OR status = '') AND success = ?))", app.App, StatusSuccess, true).
+				Order("created DESC, id DESC").First(ctx)
+			if err == nil {
+				app.LastSuccess = &success.Created
+			} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("could not retrieve the latest success of '%s'; %v", app.App, err)
+			}
+		}
+		index[app.App] = len(stats)
+		stats = append(stats, app)
+	}
+	for _, c := range counts {
+		i, ok := index[c.App]
+		if !ok {
+			continue
+		}
+		status := c.Status
+		if status == "" {
+			status = statusFromSuccess(c.Success)
+		}
+		stats[i].Counts[status] += c.Count
+	}
+	return stats, nil
+}
@@ -0,0 +1,80 @@
+package store_test
+
+import (
+	"cronlogger/store"
+	"testing"
+	"time"
+)
+
+func Test_App_Stats(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s store.OpResultStore) {
+		for _, item := range []store.OpResultEntity{
+			{App: "acme-tls", Status: store.StatusSuccess},
+			{App: "acme-tls", Status: store.StatusFailure},
+			{App: "acme-tls", Status: store.StatusFailure},
+			{App: "rclone", Status: store.StatusTimeout},
+			{App: "rclone", Status: store.StatusRunning},
+		} {
+			if _, err := s.Create(t.Context(), item); err != nil {
+				t.Fatalf("could not create item; %v", err)
+			}
+			// the items need distinct dates to define the latest item
+			time.Sleep(2 * time.Millisecond)
+		}
+
+		stats, err := s.GetAppStats(t.Context())
+		if err != nil {
+			t.Fatalf("could not get the stats; %v", err)
+		}
+		if len(stats) != 2 {
+			t.Fatalf("expected the stats of 2 applications, got %d", len(stats))
+		}
+		byApp := map[string]store.AppStats{}
+		for _, app := range stats {
+			byApp[app.App] = app
+		}
+
+		acme := byApp["acme-tls"]
+		if acme.LastRun.State() != store.StatusFailure || acme.LastSuccess == nil || !acme.LastSuccess.Before(acme.LastRun.Created) {
+			t.Errorf("unexpected latest executions of acme-tls %+v", acme)
+		}
+		if acme.Counts[store.StatusSuccess] != 1 || acme.Counts[store.StatusFailure] != 2 {
+			t.Errorf("unexpected counts of acme-tls %v", acme.Counts)
+		}
+		rclone := byApp["rclone"]
+		if rclone.LastRun.State() != store.StatusRunning || rclone.LastSuccess != nil || rclone.Counts[store.StatusTimeout] != 1 {
+			t.Errorf("unexpected stats of rclone %+v", rclone)
+		}
+
+		// the stats respect the restriction of the applications
+		stats, err = s.GetAppStats(store.WithApps(t.Context(), []string{"rclone"}))
+		if err != nil || len(stats) != 1 || stats[0].App != "rclone" {
+			t.Errorf("expected only the stats of rclone, got %+v; %v", stats, err)
+		}
+	})
+}
+
+func Test_App_Stats_Legacy(t *testing.T) {
+	con, db, err := store.CreateConFromDsn(":memory:")
+	if err != nil {
+		t.Fatalf("cannot create database connection: %v", err)
+	}
+	defer db.Close()
+	if _, err := store.Migrate(con); err != nil {
+		t.Fatalf("could not migrate the database; %v", err)
+	}
+	// entries created before the status was introduced only have the success flag
+	err = con.W().Exec(`INSERT INTO "OPRESULTS" (id, application, success, output, created, attempt_count) VALUES
+		('1', 'legacy', true, '', ?, 1), ('2', 'legacy', false, '', ?, 1)`, time.Now().Add(-time.Hour), time.Now()).Error
+	if err != nil {
+		t.Fatalf("could not insert the legacy items; %v", err)
+	}
+
+	stats, err := store.CreateStore(con, store.Options{}).GetAppStats(t.Context())
+	if err != nil {
+		t.Fatalf("could not get the stats; %v", err)
+	}
+	if len(stats) != 1 || stats[0].Counts[store.StatusSuccess] != 1 || stats[0].Counts[store.StatusFailure] != 1 || stats[0].LastSuccess == nil {
+		t.Errorf("unexpected stats of the legacy items %+v", stats)
+	}
+}
@@ -3,23 +3,98 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"io"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // the store defines a very simple interface to store the result of an operation
 // (typically a shell-script) in a table.
 
+// RunStatus describes the outcome of an execution in more detail than
+// the simple success flag
+type RunStatus string
+
+const (
+	// StatusSuccess is used for executions which finished with exit-code 0
+	StatusSuccess RunStatus = "success"
+	// StatusFailure is used for executions which finished with an exit-code other than 0
+	StatusFailure RunStatus = "failure"
+	// StatusTimeout is used for executions which were terminated because they exceeded their timeout
+	StatusTimeout RunStatus = "timeout"
+	// StatusSkipped is used for executions which were not started because the previous run was still active
+	StatusSkipped RunStatus = "skipped"
+	// StatusRunning is used for executions which were started but have not finished yet
+	StatusRunning RunStatus = "running"
+)
+
+// RunTrigger describes what started an execution
+type RunTrigger string
+
+const (
+	// TriggerLogger is used for results passed on to the logger, either via pipe or exec mode
+	TriggerLogger RunTrigger = ""
+	// TriggerSchedule is used for executions started by the built-in scheduler
+	TriggerSchedule RunTrigger = "schedule"
+	// TriggerManual is used for executions started via the web UI
+	TriggerManual RunTrigger = "manual"
+)
+
 // An OpResultEntity the result of an execution
 type OpResultEntity struct {
 	ID      string    `gorm:"primary_key;TYPE:varchar(36);COLUMN:id"`
 	App     string    `gorm:"COLUMN:application;TYPE:nvarchar(255);"`
 	Success bool      `gorm:"COLUMN:success;TYPE:bool;DEFAULT:FALSE;NOT NULL"`
+	Status  RunStatus `gorm:"COLUMN:status;TYPE:varchar(32);"`
 	Output  string    `gorm:"COLUMN:output;TYPE:nvarchar(255);"`
 	Created time.Time `gorm:"COLUMN:created;NOT NULL"`
+	// AttemptCount is the number of executions of the run, >1 if the run was retried
+	AttemptCount int `gorm:"COLUMN:attempt_count;DEFAULT:1;NOT NULL"`
+	// Attempts holds the individual executions of a retried run, populated by GetById
+	Attempts []OpAttemptEntity `gorm:"-"`
+	// Trigger defines what started the execution
+	Trigger RunTrigger `gorm:"COLUMN:run_trigger;TYPE:varchar(32);"`
+	// TriggeredBy is the user who started a manual execution
+	TriggeredBy string `gorm:"COLUMN:triggered_by;TYPE:nvarchar(255);"`
+	// Host is the origin of an imported execution, empty for executions of this host
+	Host string `gorm:"COLUMN:origin_host;TYPE:nvarchar(255);"`
+}
+
+// State returns the status of the execution. Entries created before the status
+// was introduced only have the success flag, the status is derived from it.
+func (o OpResultEntity) State() RunStatus {
+	if o.Status != "" {
+		return o.Status
+	}
+	return statusFromSuccess(o.Success)
+}
+
+// An OpAttemptEntity is a single execution of a run which was retried
+type OpAttemptEntity struct {
+	ID       string    `gorm:"primary_key;TYPE:varchar(36);COLUMN:id"`
+	RunID    string    `gorm:"COLUMN:run_id;TYPE:varchar(36);NOT NULL;index"`
+	Attempt  int       `gorm:"COLUMN:attempt;NOT NULL"`
+	Status   RunStatus `gorm:"COLUMN:status;TYPE:varchar(32);"`
+	ExitCode int       `gorm:"COLUMN:exit_code;NOT NULL"`
+	Output   string    `gorm:"COLUMN:output;TYPE:nvarchar(255);"`
+	Created  time.Time `gorm:"COLUMN:created;NOT NULL"`
+}
+
+// TableName specifies the name of the Table used
+func (OpAttemptEntity) TableName() string {
+	return "OPATTEMPTS"
+}
+
+func statusFromSuccess(success bool) RunStatus {
+	if success {
+		return StatusSuccess
+	}
+	return StatusFailure
 }
 
 // TableName specifies the name of the Table used
@@ -28,34 +103,107 @@ func (OpResultEntity) TableName() string {
 }
 
 // OpResultStore provides methods to interact with the store
+// The queries are canceled if the provided context is done, e.g. if a client aborts a request.
+// The queries reading executions are restricted to the applications of the context, see WithApps.
+// The date-range of the queries includes from and excludes until, see ParseDayRange.
 type OpResultStore interface {
-	Create(item OpResultEntity) (OpResultEntity, error)
-	GetById(id string) (OpResultEntity, error)
-	GetAll() ([]OpResultEntity, error)
-	GetPagedItems(pageSize, skip int, from, until *time.Time, appName string) (PagedOpResults, error)
-	GetAvailApps() ([]string, error)
+	Create(ctx context.Context, item OpResultEntity) (OpResultEntity, error)
+	Update(ctx context.Context, item OpResultEntity) (OpResultEntity, error)
+	Delete(ctx context.Context, id string) error
+	Import(ctx context.Context, items []OpResultEntity) (int, error)
+	GetById(ctx context.Context, id string) (OpResultEntity, error)
+	GetAll(ctx context.Context) ([]OpResultEntity, error)
+	GetPagedItems(ctx context.Context, pageSize, skip int, from, until *time.Time, appName string) (PagedOpResults, error)
+	GetCursorItems(ctx context.Context, pageSize int, cursor string, from, until *time.Time, appName string) (CursorOpResults, error)
+	GetAvailApps(ctx context.Context) ([]string, error)
+	ForEachItem(ctx context.Context, from, until *time.Time, appName string, handle func(item OpResultEntity) error) error
+	GetAppStats(ctx context.Context) ([]AppStats, error)
+}
+
+// DefaultQueryTimeout is the timeout of a store method used by the server
+const DefaultQueryTimeout = 5 * time.Second
+
+// Options configure the behavior of the store
+type Options struct {
+	// QueryTimeout limits the duration of a single store method, 0 disables the timeout
+	QueryTimeout time.Duration
+	// Events receives the committed changes of the runs, nil disables the events
+	Events *EventBus
 }
 
 // CreateStore creates a new store to persist data
-func CreateStore(con Connection) OpResultStore {
+func CreateStore(con Connection, opts Options) OpResultStore {
 	return &dbStore{
-		con: con,
+		con:          con,
+		queryTimeout: opts.QueryTimeout,
+		events:       opts.Events,
+	}
+}
+
+// CreateStoreFromDsn initializes a new store and applies the pending migrations of the schema.
+// The DSN is either a PostgreSQL URL (postgres://...) or the path of a sqlite file.
+func CreateStoreFromDsn(dsn string, opts Options) (OpResultStore, io.Closer, error) {
+	con, db, err := CreateConFromDsn(dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+	return migrateStore(con, db, opts)
+}
+
+// CreateConFromDsn opens the connection to the database of the DSN without changing the schema
+func CreateConFromDsn(dsn string) (Connection, io.Closer, error) {
+	if IsPostgresDsn(dsn) {
+		con, db, err := CreatePostgresConFromDsn(dsn)
+		if err != nil {
+			return Connection{}, nil, err
+		}
+		return con, db, nil
 	}
+	return CreateSqliteConFromDbPath(dsn)
 }
 
 // CreateSqliteStoreFromDbPath initializes a new store from a sqlite file path
-func CreateSqliteStoreFromDbPath(dbPath string) (OpResultStore, *sql.DB, error) {
-	db := MustCreateSqliteConn(dbPath)
-	con, err := CreateGormSqliteCon(db)
+// and applies the pending migrations of the schema
+func CreateSqliteStoreFromDbPath(dbPath string, opts Options) (OpResultStore, io.Closer, error) {
+	con, db, err := CreateSqliteConFromDbPath(dbPath)
 	if err != nil {
-		return nil, nil, fmt.Errorf("could not create database connection: %v", err)
+		return nil, nil, err
 	}
+	return migrateStore(con, db, opts)
+}
 
+func migrateStore(con Connection, db io.Closer, opts Options) (OpResultStore, io.Closer, error) {
 	// Migrate the schema
-	con.Write.AutoMigrate(&OpResultEntity{})
-	con.Read.AutoMigrate(&OpResultEntity{})
+	if _, err := Migrate(con); err != nil {
+		db.Close()
+		return nil, nil, fmt.Errorf("could not migrate the database schema: %v", err)
+	}
 
-	return CreateStore(con), db, nil
+	return CreateStore(con, opts), db, nil
+}
+
+// CreateSqliteConFromDbPath opens the connection to a sqlite file path without
+// changing the schema of the database. The returned closer closes the read and the write pool.
+func CreateSqliteConFromDbPath(dbPath string) (Connection, io.Closer, error) {
+	read, write := MustCreateSqlitePools(dbPath)
+	pools := sqlitePools{read, write}
+	con, err := CreateGormSqliteCon(read, write)
+	if err != nil {
+		pools.Close()
+		return Connection{}, nil, fmt.Errorf("could not create database connection: %v", err)
+	}
+	return con, pools, nil
+}
+
+// sqlitePools closes the read and the write pool of a sqlite database
+type sqlitePools []*sql.DB
+
+func (p sqlitePools) Close() error {
+	var errs []error
+	for _, db := range p {
+		errs = append(errs, db.Close())
+	}
+	return errors.Join(errs...)
 }
 
 type PagedOpResults struct {
@@ -68,44 +216,233 @@ type PagedOpResults struct {
 // --------------------------------------------------------------------------
 
 type dbStore struct {
-	con Connection
+	con          Connection
+	queryTimeout time.Duration
+	events       *EventBus
 }
 
-func (s *dbStore) Create(item OpResultEntity) (OpResultEntity, error) {
+// withTimeout applies the configured query timeout to the given context
+func (s *dbStore) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.queryTimeout)
+}
+
+// publish notifies the subscribers about a committed change, it does not block
+func (s *dbStore) publish(eventType EventType, item OpResultEntity) {
+	if s.events == nil {
+		return
+	}
+	s.events.Publish(Event{Type: eventType, Run: item, Time: time.Now()})
+}
+
+func (s *dbStore) Create(ctx context.Context, item OpResultEntity) (OpResultEntity, error) {
 	// set the necessary values like a new ID and created date
 	item.ID = uuid.New().String()
 	item.Created = time.Now()
-	ctx := context.Background()
-	err := gorm.G[OpResultEntity](s.con.W()).Create(ctx, &item)
+	if isPostgres(s.con.W()) {
+		// PostgreSQL stores microseconds, the returned item matches the stored one
+		item.Created = item.Created.Truncate(time.Microsecond)
+	}
+	if item.Status == "" {
+		item.Status = statusFromSuccess(item.Success)
+	}
+	item.Success = item.Status == StatusSuccess
+	item.AttemptCount = max(1, len(item.Attempts))
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	err := s.con.Begin(ctx, func(c Connection) error {
+		if err := gorm.G[OpResultEntity](c.W()).Create(ctx, &item); err != nil {
+			return err
+		}
+		return createAttempts(ctx, c, &item)
+	})
 	if err != nil {
 		return OpResultEntity{}, fmt.Errorf("could not store a new item: %v", err)
 	}
+	s.publish(RunCreated, item)
+	return item, nil
+}
+
+// Update replaces the result of an existing item, it is used to store the outcome of
+// an execution which was created with StatusRunning. Attempts are added to the item.
+// The stored item is returned.
+func (s *dbStore) Update(ctx context.Context, item OpResultEntity) (OpResultEntity, error) {
+	if item.ID == "" {
+		return OpResultEntity{}, fmt.Errorf("no id supplied")
+	}
+	if item.Status == "" {
+		item.Status = statusFromSuccess(item.Success)
+	}
+	item.Success = item.Status == StatusSuccess
+	item.AttemptCount = max(1, len(item.Attempts))
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	err := s.con.Begin(ctx, func(c Connection) error {
+		g := c.W().Model(&OpResultEntity{}).Where("id = ?", item.ID).Updates(map[string]any{
+			"success":       item.Success,
+			"status":        item.Status,
+			"output":        item.Output,
+			"attempt_count": item.AttemptCount,
+		})
+		if g.Error != nil {
+			return g.Error
+		}
+		if g.RowsAffected == 0 {
+			return fmt.Errorf("no item with id '%s'", item.ID)
+		}
+		// the subscribers receive the complete run, not only the updated columns
+		stored, err := gorm.G[OpResultEntity](c.W()).Where("id = ?", item.ID).First(ctx)
+		if err != nil {
+			return err
+		}
+		stored.Attempts = item.Attempts
+		item = stored
+		return createAttempts(ctx, c, &item)
+	})
+	if err != nil {
+		return OpResultEntity{}, fmt.Errorf("could not update item: %v", err)
+	}
+	s.publish(RunUpdated, item)
 	return item, nil
 }
 
-func (s *dbStore) GetById(id string) (OpResultEntity, error) {
+// Delete removes an item and its attempts
+func (s *dbStore) Delete(ctx context.Context, id string) error {
+	if id == "" {
+		return fmt.Errorf("no id supplied")
+	}
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var item OpResultEntity
+	err := s.con.Begin(ctx, func(c Connection) error {
+		var err error
+		item, err = gorm.G[OpResultEntity](c.W()).Where("id = ?", id).First(ctx)
+		if err != nil {
+			return err
+		}
+		if _, err := gorm.G[OpAttemptEntity](c.W()).Where("run_id = ?", id).Delete(ctx); err != nil {
+			return err
+		}
+		_, err = gorm.G[OpResultEntity](c.W()).Where("id = ?", id).Delete(ctx)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("could not delete item; %v", err)
+	}
+	s.publish(RunDeleted, item)
+	return nil
+}
+
+// Import stores existing items with their IDs and creation dates, e.g. the items of another
+// database. Items with an ID which is already available are skipped, the number of the stored
+// items is returned. No events are published, the items are not new executions.
+func (s *dbStore) Import(ctx context.Context, items []OpResultEntity) (int, error) {
+	for _, item := range items {
+		if item.ID == "" || item.Created.IsZero() {
+			return 0, fmt.Errorf("the imported item needs an id and a creation date")
+		}
+	}
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var imported int
+	err := s.con.Begin(ctx, func(c Connection) error {
+		imported = 0
+		for _, item := range items {
+			if item.Status == "" {
+				item.Status = statusFromSuccess(item.Success)
+			}
+			item.Success = item.Status == StatusSuccess
+			item.AttemptCount = max(1, item.AttemptCount, len(item.Attempts))
+
+			g := c.W().Clauses(clause.OnConflict{DoNothing: true}).Create(&item)
+			if g.Error != nil {
+				return g.Error
+			}
+			if g.RowsAffected == 0 {
+				continue
+			}
+			imported++
+			for _, attempt := range item.Attempts {
+				attempt.RunID = item.ID
+				if attempt.ID == "" {
+					attempt.ID = uuid.New().String()
+				}
+				if attempt.Created.IsZero() {
+					attempt.Created = item.Created
+				}
+				if err := c.W().Clauses(clause.OnConflict{DoNothing: true}).Create(&attempt).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("could not import items; %v", err)
+	}
+	return imported, nil
+}
+
+func createAttempts(ctx context.Context, c Connection, item *OpResultEntity) error {
+	for i := range item.Attempts {
+		attempt := &item.Attempts[i]
+		attempt.ID = uuid.New().String()
+		attempt.RunID = item.ID
+		if attempt.Created.IsZero() {
+			attempt.Created = item.Created
+		}
+		if err := gorm.G[OpAttemptEntity](c.W()).Create(ctx, attempt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *dbStore) GetById(ctx context.Context, id string) (OpResultEntity, error) {
 	if id == "" {
 		return OpResultEntity{}, fmt.Errorf("no id supplied")
 	}
 
-	ctx := context.Background()
-	item, err := gorm.G[OpResultEntity](s.con.R()).Where("id = ?", id).First(ctx)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	query := gorm.G[OpResultEntity](s.con.R()).Where("id = ?", id)
+	if scope, params := scopeApps(ctx, s.con.R()); scope != "" {
+		query = query.Where(scope, params...)
+	}
+	item, err := query.First(ctx)
 	if err != nil {
 		return OpResultEntity{}, fmt.Errorf("could not retrieve all entries; %v", err)
 	}
+	if item.AttemptCount > 1 {
+		item.Attempts, err = gorm.G[OpAttemptEntity](s.con.R()).Where("run_id = ?", id).Order("attempt ASC").Find(ctx)
+		if err != nil {
+			return OpResultEntity{}, fmt.Errorf("could not retrieve the attempts of the entry; %v", err)
+		}
+	}
 	return item, nil
 }
 
-func (s *dbStore) GetAll() ([]OpResultEntity, error) {
-	ctx := context.Background()
-	results, err := gorm.G[OpResultEntity](s.con.R()).Order("created DESC").Find(ctx)
+func (s *dbStore) GetAll(ctx context.Context) ([]OpResultEntity, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	query := gorm.G[OpResultEntity](s.con.R()).Order("created DESC, id DESC")
+	if scope, params := scopeApps(ctx, s.con.R()); scope != "" {
+		query = query.Where(scope, params...)
+	}
+	results, err := query.Find(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("could not retrieve all entries; %v", err)
 	}
 	return results, nil
 }
 
-func (s *dbStore) GetPagedItems(pageSize, skip int, from, until *time.Time, appName string) (PagedOpResults, error) {
+func (s *dbStore) GetPagedItems(ctx context.Context, pageSize, skip int, from, until *time.Time, appName string) (PagedOpResults, error) {
 	if pageSize < 0 {
 		return PagedOpResults{}, fmt.Errorf("negative pagesizes do not make sense")
 	}
@@ -114,16 +451,119 @@ func (s *dbStore) GetPagedItems(pageSize, skip int, from, until *time.Time, appN
 		return PagedOpResults{}, fmt.Errorf("negative offset does not make sense")
 	}
 
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	where, params := filterItems(from, until, appName)
+
+	var (
+		results      []OpResultEntity
+		totalEntries int64
+	)
+
+	// the filters on created and application are covered by the indexes
+	// idx_OPRESULTS_created and idx_OPRESULTS_application_created
+	query := s.con.R().WithContext(ctx).Model(&OpResultEntity{})
+	if where != "" {
+		query = query.Where(where, params...)
+	}
+	if scope, params := scopeApps(ctx, s.con.R()); scope != "" {
+		query = query.Where(scope, params...)
+	}
+
+	g := query.Session(&gorm.Session{}).Count(&totalEntries)
+	if g.Error != nil {
+		return PagedOpResults{}, fmt.Errorf("could not retrieve count of entries; %v", g.Error)
+	}
+	if pageSize == 0 || int64(skip) >= totalEntries {
+		return PagedOpResults{Items: []OpResultEntity{}, TotalCount: totalEntries}, nil
+	}
+
+	g = query.Session(&gorm.Session{}).Order("created DESC, id DESC").Limit(pageSize).Offset(skip).Find(&results)
+	if g.Error != nil {
+		return PagedOpResults{}, fmt.Errorf("could not retrieve entries; %v", g.Error)
+	}
+
+	return PagedOpResults{Items: results, TotalCount: totalEntries}, nil
+}
+
+// GetCursorItems retrieves the items created before the item of the cursor, an empty
+// cursor starts with the latest item. The returned cursor is used to retrieve the next page.
+func (s *dbStore) GetCursorItems(ctx context.Context, pageSize int, cursor string, from, until *time.Time, appName string) (CursorOpResults, error) {
+	if pageSize <= 0 {
+		return CursorOpResults{}, fmt.Errorf("the pagesize needs to be positive")
+	}
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	where, params := filterItems(from, until, appName)
+	query := s.con.R().WithContext(ctx).Model(&OpResultEntity{})
+	if where != "" {
+		query = query.Where(where, params...)
+	}
+	if scope, params := scopeApps(ctx, s.con.R()); scope != "" {
+		query = query.Where(scope, params...)
+	}
+	if cursor != "" {
+		c, err := DecodeCursor(cursor)
+		if err != nil {
+			return CursorOpResults{}, err
+		}
+		// the row-value comparison is covered by the indexes on (created, id)
+		query = query.Where("(created, id) < (?, ?)", c.Created, c.ID)
+	}
+
+	// one additional item is fetched to know if there is a next page
+	var results []OpResultEntity
+	g := query.Order("created DESC, id DESC").Limit(pageSize + 1).Find(&results)
+	if g.Error != nil {
+		return CursorOpResults{}, fmt.Errorf("could not retrieve entries; %v", g.Error)
+	}
+
+	page := CursorOpResults{Items: results}
+	if len(results) > pageSize {
+		page.Items = results[:pageSize]
+		page.NextCursor = cursorOf(page.Items[pageSize-1]).Encode()
+	}
+	return page, nil
+}
+
+// exportBatchSize is the number of items retrieved at once by ForEachItem
+const exportBatchSize = 500
+
+// ForEachItem calls handle for every item matching the filters, the latest item first.
+// The items are retrieved in batches, the query timeout applies to every batch.
+// An error of handle stops the iteration and is returned.
+func (s *dbStore) ForEachItem(ctx context.Context, from, until *time.Time, appName string, handle func(item OpResultEntity) error) error {
+	cursor := ""
+	for {
+		page, err := s.GetCursorItems(ctx, exportBatchSize, cursor, from, until, appName)
+		if err != nil {
+			return err
+		}
+		for _, item := range page.Items {
+			if err := handle(item); err != nil {
+				return err
+			}
+		}
+		if page.NextCursor == "" {
+			return nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// filterItems creates the where clause for the date-range and application filters
+func filterItems(from, until *time.Time, appName string) (string, []any) {
 	where := ""
 	params := make([]any, 0)
 	if from != nil && until != nil {
-		where = "created >= ? and created <= ?"
+		where = "created >= ? and created < ?"
 		params = append(params, *from, *until)
 	} else if from != nil {
 		where = "created >= ?"
 		params = append(params, *from)
 	} else if until != nil {
-		where = "created <= ?"
+		where = "created < ?"
 		params = append(params, *until)
 	}
 
@@ -134,32 +574,31 @@ func (s *dbStore) GetPagedItems(pageSize, skip int, from, until *time.Time, appN
 		where += "application = ?"
 		params = append(params, appName)
 	}
+	return where, params
+}
 
-	var (
-		results      []OpResultEntity
-		totalEntries int64
-	)
+// availAppsQuery retrieves the distinct applications with a loose index scan:
+// instead of grouping all rows, the index idx_OPRESULTS_application_created is used
+// to jump from one application to the next
+const availAppsQuery = `WITH RECURSIVE apps(application) AS (
+	SELECT MIN(application) FROM "OPRESULTS"
+	UNION ALL
+	SELECT (SELECT MIN(application) FROM "OPRESULTS" WHERE application > apps.application) FROM apps WHERE apps.application IS NOT NULL
+)
+SELECT application FROM apps WHERE application IS NOT NULL`
 
-	query := s.con.R().Find(&results).Order("created DESC")
-	if where != "" {
-		query = s.con.R().Where(where, params...).Find(&results).Order("created DESC")
-	}
+func (s *dbStore) GetAvailApps(ctx context.Context) ([]string, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 
-	g := query.Count(&totalEntries)
-	if g.Error != nil {
-		return PagedOpResults{}, fmt.Errorf("could not retrieve count of entries; %v", g.Error)
+	// the restriction of the context is applied to the distinct applications
+	query, params := availAppsQuery, []any(nil)
+	if scope, scopeParams := scopeApps(ctx, s.con.R()); scope != "" {
+		query, params = query+" AND "+scope, scopeParams
 	}
-	g = query.Limit(pageSize).Offset(skip).Find(&results)
-	if g.Error != nil {
-		return PagedOpResults{}, fmt.Errorf("could not retrieve entries; %v", g.Error)
-	}
-
-	return PagedOpResults{Items: results, TotalCount: totalEntries}, nil
-}
 
-func (s *dbStore) GetAvailApps() ([]string, error) {
 	var apps []string
-	g := s.con.R().Model(&OpResultEntity{}).Group("application").Order("application ASC").Pluck("application", &apps)
+	g := s.con.R().WithContext(ctx).Raw(query, params...).Scan(&apps)
 	if g.Error != nil {
 		return nil, g.Error
 	}
@@ -0,0 +1,150 @@
+package store_test
+
+import (
+	"cronlogger/store"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// the performance of the store is measured with a table of a million executions
+// distributed over 20 applications, one execution per minute. The test verifies the
+// results and the query plans with a smaller table, the timings are left to the benchmark.
+
+const (
+	largeTableRows = 1_000_000
+	largeTableApps = 20
+	// planTableRows covers more than a month of executions
+	planTableRows = 50_000
+)
+
+// seedLargeStore creates a store with the given number of executions
+func seedLargeStore(tb testing.TB, rows int) (store.OpResultStore, store.Connection) {
+	path := createDbFile(tb)
+	con, db, err := store.CreateSqliteConFromDbPath(path)
+	if err != nil {
+		tb.Fatalf("cannot create database connection: %v", err)
+	}
+	tb.Cleanup(func() { db.Close() })
+	if _, err := store.Migrate(con); err != nil {
+		tb.Fatalf("cannot migrate: %v", err)
+	}
+
+	err = con.Write.Exec(fmt.Sprintf(`WITH RECURSIVE seq(n) AS (SELECT 1 UNION ALL SELECT n+1 FROM seq WHERE n < %d)
+		INSERT INTO OPRESULTS (id, application, success, status, output, created, attempt_count)
+		SELECT printf('%%08d-0000-0000-0000-000000000000', n),
+			'app_' || (n %% %d),
+			n %% 7 != 0,
+			CASE WHEN n %% 7 = 0 THEN 'failure' ELSE 'success' END,
+			'output of execution ' || n,
+			strftime('%%Y-%%m-%%dT%%H:%%M:%%fZ', 'now', '-' || n || ' minutes'),
+			1
+		FROM seq`, rows, largeTableApps)).Error
+	if err != nil {
+		tb.Fatalf("cannot seed the table: %v", err)
+	}
+	if err := con.Write.Exec("ANALYZE").Error; err != nil {
+		tb.Fatalf("cannot analyze the table: %v", err)
+	}
+	return store.CreateStore(con, store.Options{}), con
+}
+
+func Test_LargeTable_QueryPlans(t *testing.T) {
+	s, con := seedLargeStore(t, planTableRows)
+
+	from := time.Now().AddDate(0, 0, -30)
+	until := time.Now().AddDate(0, 0, -20)
+
+	res, err := s.GetPagedItems(t.Context(), 20, 0, nil, nil, "")
+	if err != nil || res.TotalCount != planTableRows || len(res.Items) != 20 {
+		t.Errorf("first page: unexpected result %d/%d; %v", res.TotalCount, len(res.Items), err)
+	}
+	res, err = s.GetPagedItems(t.Context(), 20, 0, nil, nil, "app_3")
+	if err != nil || res.TotalCount != planTableRows/largeTableApps || len(res.Items) != 20 {
+		t.Errorf("first page of application: unexpected result %d/%d; %v", res.TotalCount, len(res.Items), err)
+	}
+	res, err = s.GetPagedItems(t.Context(), 20, 20, &from, &until, "app_3")
+	if err != nil || len(res.Items) != 20 {
+		t.Errorf("date range of application: unexpected result %d/%d; %v", res.TotalCount, len(res.Items), err)
+	}
+	cursor := store.PageCursor{Created: time.Now().AddDate(0, 0, -7), ID: "~"}.Encode()
+	page, err := s.GetCursorItems(t.Context(), 20, cursor, nil, nil, "app_3")
+	if err != nil || len(page.Items) != 20 || page.NextCursor == "" {
+		t.Errorf("deep page by cursor: unexpected result %d/'%s'; %v", len(page.Items), page.NextCursor, err)
+	}
+	apps, err := s.GetAvailApps(t.Context())
+	if err != nil || len(apps) != largeTableApps {
+		t.Errorf("expected %d applications, got %d; %v", largeTableApps, len(apps), err)
+	}
+
+	// the queries must use the indexes instead of scanning the table
+	plans := map[string]string{
+		"SELECT * FROM OPRESULTS ORDER BY created DESC, id DESC LIMIT 20":                                                                     "idx_OPRESULTS_created",
+		"SELECT count(*) FROM OPRESULTS WHERE application = 'app_3'":                                                                          "idx_OPRESULTS_application_created",
+		"SELECT * FROM OPRESULTS WHERE application = 'app_3' ORDER BY created DESC, id DESC LIMIT 20":                                         "idx_OPRESULTS_application_created",
+		"SELECT count(*) FROM OPRESULTS WHERE created >= '2025-01-01' and created <= '2025-02-01'":                                            "idx_OPRESULTS_created",
+		"SELECT MIN(application) FROM OPRESULTS WHERE application > 'app_1'":                                                                  "idx_OPRESULTS_application_created",
+		"SELECT * FROM OPRESULTS WHERE created >= '2025-01-01' and application = 'app_3' ORDER BY created DESC LIMIT 20":                      "idx_OPRESULTS_application_created",
+		"SELECT * FROM OPRESULTS WHERE (created, id) < ('2025-01-01', 'x') ORDER BY created DESC, id DESC LIMIT 21":                           "idx_OPRESULTS_created",
+		"SELECT * FROM OPRESULTS WHERE application = 'app_3' AND (created, id) < ('2025-01-01', 'x') ORDER BY created DESC, id DESC LIMIT 21": "idx_OPRESULTS_application_created",
+	}
+	for query, index := range plans {
+		var details []string
+		rows, err := con.Read.Raw("EXPLAIN QUERY PLAN " + query).Rows()
+		if err != nil {
+			t.Fatalf("cannot explain query: %v", err)
+		}
+		for rows.Next() {
+			var (
+				id, parent, notused int
+				detail              string
+			)
+			rows.Scan(&id, &parent, &notused, &detail)
+			details = append(details, detail)
+		}
+		rows.Close()
+		plan := strings.Join(details, "; ")
+		if !strings.Contains(plan, index) || strings.Contains(plan, "USE TEMP B-TREE") {
+			t.Errorf("the query '%s' does not use the index %s: %s", query, index, plan)
+		}
+	}
+}
+
+func Benchmark_LargeTable(b *testing.B) {
+	s, _ := seedLargeStore(b, largeTableRows)
+	from := time.Now().AddDate(0, 0, -30)
+	until := time.Now().AddDate(0, 0, -20)
+
+	b.Run("GetPagedItems", func(b *testing.B) {
+		for b.Loop() {
+			s.GetPagedItems(b.Context(), 20, 0, nil, nil, "")
+		}
+	})
+	b.Run("GetPagedItems_Application", func(b *testing.B) {
+		for b.Loop() {
+			s.GetPagedItems(b.Context(), 20, 0, nil, nil, "app_3")
+		}
+	})
+	b.Run("GetPagedItems_DateRange", func(b *testing.B) {
+		for b.Loop() {
+			s.GetPagedItems(b.Context(), 20, 0, &from, &until, "")
+		}
+	})
+	b.Run("GetPagedItems_DeepPage", func(b *testing.B) {
+		for b.Loop() {
+			s.GetPagedItems(b.Context(), 20, 10_000, nil, nil, "")
+		}
+	})
+	b.Run("GetCursorItems_DeepPage", func(b *testing.B) {
+		cursor := store.PageCursor{Created: time.Now().AddDate(0, 0, -7), ID: "~"}.Encode()
+		for b.Loop() {
+			s.GetCursorItems(b.Context(), 20, cursor, nil, nil, "")
+		}
+	})
+	b.Run("GetAvailApps", func(b *testing.B) {
+		for b.Loop() {
+			s.GetAvailApps(b.Context())
+		}
+	})
+}
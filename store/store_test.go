@@ -1,284 +1,636 @@
 package store_test
 
 import (
+	"context"
 	"cronlogger/store"
-	"database/sql"
+	"errors"
 	"fmt"
+	"io"
 	"testing"
 	"time"
 )
 
-func getStore(t *testing.T) (store.OpResultStore, *sql.DB) {
-	store, db, err := store.CreateSqliteStoreFromDbPath(":memory:")
+// the tests of the store are executed against every backend
+var backends = []string{"sqlite", "postgres"}
+
+// getStore creates an empty store of the given backend, the store is closed after the test
+func getStore(t *testing.T, backend string, opts store.Options) store.OpResultStore {
+	var (
+		s   store.OpResultStore
+		db  io.Closer
+		err error
+	)
+	switch backend {
+	case "postgres":
+		s, db, err = store.CreateStoreFromDsn(createPostgresDb(t), opts)
+	default:
+		s, db, err = store.CreateSqliteStoreFromDbPath(":memory:", opts)
+	}
 	if err != nil {
 		t.Fatalf("cannot create database connection: %v", err)
 	}
-	return store, db
+	t.Cleanup(func() { db.Close() })
+	return s
 }
 
-func Test_Create_OpResult(t *testing.T) {
-	s, db := getStore(t)
-	defer db.Close()
+func forEachBackend(t *testing.T, test func(t *testing.T, s store.OpResultStore)) {
+	forEachBackendWithOptions(t, store.Options{}, test)
+}
 
-	items, err := s.GetAll()
-	if err != nil {
-		t.Errorf("could not get all items; %v", err)
-	}
-	if len(items) != 0 {
-		t.Error("there should be not items in the result")
+func forEachBackendWithOptions(t *testing.T, opts store.Options, test func(t *testing.T, s store.OpResultStore)) {
+	for _, backend := range backends {
+		t.Run(backend, func(t *testing.T) {
+			test(t, getStore(t, backend, opts))
+		})
 	}
+}
 
-	item, err := s.Create(store.OpResultEntity{
-		App:     "test",
-		Success: true,
-		Output:  "",
+func Test_Create_OpResult(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s store.OpResultStore) {
+		items, err := s.GetAll(t.Context())
+		if err != nil {
+			t.Errorf("could not get all items; %v", err)
+		}
+		if len(items) != 0 {
+			t.Error("there should be not items in the result")
+		}
+
+		item, err := s.Create(t.Context(), store.OpResultEntity{
+			App:     "test",
+			Success: true,
+			Output:  "",
+		})
+		if err != nil {
+			t.Errorf("could not create an item; %v", err)
+		}
+
+		if item.ID == "" {
+			t.Errorf("a created item must have an ID")
+		}
+		date := time.Date(2025, time.December, 1, 00, 0, 0, 0, time.UTC)
+		if !item.Created.After(date) {
+			t.Errorf("item needs to have a created timestamp")
+		}
+
+		item, err = s.GetById(t.Context(), item.ID)
+		if err != nil {
+			t.Errorf("could not get item by ID; %v", err)
+		}
+
+		_, err = s.GetById(t.Context(), "")
+		if err == nil {
+			t.Errorf("expected an error for missing ID")
+		}
+
+		_, err = s.GetById(t.Context(), "no-id")
+		if err == nil {
+			t.Errorf("expected an error for wrong ID")
+		}
+
+		items, err = s.GetAll(t.Context())
+		if err != nil {
+			t.Errorf("could not get all items; %v", err)
+		}
+
+		if len(items) == 0 {
+			t.Error("expected a list of items but got empty list")
+		}
 	})
-	if err != nil {
-		t.Errorf("could not create an item; %v", err)
-	}
-
-	if item.ID == "" {
-		t.Errorf("a created item must have an ID")
-	}
-	date := time.Date(2025, time.December, 1, 00, 0, 0, 0, time.UTC)
-	if !item.Created.After(date) {
-		t.Errorf("item needs to have a created timestamp")
-	}
-
-	item, err = s.GetById(item.ID)
-	if err != nil {
-		t.Errorf("could not get item by ID; %v", err)
-	}
-
-	_, err = s.GetById("")
-	if err == nil {
-		t.Errorf("expected an error for missing ID")
-	}
-
-	_, err = s.GetById("no-id")
-	if err == nil {
-		t.Errorf("expected an error for wrong ID")
-	}
-
-	items, err = s.GetAll()
-	if err != nil {
-		t.Errorf("could not get all items; %v", err)
-	}
-
-	if len(items) == 0 {
-		t.Error("expected a list of items but got empty list")
-	}
 }
 
 func Test_Result_Order(t *testing.T) {
-	s, db := getStore(t)
-	defer db.Close()
+	forEachBackend(t, func(t *testing.T, s store.OpResultStore) {
+		s.Create(t.Context(), store.OpResultEntity{
+			App:     "test1",
+			Success: true,
+			Output:  "",
+		})
+		s.Create(t.Context(), store.OpResultEntity{
+			App:     "test2",
+			Success: false,
+			Output:  "",
+		})
+		s.Create(t.Context(), store.OpResultEntity{
+			App:     "test3",
+			Success: false,
+			Output:  "",
+		})
 
-	s.Create(store.OpResultEntity{
-		App:     "test1",
-		Success: true,
-		Output:  "",
-	})
-	s.Create(store.OpResultEntity{
-		App:     "test2",
-		Success: false,
-		Output:  "",
-	})
-	s.Create(store.OpResultEntity{
-		App:     "test3",
-		Success: false,
-		Output:  "",
+		items, err := s.GetAll(t.Context())
+		if err != nil {
+			t.Errorf("could not get all items; %v", err)
+		}
+		if items[0].App != "test3" && items[2].App != "test1" {
+			t.Errorf("the ordering of the results does not work")
+		}
 	})
-
-	items, err := s.GetAll()
-	if err != nil {
-		t.Errorf("could not get all items; %v", err)
-	}
-	if items[0].App != "test3" && items[2].App != "test1" {
-		t.Errorf("the ordering of the results does not work")
-	}
 }
 
 func Test_Paged_Results(t *testing.T) {
-	s, db := getStore(t)
-	defer db.Close()
+	forEachBackend(t, func(t *testing.T, s store.OpResultStore) {
+		for i := range 10 {
+			s.Create(t.Context(), store.OpResultEntity{
+				App:     fmt.Sprintf("test_%d", i),
+				Success: true,
+				Output:  "",
+			})
+		}
+
+		// standards queries
+		// ----------------------------------------------------------------------
+
+		// retrieve all 10 items
+		res, err := s.GetPagedItems(t.Context(), 10, 0, nil, nil, "")
+		if err != nil {
+			t.Errorf("could not get paged items; %v", err)
+		}
+		if res.TotalCount != 10 {
+			t.Errorf("expected 10 total items, got %d", res.TotalCount)
+		}
+
+		// retrieve 5 items
+		res, err = s.GetPagedItems(t.Context(), 5, 0, nil, nil, "")
+		if err != nil {
+			t.Errorf("could not get paged items; %v", err)
+		}
+		if res.TotalCount != 10 {
+			t.Errorf("expected 10 total items, got %d", res.TotalCount)
+		}
+		if len(res.Items) != 5 {
+			t.Errorf("expected 5 items, got %d", res.TotalCount)
+		}
+		if res.Items[0].App != "test_9" {
+			t.Errorf("expected test_9 item, got %s", res.Items[0].App)
+		}
+		if res.Items[4].App != "test_5" {
+			t.Errorf("expected test_5 item, got %s", res.Items[0].App)
+		}
+
+		// retrieve 3 items / skip 3
+		res, err = s.GetPagedItems(t.Context(), 3, 3, nil, nil, "")
+		if err != nil {
+			t.Errorf("could not get paged items; %v", err)
+		}
+		if res.TotalCount != 10 {
+			t.Errorf("expected 10 total items, got %d", res.TotalCount)
+		}
+		if len(res.Items) != 3 {
+			t.Errorf("expected 3 items, got %d", res.TotalCount)
+		}
+		if res.Items[0].App != "test_6" {
+			t.Errorf("expected test_6 item, got %s", res.Items[0].App)
+		}
+		if res.Items[2].App != "test_4" {
+			t.Errorf("expected test_4 item, got %s", res.Items[0].App)
+		}
+
+		// filter application
+		future := time.Now().AddDate(1, 0, 0)
+		res, err = s.GetPagedItems(t.Context(), 10, 0, nil, &future, "test_9")
+		if err != nil {
+			t.Errorf("could not get paged items; %v", err)
+		}
+		if res.TotalCount != 1 {
+			t.Errorf("expected 1 total items, got %d", res.TotalCount)
+		}
+
+		// filter date
+
+		res, err = s.GetPagedItems(t.Context(), 10, 0, &future, nil, "")
+		if err != nil {
+			t.Errorf("could not get paged items; %v", err)
+		}
+		if res.TotalCount != 0 {
+			t.Errorf("expected 0 total items, got %d", res.TotalCount)
+		}
+
+		past := time.Now().AddDate(-1, 0, 0)
+		res, err = s.GetPagedItems(t.Context(), 10, 0, nil, &past, "")
+		if err != nil {
+			t.Errorf("could not get paged items; %v", err)
+		}
+		if res.TotalCount != 0 {
+			t.Errorf("expected 0 total items, got %d", res.TotalCount)
+		}
+
+		res, err = s.GetPagedItems(t.Context(), 10, 0, &past, &future, "")
+		if err != nil {
+			t.Errorf("could not get paged items; %v", err)
+		}
+		if res.TotalCount != 10 {
+			t.Errorf("expected 10 total items, got %d", res.TotalCount)
+		}
+
+		// corner cases
+		// ----------------------------------------------------------------------
+
+		res, err = s.GetPagedItems(t.Context(), 0, 0, nil, nil, "")
+		if err != nil {
+			t.Errorf("could not get paged items; %v", err)
+		}
+		if res.TotalCount != 10 {
+			t.Errorf("expected 10 total items, got %d", res.TotalCount)
+		}
+		if len(res.Items) != 0 {
+			t.Errorf("expected 0 items, got %d", res.TotalCount)
+		}
+
+		// negative pagesize
+		res, err = s.GetPagedItems(t.Context(), -2, 0, nil, nil, "")
+		if err == nil {
+			t.Errorf("error expected for negative pagesize")
+		}
+
+		// negative skip
+		res, err = s.GetPagedItems(t.Context(), 0, -3, nil, nil, "")
+		if err == nil {
+			t.Errorf("error expected for negative offset")
+		}
+
+		// skip is too big
+		res, err = s.GetPagedItems(t.Context(), 0, 100, nil, nil, "")
+		if err != nil {
+			t.Errorf("could not get paged items; %v", err)
+		}
+		if res.TotalCount != 10 {
+			t.Errorf("expected 10 total items, got %d", res.TotalCount)
+		}
+		if len(res.Items) != 0 {
+			t.Errorf("expected 0 items, got %d", res.TotalCount)
+		}
+
+		// retrieve 3 items / skip 8
+		res, err = s.GetPagedItems(t.Context(), 3, 8, nil, nil, "")
+		if err != nil {
+			t.Errorf("could not get paged items; %v", err)
+		}
+		if res.TotalCount != 10 {
+			t.Errorf("expected 10 total items, got %d", res.TotalCount)
+		}
+		if len(res.Items) != 2 {
+			t.Errorf("expected 2 items, got %d", len(res.Items))
+		}
+		if res.Items[0].App != "test_1" {
+			t.Errorf("expected test_1 item, got %s", res.Items[0].App)
+		}
+		if res.Items[1].App != "test_0" {
+			t.Errorf("expected test_0 item, got %s", res.Items[1].App)
+		}
+	})
+}
 
-	for i := range 10 {
-		s.Create(store.OpResultEntity{
-			App:     fmt.Sprintf("test_%d", i),
+func Test_Available_Apps(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s store.OpResultStore) {
+		s.Create(t.Context(), store.OpResultEntity{
+			App:     "test1",
 			Success: true,
 			Output:  "",
 		})
-	}
-
-	// standards queries
-	// ----------------------------------------------------------------------
-
-	// retrieve all 10 items
-	res, err := s.GetPagedItems(10, 0, nil, nil, "")
-	if err != nil {
-		t.Errorf("could not get paged items; %v", err)
-	}
-	if res.TotalCount != 10 {
-		t.Errorf("expected 10 total items, got %d", res.TotalCount)
-	}
-
-	// retrieve 5 items
-	res, err = s.GetPagedItems(5, 0, nil, nil, "")
-	if err != nil {
-		t.Errorf("could not get paged items; %v", err)
-	}
-	if res.TotalCount != 10 {
-		t.Errorf("expected 10 total items, got %d", res.TotalCount)
-	}
-	if len(res.Items) != 5 {
-		t.Errorf("expected 5 items, got %d", res.TotalCount)
-	}
-	if res.Items[0].App != "test_9" {
-		t.Errorf("expected test_9 item, got %s", res.Items[0].App)
-	}
-	if res.Items[4].App != "test_5" {
-		t.Errorf("expected test_5 item, got %s", res.Items[0].App)
-	}
-
-	// retrieve 3 items / skip 3
-	res, err = s.GetPagedItems(3, 3, nil, nil, "")
-	if err != nil {
-		t.Errorf("could not get paged items; %v", err)
-	}
-	if res.TotalCount != 10 {
-		t.Errorf("expected 10 total items, got %d", res.TotalCount)
-	}
-	if len(res.Items) != 3 {
-		t.Errorf("expected 3 items, got %d", res.TotalCount)
-	}
-	if res.Items[0].App != "test_6" {
-		t.Errorf("expected test_6 item, got %s", res.Items[0].App)
-	}
-	if res.Items[2].App != "test_4" {
-		t.Errorf("expected test_4 item, got %s", res.Items[0].App)
-	}
-
-	// filter application
-	future := time.Now().AddDate(1, 0, 0)
-	res, err = s.GetPagedItems(10, 0, nil, &future, "test_9")
-	if err != nil {
-		t.Errorf("could not get paged items; %v", err)
-	}
-	if res.TotalCount != 1 {
-		t.Errorf("expected 1 total items, got %d", res.TotalCount)
-	}
-
-	// filter date
-
-	res, err = s.GetPagedItems(10, 0, &future, nil, "")
-	if err != nil {
-		t.Errorf("could not get paged items; %v", err)
-	}
-	if res.TotalCount != 0 {
-		t.Errorf("expected 0 total items, got %d", res.TotalCount)
-	}
-
-	past := time.Now().AddDate(-1, 0, 0)
-	res, err = s.GetPagedItems(10, 0, nil, &past, "")
-	if err != nil {
-		t.Errorf("could not get paged items; %v", err)
-	}
-	if res.TotalCount != 0 {
-		t.Errorf("expected 0 total items, got %d", res.TotalCount)
-	}
-
-	res, err = s.GetPagedItems(10, 0, &past, &future, "")
-	if err != nil {
-		t.Errorf("could not get paged items; %v", err)
-	}
-	if res.TotalCount != 10 {
-		t.Errorf("expected 10 total items, got %d", res.TotalCount)
-	}
-
-	// corner cases
-	// ----------------------------------------------------------------------
-
-	res, err = s.GetPagedItems(0, 0, nil, nil, "")
-	if err != nil {
-		t.Errorf("could not get paged items; %v", err)
-	}
-	if res.TotalCount != 10 {
-		t.Errorf("expected 10 total items, got %d", res.TotalCount)
-	}
-	if len(res.Items) != 0 {
-		t.Errorf("expected 0 items, got %d", res.TotalCount)
-	}
-
-	// negative pagesize
-	res, err = s.GetPagedItems(-2, 0, nil, nil, "")
-	if err == nil {
-		t.Errorf("error expected for negative pagesize")
-	}
-
-	// negative skip
-	res, err = s.GetPagedItems(0, -3, nil, nil, "")
-	if err == nil {
-		t.Errorf("error expected for negative offset")
-	}
+		s.Create(t.Context(), store.OpResultEntity{
+			App:     "test2",
+			Success: false,
+			Output:  "",
+		})
+		s.Create(t.Context(), store.OpResultEntity{
+			App:     "test3",
+			Success: false,
+			Output:  "",
+		})
 
-	// skip is too big
-	res, err = s.GetPagedItems(0, 100, nil, nil, "")
-	if err != nil {
-		t.Errorf("could not get paged items; %v", err)
-	}
-	if res.TotalCount != 10 {
-		t.Errorf("expected 10 total items, got %d", res.TotalCount)
-	}
-	if len(res.Items) != 0 {
-		t.Errorf("expected 0 items, got %d", res.TotalCount)
-	}
+		items, err := s.GetAvailApps(t.Context())
+		if err != nil {
+			t.Errorf("could not get all items; %v", err)
+		}
+		if items[0] != "test1" && items[2] != "test3" {
+			t.Errorf("the ordering of the results does not work")
+		}
+	})
+}
 
-	// retrieve 3 items / skip 8
-	res, err = s.GetPagedItems(3, 8, nil, nil, "")
-	if err != nil {
-		t.Errorf("could not get paged items; %v", err)
-	}
-	if res.TotalCount != 10 {
-		t.Errorf("expected 10 total items, got %d", res.TotalCount)
-	}
-	if len(res.Items) != 2 {
-		t.Errorf("expected 2 items, got %d", len(res.Items))
-	}
-	if res.Items[0].App != "test_1" {
-		t.Errorf("expected test_1 item, got %s", res.Items[0].App)
-	}
-	if res.Items[1].App != "test_0" {
-		t.Errorf("expected test_0 item, got %s", res.Items[1].App)
-	}
+func Test_Result_Status(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s store.OpResultStore) {
+		item, err := s.Create(t.Context(), store.OpResultEntity{
+			App:     "test",
+			Success: true,
+		})
+		if err != nil {
+			t.Errorf("could not create an item; %v", err)
+		}
+		if item.State() != store.StatusSuccess {
+			t.Errorf("expected status %s, got %s", store.StatusSuccess, item.State())
+		}
+
+		item, err = s.Create(t.Context(), store.OpResultEntity{
+			App:     "test",
+			Success: false,
+		})
+		if err != nil {
+			t.Errorf("could not create an item; %v", err)
+		}
+		if item.State() != store.StatusFailure {
+			t.Errorf("expected status %s, got %s", store.StatusFailure, item.State())
+		}
+
+		item, err = s.Create(t.Context(), store.OpResultEntity{
+			App:    "test",
+			Status: store.StatusTimeout,
+		})
+		if err != nil {
+			t.Errorf("could not create an item; %v", err)
+		}
+		item, err = s.GetById(t.Context(), item.ID)
+		if err != nil {
+			t.Errorf("could not get item by ID; %v", err)
+		}
+		if item.State() != store.StatusTimeout {
+			t.Errorf("expected status %s, got %s", store.StatusTimeout, item.State())
+		}
+		if item.Success {
+			t.Errorf("a timed out execution must not be successful")
+		}
+
+		// entries without a status are derived from the success flag
+		legacy := store.OpResultEntity{Success: true}
+		if legacy.State() != store.StatusSuccess {
+			t.Errorf("expected status %s, got %s", store.StatusSuccess, legacy.State())
+		}
+	})
+}
 
+func Test_Result_Attempts(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s store.OpResultStore) {
+		item, err := s.Create(t.Context(), store.OpResultEntity{
+			App:    "test",
+			Status: store.StatusSuccess,
+			Output: "done",
+			Attempts: []store.OpAttemptEntity{
+				{Attempt: 1, Status: store.StatusFailure, ExitCode: 1, Output: "network error"},
+				{Attempt: 2, Status: store.StatusTimeout, ExitCode: -1, Output: "hanging"},
+				{Attempt: 3, Status: store.StatusSuccess, ExitCode: 0, Output: "done"},
+			},
+		})
+		if err != nil {
+			t.Fatalf("could not create an item; %v", err)
+		}
+		if item.AttemptCount != 3 {
+			t.Errorf("expected 3 attempts, got %d", item.AttemptCount)
+		}
+
+		item, err = s.GetById(t.Context(), item.ID)
+		if err != nil {
+			t.Fatalf("could not get item by ID; %v", err)
+		}
+		if len(item.Attempts) != 3 {
+			t.Fatalf("expected 3 attempts, got %d", len(item.Attempts))
+		}
+		for i, attempt := range item.Attempts {
+			if attempt.Attempt != i+1 {
+				t.Errorf("expected attempt %d, got %d", i+1, attempt.Attempt)
+			}
+			if attempt.RunID != item.ID {
+				t.Errorf("the attempt is not related to the run")
+			}
+		}
+		if item.Attempts[0].ExitCode != 1 || item.Attempts[1].Status != store.StatusTimeout {
+			t.Errorf("the attempt details were not stored")
+		}
+
+		// a run without retries has a single attempt
+		item, err = s.Create(t.Context(), store.OpResultEntity{
+			App:     "test",
+			Success: true,
+		})
+		if err != nil {
+			t.Fatalf("could not create an item; %v", err)
+		}
+		item, err = s.GetById(t.Context(), item.ID)
+		if err != nil {
+			t.Fatalf("could not get item by ID; %v", err)
+		}
+		if item.AttemptCount != 1 || len(item.Attempts) != 0 {
+			t.Errorf("expected a single attempt without details, got %d/%d", item.AttemptCount, len(item.Attempts))
+		}
+	})
 }
 
-func Test_Available_Apps(t *testing.T) {
-	s, db := getStore(t)
-	defer db.Close()
+func Test_Update_OpResult(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s store.OpResultStore) {
+		item, err := s.Create(t.Context(), store.OpResultEntity{
+			App:         "test",
+			Status:      store.StatusRunning,
+			Trigger:     store.TriggerManual,
+			TriggeredBy: "user",
+		})
+		if err != nil {
+			t.Fatalf("could not create an item; %v", err)
+		}
+		if item.State() != store.StatusRunning || item.Success {
+			t.Errorf("expected a running item, got %s", item.State())
+		}
+
+		item.Status = store.StatusFailure
+		item.Output = "failed twice"
+		item.Attempts = []store.OpAttemptEntity{
+			{Attempt: 1, Status: store.StatusFailure, ExitCode: 1},
+			{Attempt: 2, Status: store.StatusFailure, ExitCode: 1},
+		}
+		if _, err = s.Update(t.Context(), item); err != nil {
+			t.Fatalf("could not update the item; %v", err)
+		}
+
+		updated, err := s.GetById(t.Context(), item.ID)
+		if err != nil {
+			t.Fatalf("could not get item by ID; %v", err)
+		}
+		if updated.State() != store.StatusFailure || updated.Output != "failed twice" {
+			t.Errorf("the item was not updated: %s/%s", updated.State(), updated.Output)
+		}
+		if updated.Trigger != store.TriggerManual || updated.TriggeredBy != "user" {
+			t.Errorf("the trigger of the item must not change")
+		}
+		if !updated.Created.Equal(item.Created) {
+			t.Errorf("the created timestamp must not change")
+		}
+		if updated.AttemptCount != 2 || len(updated.Attempts) != 2 {
+			t.Errorf("expected 2 attempts, got %d", len(updated.Attempts))
+		}
+
+		_, err = s.Update(t.Context(), store.OpResultEntity{})
+		if err == nil {
+			t.Errorf("expected an error for missing ID")
+		}
+		_, err = s.Update(t.Context(), store.OpResultEntity{ID: "no-id"})
+		if err == nil {
+			t.Errorf("expected an error for wrong ID")
+		}
+	})
+}
 
-	s.Create(store.OpResultEntity{
-		App:     "test1",
-		Success: true,
-		Output:  "",
+func Test_Cursor_Results(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s store.OpResultStore) {
+		for i := range 10 {
+			s.Create(t.Context(), store.OpResultEntity{
+				App:     fmt.Sprintf("test_%d", i%2),
+				Success: true,
+				Output:  fmt.Sprintf("%d", i),
+			})
+		}
+
+		// page through all items with a page size which does not divide the total
+		var (
+			outputs []string
+			cursor  string
+			pages   int
+		)
+		for {
+			res, err := s.GetCursorItems(t.Context(), 3, cursor, nil, nil, "")
+			if err != nil {
+				t.Fatalf("could not get cursor items; %v", err)
+			}
+			pages++
+			for _, item := range res.Items {
+				outputs = append(outputs, item.Output)
+			}
+			if res.NextCursor == "" {
+				break
+			}
+			cursor = res.NextCursor
+
+			// new items do not shift the following pages
+			if pages == 1 {
+				s.Create(t.Context(), store.OpResultEntity{App: "test_0", Success: true, Output: "new"})
+			}
+		}
+		if pages != 4 {
+			t.Errorf("expected 4 pages, got %d", pages)
+		}
+		if fmt.Sprint(outputs) != "[9 8 7 6 5 4 3 2 1 0]" {
+			t.Errorf("unexpected items %v", outputs)
+		}
+
+		// an exactly filled last page has no next cursor
+		res, err := s.GetCursorItems(t.Context(), 11, "", nil, nil, "")
+		if err != nil {
+			t.Fatalf("could not get cursor items; %v", err)
+		}
+		if len(res.Items) != 11 || res.NextCursor != "" {
+			t.Errorf("expected 11 items and no cursor, got %d/'%s'", len(res.Items), res.NextCursor)
+		}
+
+		// filter by application
+		res, err = s.GetCursorItems(t.Context(), 2, "", nil, nil, "test_1")
+		if err != nil {
+			t.Fatalf("could not get cursor items; %v", err)
+		}
+		if len(res.Items) != 2 || res.Items[0].Output != "9" || res.Items[1].Output != "7" {
+			t.Errorf("unexpected items %+v", res.Items)
+		}
+		res, err = s.GetCursorItems(t.Context(), 2, res.NextCursor, nil, nil, "test_1")
+		if err != nil {
+			t.Fatalf("could not get cursor items; %v", err)
+		}
+		if len(res.Items) != 2 || res.Items[0].Output != "5" || res.Items[1].Output != "3" {
+			t.Errorf("unexpected items %+v", res.Items)
+		}
+
+		// filter by date
+		future := time.Now().Add(time.Hour)
+		res, err = s.GetCursorItems(t.Context(), 2, "", &future, nil, "")
+		if err != nil {
+			t.Fatalf("could not get cursor items; %v", err)
+		}
+		if len(res.Items) != 0 || res.NextCursor != "" {
+			t.Errorf("expected no items, got %d", len(res.Items))
+		}
+
+		// invalid input
+		for _, cursor := range []string{"invalid!", "bm8tc2VwYXJhdG9y", "MjAyNXxpZA"} {
+			if _, err := s.GetCursorItems(t.Context(), 2, cursor, nil, nil, ""); !errors.Is(err, store.ErrInvalidCursor) {
+				t.Errorf("expected an invalid cursor error for '%s', got %v", cursor, err)
+			}
+		}
+		if _, err := s.GetCursorItems(t.Context(), 0, "", nil, nil, ""); err == nil {
+			t.Errorf("expected an error for page size 0")
+		}
 	})
-	s.Create(store.OpResultEntity{
-		App:     "test2",
-		Success: false,
-		Output:  "",
+}
+
+func Test_Canceled_Queries(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s store.OpResultStore) {
+		if _, err := s.Create(t.Context(), store.OpResultEntity{App: "test", Success: true}); err != nil {
+			t.Fatalf("could not create an item; %v", err)
+		}
+
+		// a canceled context aborts the queries and the transactions
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+		if _, err := s.GetAll(ctx); err == nil {
+			t.Errorf("expected an error for a canceled context")
+		}
+		if _, err := s.GetCursorItems(ctx, 10, "", nil, nil, ""); err == nil {
+			t.Errorf("expected an error for a canceled context")
+		}
+		if _, err := s.GetAvailApps(ctx); err == nil {
+			t.Errorf("expected an error for a canceled context")
+		}
+		if _, err := s.Create(ctx, store.OpResultEntity{App: "canceled"}); err == nil {
+			t.Errorf("expected an error for a canceled context")
+		}
+		items, err := s.GetAll(t.Context())
+		if err != nil {
+			t.Fatalf("could not get all items; %v", err)
+		}
+		if len(items) != 1 {
+			t.Errorf("expected 1 item, got %d", len(items))
+		}
 	})
-	s.Create(store.OpResultEntity{
-		App:     "test3",
-		Success: false,
-		Output:  "",
+}
+
+func Test_Query_Timeout(t *testing.T) {
+	forEachBackendWithOptions(t, store.Options{QueryTimeout: time.Nanosecond}, func(t *testing.T, s store.OpResultStore) {
+		if _, err := s.GetAll(t.Context()); err == nil {
+			t.Errorf("expected an error for an exceeded query timeout")
+		}
+		if _, err := s.GetPagedItems(t.Context(), 10, 0, nil, nil, ""); err == nil {
+			t.Errorf("expected an error for an exceeded query timeout")
+		}
 	})
+}
 
-	items, err := s.GetAvailApps()
-	if err != nil {
-		t.Errorf("could not get all items; %v", err)
-	}
-	if items[0] != "test1" && items[2] != "test3" {
-		t.Errorf("the ordering of the results does not work")
-	}
+func Test_Day_Range(t *testing.T) {
+	if _, _, err := store.ParseDayRange("2025-01-01", "31.01.2025"); err == nil {
+		t.Errorf("expected an error for an invalid date")
+	}
+	from, until, err := store.ParseDayRange("", "")
+	if err != nil || from != nil || until != nil {
+		t.Errorf("expected an open range, got %v - %v; %v", from, until, err)
+	}
+
+	forEachBackend(t, func(t *testing.T, s store.OpResultStore) {
+		day := func(d, h, m int) time.Time {
+			return time.Date(2025, time.January, d, h, m, 0, 0, time.Local)
+		}
+		items := []store.OpResultEntity{
+			{ID: "before", App: "range", Created: day(1, 0, 0).Add(-time.Second)},
+			{ID: "first", App: "range", Created: day(1, 0, 0)},
+			{ID: "last", App: "range", Created: day(31, 23, 59).Add(59*time.Second + 900*time.Millisecond)},
+			{ID: "after", App: "range", Created: day(32, 0, 0)},
+		}
+		if _, err := s.Import(t.Context(), items); err != nil {
+			t.Fatalf("could not import the items; %v", err)
+		}
+
+		// the range includes midnight of the first day and the complete last day
+		from, until, err := store.ParseDayRange("2025-01-01", "2025-01-31")
+		if err != nil {
+			t.Fatalf("could not parse the range; %v", err)
+		}
+		res, err := s.GetCursorItems(t.Context(), 10, "", from, until, "range")
+		if err != nil {
+			t.Fatalf("could not get the items; %v", err)
+		}
+		if len(res.Items) != 2 || res.Items[0].ID != "last" || res.Items[1].ID != "first" {
+			t.Errorf("expected the items of the range, got %+v", res.Items)
+		}
+	})
 }
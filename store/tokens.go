@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrTokenNotFound is returned if no API token has the hash
+var ErrTokenNotFound = errors.New("the API token was not found")
+
+// An APITokenEntity is a bearer token created via the admin page, only the hash of the token is stored
+type APITokenEntity struct {
+	ID   string `gorm:"primary_key;TYPE:varchar(36);COLUMN:id"`
+	Name string `gorm:"COLUMN:name;TYPE:nvarchar(255);NOT NULL"`
+	// Hash is the hex encoded SHA-256 hash of the token
+	Hash string `gorm:"COLUMN:token_hash;TYPE:varchar(64);NOT NULL;uniqueIndex"`
+	// Scopes are separated by spaces, e.g. "ingest:rclone-* read:*"
+	Scopes    string    `gorm:"COLUMN:scopes;TYPE:nvarchar(1024);"`
+	Created   time.Time `gorm:"COLUMN:created;NOT NULL"`
+	CreatedBy string    `gorm:"COLUMN:created_by;TYPE:nvarchar(255);"`
+	// Expires is nil for tokens which do not expire
+	Expires *time.Time `gorm:"COLUMN:expires"`
+	// LastUsed is nil for tokens which were not used yet
+	LastUsed *time.Time `gorm:"COLUMN:last_used"`
+}
+
+// TableName specifies the name of the Table used
+func (APITokenEntity) TableName() string {
+	return "APITOKENS"
+}
+
+// TokenStore persists the API tokens
+type TokenStore interface {
+	CreateToken(ctx context.Context, token APITokenEntity) (APITokenEntity, error)
+	GetTokenByHash(ctx context.Context, hash string) (APITokenEntity, error)
+	GetTokens(ctx context.Context) ([]APITokenEntity, error)
+	DeleteToken(ctx context.Context, id string) error
+	TouchToken(ctx context.Context, id string, used time.Time) error
+}
+
+// CreateTokenStore creates the store of the API tokens, the schema needs to be migrated
+func CreateTokenStore(con Connection, opts Options) TokenStore {
+	return &dbStore{
+		con:          con,
+		queryTimeout: opts.QueryTimeout,
+	}
+}
+
+// CreateToken stores a new token, the ID and the creation date are set
+func (s *dbStore) CreateToken(ctx context.Context, token APITokenEntity) (APITokenEntity, error) {
+	if token.Name == "" || token.Hash == "" {
+		return APITokenEntity{}, fmt.Errorf("the API token needs a name and a hash")
+	}
+	token.ID = uuid.New().String()
+	token.Created = time.Now().Truncate(time.Microsecond)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if err := gorm.G[APITokenEntity](s.con.W()).Create(ctx, &token); err != nil {
+		return APITokenEntity{}, fmt.Errorf("could not store the API token '%s'; %v", token.Name, err)
+	}
+	return token, nil
+}
+
+// GetTokenByHash retrieves the token of the hash, ErrTokenNotFound is returned for unknown hashes
+func (s *dbStore) GetTokenByHash(ctx context.Context, hash string) (APITokenEntity, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	token, err := gorm.G[APITokenEntity](s.con.R()).Where("token_hash = ?", hash).First(ctx)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return APITokenEntity{}, ErrTokenNotFound
+	}
+	if err != nil {
+		return APITokenEntity{}, fmt.Errorf("could not retrieve the API token; %v", err)
+	}
+	return token, nil
+}
+
+// GetTokens retrieves all tokens, the latest token first
+func (s *dbStore) GetTokens(ctx context.Context) ([]APITokenEntity, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tokens, err := gorm.G[APITokenEntity](s.con.R()).Order("created DESC, id DESC").Find(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve the API tokens; %v", err)
+	}
+	return tokens, nil
+}
+
+// DeleteToken revokes a token
+func (s *dbStore) DeleteToken(ctx context.Context, id string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	deleted, err := gorm.G[APITokenEntity](s.con.W()).Where("id = ?", id).Delete(ctx)
+	if err != nil {
+		return fmt.Errorf("could not delete the API token '%s'; %v", id, err)
+	}
+	if deleted == 0 {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+// TouchToken records the last use of a token
+func (s *dbStore) TouchToken(ctx context.Context, id string, used time.Time) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if _, err := gorm.G[APITokenEntity](s.con.W()).Where("id = ?", id).Update(ctx, "last_used", used); err != nil {
+		return fmt.Errorf("could not update the API token '%s'; %v", id, err)
+	}
+	return nil
+}
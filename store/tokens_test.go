@@ -0,0 +1,80 @@
+package store_test
+
+import (
+	"cronlogger/store"
+	"errors"
+	"testing"
+	"time"
+)
+
+func getTokenStore(t *testing.T, backend string) store.TokenStore {
+	dsn := ":memory:"
+	if backend == "postgres" {
+		dsn = createPostgresDb(t)
+	}
+	con, db, err := store.CreateConFromDsn(dsn)
+	if err != nil {
+		t.Fatalf("cannot create database connection: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := store.Migrate(con); err != nil {
+		t.Fatalf("could not migrate the database; %v", err)
+	}
+	return store.CreateTokenStore(con, store.Options{})
+}
+
+func Test_Tokens(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend, func(t *testing.T) {
+			s := getTokenStore(t, backend)
+
+			expires := time.Now().Add(time.Hour).Truncate(time.Second)
+			token, err := s.CreateToken(t.Context(), store.APITokenEntity{Name: "web1", Hash: "abc", Scopes: "ingest:rclone-*", Expires: &expires, CreatedBy: "alice"})
+			if err != nil {
+				t.Fatalf("could not create the token; %v", err)
+			}
+			if token.ID == "" || token.Created.IsZero() {
+				t.Errorf("expected the ID and the creation date to be set")
+			}
+			if _, err := s.CreateToken(t.Context(), store.APITokenEntity{Name: "web2", Hash: "abc"}); err == nil {
+				t.Errorf("expected an error for a duplicate hash")
+			}
+			if _, err := s.CreateToken(t.Context(), store.APITokenEntity{Name: "web3"}); err == nil {
+				t.Errorf("expected an error for a missing hash")
+			}
+
+			found, err := s.GetTokenByHash(t.Context(), "abc")
+			if err != nil {
+				t.Fatalf("could not get the token; %v", err)
+			}
+			if found.Name != "web1" || found.Scopes != "ingest:rclone-*" || found.Expires == nil || !found.Expires.Equal(expires) || found.LastUsed != nil {
+				t.Errorf("unexpected token %+v", found)
+			}
+			if _, err := s.GetTokenByHash(t.Context(), "other"); !errors.Is(err, store.ErrTokenNotFound) {
+				t.Errorf("expected ErrTokenNotFound, got %v", err)
+			}
+
+			used := time.Now().Truncate(time.Second)
+			if err := s.TouchToken(t.Context(), token.ID, used); err != nil {
+				t.Fatalf("could not touch the token; %v", err)
+			}
+			tokens, err := s.GetTokens(t.Context())
+			if err != nil {
+				t.Fatalf("could not get the tokens; %v", err)
+			}
+			if len(tokens) != 1 || tokens[0].LastUsed == nil || !tokens[0].LastUsed.Equal(used) {
+				t.Errorf("unexpected tokens %+v", tokens)
+			}
+
+			if err := s.DeleteToken(t.Context(), token.ID); err != nil {
+				t.Fatalf("could not delete the token; %v", err)
+			}
+			if err := s.DeleteToken(t.Context(), token.ID); !errors.Is(err, store.ErrTokenNotFound) {
+				t.Errorf("expected ErrTokenNotFound for a deleted token, got %v", err)
+			}
+			if _, err := s.GetTokenByHash(t.Context(), "abc"); !errors.Is(err, store.ErrTokenNotFound) {
+				t.Errorf("expected the token to be deleted, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package tracing
+
+import (
+	"context"
+	"cronlogger"
+	"cronlogger/store"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// the traces are exported via OTLP/HTTP to a collector. Without an endpoint the global
+// tracer provider of OpenTelemetry remains the no-op provider, the instrumentation is
+// therefore always in place and only creates spans once the export is configured.
+
+// name is the instrumentation scope of the spans
+const name = "cronlogger"
+
+// Tracer returns the tracer of the cronlogger spans
+func Tracer() trace.Tracer {
+	return otel.Tracer(name)
+}
+
+// Setup configures the export of the traces, the returned func flushes the pending spans
+// and stops the export. Without an endpoint the tracing is disabled and the func does nothing.
+func Setup(ctx context.Context, config cronlogger.TracingConfig, serviceName, version string) (shutdown func(ctx context.Context) error, err error) {
+	if config.Endpoint == "" {
+		return func(ctx context.Context) error { return nil }, nil
+	}
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid tracing endpoint '%s', expected a URL like http://localhost:4318", config.Endpoint)
+	}
+	if endpoint.Path == "" || endpoint.Path == "/" {
+		endpoint.Path = "/v1/traces"
+	}
+	ratio := config.SampleRatio
+	if ratio < 0 || ratio > 1 {
+		return nil, fmt.Errorf("invalid sample ratio %v, expected a value between 0 and 1", ratio)
+	}
+	if ratio == 0 {
+		ratio = 1
+	}
+	if config.ServiceName != "" {
+		serviceName = config.ServiceName
+	}
+
+	exporter, err := otlptracehttp.New(ctx,
+		otlptracehttp.WithEndpointURL(endpoint.String()),
+		otlptracehttp.WithHeaders(config.Headers),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not create the trace exporter; %v", err)
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", serviceName),
+		attribute.String("service.version", version),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("could not create the trace resource; %v", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// Middleware creates a span for every request. The span is named after the route of the
+// request, which is refined while the request is handled, e.g. by the metrics.
+func Middleware(next http.Handler, route func(ctx context.Context) string) http.Handler {
+	withRoute := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+		// the nested handlers work on copies of the request, the route is only known from the context
+		if pattern := route(r.Context()); pattern != "" {
+			span := trace.SpanFromContext(r.Context())
+			span.SetName(r.Method + " " + pattern)
+			span.SetAttributes(attribute.String("http.route", pattern))
+		}
+	})
+	return otelhttp.NewHandler(withRoute, "request", otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+		if pattern := route(r.Context()); pattern != "" {
+			return r.Method + " " + pattern
+		}
+		return r.Method
+	}))
+}
+
+// Start creates a span of an operation, e.g. the rendering of a page
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records the error of the operation and ends the span
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+type spanKey struct{}
+
+// InstrumentStore creates a span for every query of the store. The span is a child of the
+// span of the context the query is executed with, e.g. the span of the request.
+func InstrumentStore(con store.Connection) error {
+	if err := instrumentGorm(con.Write); err != nil {
+		return err
+	}
+	// PostgreSQL uses the same pool for reading and writing
+	if con.Read != con.Write {
+		return instrumentGorm(con.Read)
+	}
+	return nil
+}
+
+func instrumentGorm(db *gorm.DB) error {
+	system := db.Dialector.Name()
+	before := func(operation string) func(tx *gorm.DB) {
+		return func(tx *gorm.DB) {
+			ctx, _ := Tracer().Start(tx.Statement.Context, "db."+operation, trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(attribute.String("db.system", system)))
+			tx.Statement.Context = context.WithValue(ctx, spanKey{}, true)
+		}
+	}
+	after := func(tx *gorm.DB) {
+		if tx.Statement.Context.Value(spanKey{}) == nil {
+			return
+		}
+		span := trace.SpanFromContext(tx.Statement.Context)
+		if table := tx.Statement.Table; table != "" {
+			span.SetAttributes(attribute.String("db.collection.name", table))
+		}
+		span.SetAttributes(
+			attribute.String("db.query.text", strings.TrimSpace(tx.Statement.SQL.String())),
+			attribute.Int64("db.response.rows", tx.Statement.RowsAffected),
+		)
+		var err error
+		if tx.Error != nil && !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+			err = tx.Error
+		}
+		End(span, err)
+	}
+
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("tracing:before_create", before("create")),
+		cb.Create().After("gorm:create").Register("tracing:after_create", after),
+		cb.Query().Before("gorm:query").Register("tracing:before_query", before("query")),
+		cb.Query().After("gorm:query").Register("tracing:after_query", after),
+		cb.Update().Before("gorm:update").Register("tracing:before_update", before("update")),
+		cb.Update().After("gorm:update").Register("tracing:after_update", after),
+		cb.Delete().Before("gorm:delete").Register("tracing:before_delete", before("delete")),
+		cb.Delete().After("gorm:delete").Register("tracing:after_delete", after),
+		cb.Row().Before("gorm:row").Register("tracing:before_row", before("row")),
+		cb.Row().After("gorm:row").Register("tracing:after_row", after),
+		cb.Raw().Before("gorm:raw").Register("tracing:before_raw", before("raw")),
+		cb.Raw().After("gorm:raw").Register("tracing:after_raw", after),
+	)
+}
+
+// the trace context of a command is passed on via environment variables, e.g. from a CI
+// pipeline to the logger and from the logger to the executed script
+// https://opentelemetry.io/docs/specs/otel/context/env-carriers/
+var envPropagator = propagation.TraceContext{}
+
+// FromEnv continues the trace of the TRACEPARENT variable of the process
+func FromEnv(ctx context.Context) context.Context {
+	carrier := propagation.MapCarrier{}
+	if parent := os.Getenv("TRACEPARENT"); parent != "" {
+		carrier.Set("traceparent", parent)
+	}
+	if state := os.Getenv("TRACESTATE"); state != "" {
+		carrier.Set("tracestate", state)
+	}
+	return envPropagator.Extract(ctx, carrier)
+}
+
+// Env returns the TRACEPARENT variable of the span of the context, nil without a recorded span
+func Env(ctx context.Context) []string {
+	carrier := propagation.MapCarrier{}
+	envPropagator.Inject(ctx, carrier)
+	var env []string
+	if parent := carrier.Get("traceparent"); parent != "" {
+		env = append(env, "TRACEPARENT="+parent)
+	}
+	if state := carrier.Get("tracestate"); state != "" {
+		env = append(env, "TRACESTATE="+state)
+	}
+	return env
+}
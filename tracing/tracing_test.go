@@ -0,0 +1,182 @@
+package tracing_test
+
+import (
+	"cronlogger"
+	"cronlogger/metrics"
+	"cronlogger/store"
+	"cronlogger/tracing"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+var logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// collector is an in-process stub of an OTLP/HTTP receiver
+type collector struct {
+	*httptest.Server
+	mu    sync.Mutex
+	spans []*tracepb.Span
+}
+
+func newCollector(t *testing.T) *collector {
+	c := &collector{}
+	c.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/traces" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var req collectortrace.ExportTraceServiceRequest
+		if err := proto.Unmarshal(body, &req); err != nil {
+			t.Errorf("could not decode the exported spans; %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		c.mu.Lock()
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				c.spans = append(c.spans, ss.Spans...)
+			}
+		}
+		c.mu.Unlock()
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(c.Close)
+	return c
+}
+
+// find returns the first span with a name starting with the prefix
+func (c *collector) find(prefix string) *tracepb.Span {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, span := range c.spans {
+		if strings.HasPrefix(span.Name, prefix) {
+			return span
+		}
+	}
+	return nil
+}
+
+func attr(span *tracepb.Span, key string) string {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value.GetStringValue()
+		}
+	}
+	return ""
+}
+
+func Test_Setup_Invalid(t *testing.T) {
+	for name, config := range map[string]cronlogger.TracingConfig{
+		"no scheme": {Endpoint: "localhost:4318"},
+		"scheme":    {Endpoint: "grpc://localhost:4317"},
+		"ratio":     {Endpoint: "http://localhost:4318", SampleRatio: 2},
+	} {
+		if _, err := tracing.Setup(t.Context(), config, "test", "1.0.0"); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	// without an endpoint the tracing is disabled
+	shutdown, err := tracing.Setup(t.Context(), cronlogger.TracingConfig{}, "test", "1.0.0")
+	if err != nil || shutdown(t.Context()) != nil {
+		t.Errorf("expected the disabled tracing; %v", err)
+	}
+}
+
+func Test_Tracing(t *testing.T) {
+	c := newCollector(t)
+	shutdown, err := tracing.Setup(t.Context(), cronlogger.TracingConfig{Endpoint: c.URL}, "test", "1.0.0")
+	if err != nil {
+		t.Fatalf("could not set up the tracing; %v", err)
+	}
+
+	con, db, err := store.CreateConFromDsn(":memory:")
+	if err != nil {
+		t.Fatalf("cannot create database connection: %v", err)
+	}
+	defer db.Close()
+	if _, err := store.Migrate(con); err != nil {
+		t.Fatalf("could not migrate the database; %v", err)
+	}
+	if err := tracing.InstrumentStore(con); err != nil {
+		t.Fatalf("could not instrument the store; %v", err)
+	}
+	s := store.CreateStore(con, store.Options{})
+
+	m, err := metrics.New(s, logger, cronlogger.AppConfig{})
+	if err != nil {
+		t.Fatalf("could not create the metrics; %v", err)
+	}
+	routes := http.NewServeMux()
+	routes.HandleFunc("GET /App/{name}", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := s.GetPagedItems(r.Context(), 10, 0, nil, nil, r.PathValue("name")); err != nil {
+			t.Errorf("could not get the items; %v", err)
+		}
+		_, span := tracing.Start(r.Context(), "render AppPage")
+		tracing.End(span, nil)
+	})
+	mux := http.NewServeMux()
+	mux.Handle("/cronlogger/", http.StripPrefix("/cronlogger", metrics.Route("/cronlogger", routes)))
+	h := m.Middleware(mux, tracing.Middleware(mux, metrics.RouteOf))
+
+	// the trace of the caller is continued
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	r := httptest.NewRequest("GET", "/cronlogger/App/acme-tls", nil)
+	r.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if err := shutdown(t.Context()); err != nil {
+		t.Fatalf("could not export the spans; %v", err)
+	}
+
+	request := c.find("GET ")
+	if request == nil || request.Name != "GET /cronlogger/App/{name}" || attr(request, "http.route") != "/cronlogger/App/{name}" {
+		t.Fatalf("expected the span of the request, got %v", request)
+	}
+	if hex.EncodeToString(request.TraceId) != traceID {
+		t.Errorf("expected the trace of the caller, got %x", request.TraceId)
+	}
+	for _, name := range []string{"db.", "render AppPage"} {
+		span := c.find(name)
+		if span == nil {
+			t.Errorf("expected a span '%s'", name)
+			continue
+		}
+		if string(span.TraceId) != string(request.TraceId) || string(span.ParentSpanId) != string(request.SpanId) {
+			t.Errorf("expected the span '%s' to be a child of the request", span.Name)
+		}
+	}
+	if query := c.find("db."); query != nil && !strings.Contains(attr(query, "db.query.text"), "OPRESULTS") {
+		t.Errorf("expected the query of the span, got '%s'", attr(query, "db.query.text"))
+	}
+}
+
+func Test_Env(t *testing.T) {
+	const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	t.Setenv("TRACEPARENT", parent)
+
+	// without a recorded span the trace of the parent is passed on
+	env := tracing.Env(tracing.FromEnv(t.Context()))
+	if len(env) != 1 || env[0] != "TRACEPARENT="+parent {
+		t.Errorf("expected the trace of the parent, got %v", env)
+	}
+	if env := tracing.Env(t.Context()); len(env) != 0 {
+		t.Errorf("expected no trace without a parent, got %v", env)
+	}
+}